@@ -12,6 +12,6 @@ func main() {
 	rootCmd := cli.NewRootCmd(trustpin.NewService(""))
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
 	}
 }
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/milan604/trustPIN/internal/trustpin"
+	"github.com/spf13/cobra"
+)
+
+func newBrandCmd(app *App) *cobra.Command {
+	brandCmd := &cobra.Command{
+		Use:          "brand <account>",
+		Aliases:      []string{"icon"},
+		Short:        "Show or change the color and icon an account is drawn with",
+		Long:         "Show the brand TrustPIN resolves for an account, found by ID, name, or search terms, from its built-in issuer catalog. --color overrides the brand color, --icon stores a PNG, JPEG, GIF or WebP image of at most 64 KiB encrypted with the account for the web dashboard, and --reset goes back to the issuer's brand.",
+		SilenceUsage: true,
+		Args:         cobra.MinimumNArgs(1),
+		RunE:         app.runBrandCommand,
+	}
+	brandCmd.Flags().String("color", "", "Hex color such as #1f6feb to draw the account with")
+	brandCmd.Flags().String("icon", "", "Image file to use as the account icon")
+	brandCmd.Flags().Bool("reset", false, "Remove the custom color and icon")
+	brandCmd.MarkFlagsMutuallyExclusive("reset", "color")
+	brandCmd.MarkFlagsMutuallyExclusive("reset", "icon")
+	return brandCmd
+}
+
+func (a *App) runBrandCommand(cmd *cobra.Command, args []string) error {
+	colorValue, _ := cmd.Flags().GetString("color")
+	iconPath, _ := cmd.Flags().GetString("icon")
+	reset, _ := cmd.Flags().GetBool("reset")
+	service := a.service()
+
+	account, err := findAccount(service, strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+
+	var icon *trustpin.AccountIcon
+	if iconPath != "" {
+		data, err := os.ReadFile(iconPath)
+		if err != nil {
+			return exitError{code: ExitInvalidInput, err: fmt.Errorf("read icon: %w", err)}
+		}
+		if icon, err = trustpin.NewAccountIcon(data); err != nil {
+			return err
+		}
+	}
+
+	if reset || cmd.Flags().Changed("color") {
+		if account, err = service.SetAccountColor(account.ID, colorValue); err != nil {
+			return err
+		}
+	}
+	if reset || icon != nil {
+		if account, err = service.SetAccountIcon(account.ID, icon); err != nil {
+			return err
+		}
+	}
+
+	snapshot := trustpin.BuildAccountSnapshot(account)
+	if output := a.outputOptions(); output.machine() {
+		return writeAccounts(os.Stdout, []trustpin.AccountSnapshot{snapshot}, output)
+	}
+	fmt.Print(renderBrandPanel(account))
+	return nil
+}
+
+func renderBrandPanel(account trustpin.Account) string {
+	brand := trustpin.AccountBrand(account)
+	issuer, _, hasIssuer := trustpin.AccountIssuer(account)
+
+	source := "derived from the issuer name"
+	switch {
+	case !hasIssuer:
+		issuer, source = "Standalone", "no issuer to match"
+	case brand.Known:
+		source = "built-in catalog: " + brand.Name
+	}
+	colorLine := brand.Color
+	if colorLine == "" {
+		colorLine = "none"
+	}
+	if account.Color != "" {
+		colorLine += " (custom)"
+	}
+	iconLine := "monogram in the brand color"
+	if account.Icon != nil {
+		iconLine = fmt.Sprintf("custom %s, %d bytes", account.Icon.Type, len(account.Icon.Data))
+	}
+
+	lines := []string{
+		"issuer " + issuerBadge(issuer, brand.Color) + " " + mutedText("("+source+")"),
+		"color  " + issuerBadge(colorLine, brand.Color),
+		"icon   " + iconLine,
+	}
+	return strings.Join(renderPanel("Brand: "+account.Name, lines, min(terminalWidth(), 72)), "\n") + "\n"
+}
@@ -3,16 +3,24 @@ package cli
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/fatih/color"
 	"github.com/milan604/trustPIN/internal/trustpin"
 	"github.com/milan604/trustPIN/internal/webui"
 	"github.com/spf13/cobra"
 )
 
 type App struct {
-	storePath string
+	storePath      string
+	configPath     string
+	output         string
+	includeSecrets bool
 }
 
 func NewRootCmd(service trustpin.Service) *cobra.Command {
@@ -24,9 +32,18 @@ func NewRootCmd(service trustpin.Service) *cobra.Command {
 		Long:         "TrustPIN is a local-first TOTP workspace for importing, monitoring, and auditing one-time-password accounts in polished terminal and browser dashboards.",
 		SilenceUsage: true,
 		RunE:         app.runShowCommand,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := app.applyPreferences(cmd); err != nil {
+				return err
+			}
+			return app.configureOutput()
+		},
 	}
 
 	rootCmd.PersistentFlags().StringVar(&app.storePath, "accounts-file", service.StorePath, "Path to the TrustPIN encrypted account store")
+	rootCmd.PersistentFlags().StringVarP(&app.output, "output", "o", outputTable, "Output format: table, json, yaml, csv")
+	rootCmd.PersistentFlags().BoolVar(&app.includeSecrets, "include-secrets", false, "Include raw secrets in json, yaml, and csv output")
+	rootCmd.PersistentFlags().StringVar(&app.configPath, configFlagName, "", "Path to the TrustPIN config file (default "+trustpin.DefaultConfigFileName+" in the app data directory)")
 
 	addCmd := &cobra.Command{
 		Use:          "add [account] [secret]",
@@ -51,12 +68,22 @@ func NewRootCmd(service trustpin.Service) *cobra.Command {
 		Use:          "inspect <account>",
 		Aliases:      []string{"view"},
 		Short:        "Open a focused live view for one account",
-		Long:         "Inspect a single account with a dedicated OTP view, account metadata, and refresh cycle details.",
+		Long:         "Inspect a single account by ID, name, or search terms with a dedicated OTP view, account metadata, and refresh cycle details.",
 		SilenceUsage: true,
 		Args:         cobra.MinimumNArgs(1),
 		RunE:         app.runInspectCommand,
 	}
 
+	codeCmd := &cobra.Command{
+		Use:          "code <account>",
+		Aliases:      []string{"otp"},
+		Short:        "Print the current code for one account",
+		Long:         "Print the current code for one account, found by ID, name, or search terms, and record that it was used. --next moves a counter-based (HOTP) account to its next code first.",
+		SilenceUsage: true,
+		Args:         cobra.MinimumNArgs(1),
+		RunE:         app.runCodeCommand,
+	}
+
 	healthCmd := &cobra.Command{
 		Use:          "health",
 		Aliases:      []string{"audit"},
@@ -70,7 +97,7 @@ func NewRootCmd(service trustpin.Service) *cobra.Command {
 		Use:          "delete [account ...]",
 		Aliases:      []string{"rm"},
 		Short:        "Delete one or more TOTP accounts",
-		Long:         "Delete one or more accounts by name. Running delete with no account names removes all accounts after confirmation.",
+		Long:         "Delete one or more accounts by ID, name, or search terms. An account found by search is only deleted after confirmation, or with --force. Running delete with no arguments removes all accounts after confirmation.",
 		SilenceUsage: true,
 		Args:         cobra.ArbitraryArgs,
 		RunE:         app.deleteAccounts,
@@ -85,6 +112,15 @@ func NewRootCmd(service trustpin.Service) *cobra.Command {
 		RunE:         app.runMigrateCommand,
 	}
 
+	batchCmd := &cobra.Command{
+		Use:          "batch <operations.jsonl>",
+		Short:        "Apply many account changes in one transaction",
+		Long:         "Apply a file of JSON operations, one per line, in a single transaction. Operations are delete, archive, set-tags, set-favorite, and update; if any of them fails nothing is saved. Use - to read from stdin.",
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+		RunE:         app.runBatchCommand,
+	}
+
 	serveCmd := &cobra.Command{
 		Use:          "serve",
 		Aliases:      []string{"web", "ui"},
@@ -98,33 +134,57 @@ func NewRootCmd(service trustpin.Service) *cobra.Command {
 	addCmd.Flags().IntP("digits", "d", trustpin.DefaultDigits, "Number of TOTP digits")
 	addCmd.Flags().StringP("qr-file", "q", "", "Path to a QR image file containing an otpauth payload")
 	addCmd.Flags().StringP("algorithm", "a", "SHA1", "Hash algorithm: SHA1, SHA256, SHA512")
+	addCmd.Flags().String("issuer", "", "Issuer for the account; the account argument then becomes the label")
+	addCmd.Flags().StringP("encoding", "e", "auto", "Secret encoding: auto, base32, base32-nopad, base64, hex, raw")
 	addCmd.Flags().StringP("type", "t", "totp", "OTP type: totp, hotp, steam")
 	addCmd.Flags().Int64("counter", 0, "Initial counter value for HOTP accounts")
 	addCmd.Flags().StringSlice("tags", nil, "Comma-separated tags for the account")
 	addCmd.Flags().Bool("favorite", false, "Mark the account as a favorite")
 	addCmd.Flags().String("notes", "", "Notes or recovery codes to attach to the account")
+	configureImportFlags(addCmd)
 
 	configureShowFlags(rootCmd)
 	configureShowFlags(showCmd)
 
 	inspectCmd.Flags().Bool("watch", true, "Keep the inspect view live and refresh every second")
 	inspectCmd.Flags().Bool("once", false, "Render one snapshot and exit")
+	inspectCmd.Flags().Bool("private", false, "Mask the code, secret preview and notes")
+	inspectCmd.Flags().Bool("reveal", false, "Show the code even when private mode is on")
+
+	codeCmd.Flags().Bool("next", false, "Advance a HOTP account's counter and print the new code")
 
-	deleteCmd.Flags().BoolP("force", "f", false, "Delete without confirmation when removing all accounts")
+	healthCmd.Flags().String("fail-on", "", "Exit with a non-zero status when findings reach this level: critical, warning")
+	healthCmd.Flags().String("rules", "", "Path to a health rule file (default "+trustpin.DefaultHealthPolicyFileName+" in the app data directory)")
+
+	deleteCmd.Flags().BoolP("force", "f", false, "Delete without confirmation when removing all accounts or an account found by search")
 	migrateCmd.Flags().Bool("keep-source", false, "Keep the plaintext source file after successful migration")
+	configureImportFlags(migrateCmd)
+	batchCmd.Flags().Bool("dry-run", false, "Check every operation without saving")
 	serveCmd.Flags().IntP("port", "p", 8086, "Port for the web server")
-
-	rootCmd.AddCommand(addCmd, showCmd, inspectCmd, healthCmd, deleteCmd, migrateCmd, serveCmd)
+	serveCmd.Flags().String("bind", "127.0.0.1", "Address to listen on; non-loopback addresses need --allow-remote and --tls")
+	serveCmd.Flags().Bool("allow-remote", false, "Allow --bind to expose the dashboard beyond this machine")
+	serveCmd.Flags().String("socket", "", "Listen on a Unix domain socket (mode 0600) instead of a TCP port")
+	serveCmd.Flags().Bool("tls", false, "Serve HTTPS with a certificate from a local CA kept in the app data directory")
+	serveCmd.Flags().String("tls-cert", "", "PEM certificate to serve instead of the generated one (implies --tls)")
+	serveCmd.Flags().String("tls-key", "", "PEM private key for --tls-cert")
+	serveCmd.Flags().Duration("idle-timeout", defaultIdleTimeout, "Lock browser sessions after this long without activity (0 disables)")
+	serveCmd.Flags().String("token", "", "Access token for unlocking the dashboard (default: random per run)")
+
+	rootCmd.AddCommand(addCmd, showCmd, inspectCmd, codeCmd, healthCmd, deleteCmd, migrateCmd, batchCmd, serveCmd, newRecoveryCmd(app), newBrandCmd(app), newDoctorCmd(app), newConfigCmd(app))
 	return rootCmd
 }
 
 func configureShowFlags(cmd *cobra.Command) {
-	cmd.Flags().StringP("search", "s", "", "Filter accounts by name or issuer")
+	cmd.Flags().StringP("search", "s", "", "Filter accounts by name, issuer, tags or notes, tolerating typos")
 	cmd.Flags().String("issuer", "", "Only show accounts for a specific issuer")
-	cmd.Flags().String("sort", "expiry", "Sort by: expiry, name, issuer, digits")
+	cmd.Flags().String("sort", "expiry", "Sort by: expiry, name, issuer, digits, recent (last used), added (newest first), relevance (default when searching)")
+	cmd.Flags().String("group-by", "", "Group accounts by: issuer, tag, or type")
 	cmd.Flags().Bool("watch", true, "Keep the dashboard live and refresh every second")
 	cmd.Flags().Bool("once", false, "Render one snapshot and exit")
 	cmd.Flags().Bool("compact", false, "Use a denser list layout")
+	cmd.Flags().Bool("reveal-notes", false, "Show notes and unused recovery codes on account cards")
+	cmd.Flags().Bool("private", false, "Mask codes, secret previews and notes; press 1-9 in watch mode to reveal one account")
+	cmd.Flags().Duration("idle-timeout", defaultIdleTimeout, "Blank codes in watch mode after this long without a key press (0 disables)")
 }
 
 func (a *App) runShowCommand(cmd *cobra.Command, args []string) error {
@@ -135,20 +195,36 @@ func (a *App) runShowCommand(cmd *cobra.Command, args []string) error {
 
 	issuer, _ := cmd.Flags().GetString("issuer")
 	sortBy, _ := cmd.Flags().GetString("sort")
+	groupBy, _ := cmd.Flags().GetString("group-by")
 	watch, _ := cmd.Flags().GetBool("watch")
 	once, _ := cmd.Flags().GetBool("once")
 	compact, _ := cmd.Flags().GetBool("compact")
+	revealNotes, _ := cmd.Flags().GetBool("reveal-notes")
+	private, _ := cmd.Flags().GetBool("private")
+	idleTimeout, _ := cmd.Flags().GetDuration("idle-timeout")
+	if idleTimeout < 0 {
+		return fmt.Errorf("--idle-timeout cannot be negative")
+	}
 
 	opts := showOptions{
-		Search:  strings.TrimSpace(search),
-		Issuer:  strings.TrimSpace(issuer),
-		SortBy:  strings.TrimSpace(sortBy),
-		Watch:   watch,
-		Compact: compact,
+		Search:      strings.TrimSpace(search),
+		Issuer:      strings.TrimSpace(issuer),
+		SortBy:      strings.TrimSpace(sortBy),
+		GroupBy:     groupBy,
+		Watch:       watch,
+		Compact:     compact,
+		RevealNotes: revealNotes,
+		Private:     private,
+		IdleTimeout: idleTimeout,
+		Output:      a.outputOptions(),
 	}
-	if once {
+	if once || !stdoutIsTerminal() {
 		opts.Watch = false
 	}
+	// A search lists the best matches first unless a sort was asked for.
+	if opts.Search != "" && !cmd.Flags().Changed("sort") {
+		opts.SortBy = "relevance"
+	}
 
 	return showDashboard(a.service(), opts)
 }
@@ -158,6 +234,8 @@ func (a *App) addAccount(cmd *cobra.Command, args []string) error {
 	interval, _ := cmd.Flags().GetInt("interval")
 	digits, _ := cmd.Flags().GetInt("digits")
 	algorithm, _ := cmd.Flags().GetString("algorithm")
+	encodingFlag, _ := cmd.Flags().GetString("encoding")
+	issuer, _ := cmd.Flags().GetString("issuer")
 	otpType, _ := cmd.Flags().GetString("type")
 	counter, _ := cmd.Flags().GetInt64("counter")
 	tags, _ := cmd.Flags().GetStringSlice("tags")
@@ -170,10 +248,18 @@ func (a *App) addAccount(cmd *cobra.Command, args []string) error {
 	if err := trustpin.ValidateDigits(digits); err != nil {
 		return err
 	}
+	encoding, err := trustpin.NormalizeSecretEncoding(encodingFlag)
+	if err != nil {
+		return err
+	}
+	importOpts, err := importOptions(cmd)
+	if err != nil {
+		return err
+	}
 
 	service := a.service()
 	if qrFile != "" {
-		result, err := service.ImportAccountsFromQR(qrFile)
+		result, err := service.ImportAccountsFromQR(qrFile, importOpts)
 		if err != nil {
 			return err
 		}
@@ -185,22 +271,33 @@ func (a *App) addAccount(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	label := ""
+	if strings.TrimSpace(issuer) != "" {
+		label = account
+		account = trustpin.AccountName(issuer, label)
+	}
 	if err := trustpin.ValidateAccountInput(account, secret); err != nil {
 		return err
 	}
+	if err := trustpin.ValidateSecret(secret, encoding); err != nil {
+		return err
+	}
 
-	summary, err := service.UpsertAccounts([]trustpin.Account{{
-		Name:      account,
-		Secret:    secret,
-		Interval:  int64(interval),
-		Digits:    digits,
-		Algorithm: algorithm,
-		Type:      otpType,
-		Counter:   counter,
-		Tags:      tags,
-		Favorite:  favorite,
-		Notes:     notes,
-	}})
+	summary, err := service.ImportAccounts([]trustpin.Account{{
+		Name:           account,
+		Issuer:         issuer,
+		Label:          label,
+		Secret:         secret,
+		SecretEncoding: encoding,
+		Interval:       int64(interval),
+		Digits:         digits,
+		Algorithm:      algorithm,
+		Type:           otpType,
+		Counter:        counter,
+		Tags:           tags,
+		Favorite:       favorite,
+		Notes:          notes,
+	}}, importOpts)
 	if err != nil {
 		return err
 	}
@@ -212,15 +309,111 @@ func (a *App) addAccount(cmd *cobra.Command, args []string) error {
 func (a *App) runInspectCommand(cmd *cobra.Command, args []string) error {
 	watch, _ := cmd.Flags().GetBool("watch")
 	once, _ := cmd.Flags().GetBool("once")
-	if once {
+	private, _ := cmd.Flags().GetBool("private")
+	reveal, _ := cmd.Flags().GetBool("reveal")
+	if once || !stdoutIsTerminal() {
 		watch = false
 	}
 
-	return inspectAccount(a.service(), strings.Join(args, " "), watch)
+	return inspectAccount(a.service(), strings.Join(args, " "), watch, private && !reveal, a.outputOptions())
+}
+
+func (a *App) runCodeCommand(cmd *cobra.Command, args []string) error {
+	next, _ := cmd.Flags().GetBool("next")
+	query := strings.TrimSpace(strings.Join(args, " "))
+	service := a.service()
+
+	account, err := findAccount(service, query)
+	if err != nil {
+		return err
+	}
+	if account.Archived {
+		return fmt.Errorf("%s is archived; restore it before generating codes", account.Name)
+	}
+	if _, _, err := trustpin.GenerateAccountCode(account); err != nil {
+		return exitError{code: ExitInvalidInput, err: fmt.Errorf("%s cannot generate codes: %w", account.Name, err)}
+	}
+
+	if next {
+		account, err = service.AdvanceHOTP(account.ID, time.Now())
+	} else {
+		account, err = service.RecordUse(account.ID, time.Now())
+	}
+	if err != nil {
+		return err
+	}
+
+	snapshot := trustpin.BuildAccountSnapshot(account)
+	if output := a.outputOptions(); output.machine() {
+		return writeAccounts(os.Stdout, []trustpin.AccountSnapshot{snapshot}, output)
+	}
+	fmt.Println(snapshot.OTP)
+	return nil
+}
+
+// findAccount resolves an ID, name, or search terms to exactly one account.
+func findAccount(service trustpin.Service, query string) (trustpin.Account, error) {
+	accounts, err := service.LoadAccounts()
+	if err != nil {
+		return trustpin.Account{}, err
+	}
+	account, suggestions, found, ambiguous := resolveInspectAccount(accounts, query)
+	if ambiguous {
+		return trustpin.Account{}, fmt.Errorf("the query %q is ambiguous; candidates: %s", query, strings.Join(suggestions, ", "))
+	}
+	if !found {
+		return trustpin.Account{}, exitError{code: ExitNotFound, err: fmt.Errorf("no stored account matches %q", query)}
+	}
+	return account, nil
 }
 
 func (a *App) runHealthCommand(cmd *cobra.Command, args []string) error {
-	return showHealthReport(a.service())
+	failOn, _ := cmd.Flags().GetString("fail-on")
+	rulesPath, _ := cmd.Flags().GetString("rules")
+
+	threshold, err := normalizeFailOn(failOn)
+	if err != nil {
+		return err
+	}
+
+	policy, err := trustpin.LoadHealthPolicy(rulesPath)
+	if err != nil {
+		return err
+	}
+
+	report, err := showHealthReport(a.service(), policy, a.outputOptions())
+	if err != nil {
+		return err
+	}
+
+	if threshold != "" {
+		failing := 0
+		for _, item := range report.Items {
+			if trustpin.HealthLevelAtLeast(item.Level, threshold) {
+				failing++
+			}
+		}
+		if failing > 0 {
+			return exitError{
+				code: ExitHealthFailed,
+				err:  fmt.Errorf("health check failed: %d %s at or above %s", failing, pluralize("finding", "findings", failing), threshold),
+			}
+		}
+	}
+	return nil
+}
+
+func normalizeFailOn(value string) (trustpin.HealthLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "none":
+		return "", nil
+	case string(trustpin.HealthLevelCritical):
+		return trustpin.HealthLevelCritical, nil
+	case string(trustpin.HealthLevelWarning):
+		return trustpin.HealthLevelWarning, nil
+	default:
+		return "", fmt.Errorf("unsupported --fail-on %q (use critical or warning)", value)
+	}
 }
 
 func (a *App) runServeCommand(cmd *cobra.Command, args []string) error {
@@ -229,7 +422,40 @@ func (a *App) runServeCommand(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("port must be between 1 and 65535")
 	}
 
-	return webui.Start(a.service(), port)
+	idleTimeout, _ := cmd.Flags().GetDuration("idle-timeout")
+	if idleTimeout < 0 {
+		return fmt.Errorf("--idle-timeout cannot be negative")
+	}
+	token, _ := cmd.Flags().GetString("token")
+	bind, _ := cmd.Flags().GetString("bind")
+	allowRemote, _ := cmd.Flags().GetBool("allow-remote")
+	socket, _ := cmd.Flags().GetString("socket")
+	useTLS, _ := cmd.Flags().GetBool("tls")
+	certFile, _ := cmd.Flags().GetString("tls-cert")
+	keyFile, _ := cmd.Flags().GetString("tls-key")
+	certFile, keyFile = strings.TrimSpace(certFile), strings.TrimSpace(keyFile)
+	if (certFile == "") != (keyFile == "") {
+		return fmt.Errorf("--tls-cert and --tls-key must be used together")
+	}
+
+	locks, stopLocks := watchLockSignals()
+	defer stopLocks()
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return webui.Start(ctx, a.service(), webui.Options{
+		Port:        port,
+		Bind:        strings.TrimSpace(bind),
+		AllowRemote: allowRemote,
+		Socket:      strings.TrimSpace(socket),
+		TLS:         useTLS || certFile != "",
+		TLSCertFile: certFile,
+		TLSKeyFile:  keyFile,
+		TLSDir:      trustpin.DefaultTLSDir(),
+		Token:       strings.TrimSpace(token),
+		IdleTimeout: idleTimeout,
+		Lock:        locks,
+	})
 }
 
 func (a *App) runMigrateCommand(cmd *cobra.Command, args []string) error {
@@ -241,7 +467,12 @@ func (a *App) runMigrateCommand(cmd *cobra.Command, args []string) error {
 		source = args[0]
 	}
 
-	summary, err := service.MigrateLegacyFrom(source, !keepSource)
+	importOpts, err := importOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	summary, err := service.MigrateLegacyFrom(source, !keepSource && !importOpts.DryRun, importOpts)
 	if err != nil {
 		return err
 	}
@@ -255,20 +486,62 @@ func (a *App) runMigrateCommand(cmd *cobra.Command, args []string) error {
 		mutedText("Migrated from " + source),
 		mutedText("Encrypted store " + service.StorePath),
 		"",
-		strings.Join([]string{
-			renderMetricBadge(toneSuccess, fmt.Sprintf("%d added", summary.Added)),
-			renderMetricBadge(toneAccent, fmt.Sprintf("%d replaced", summary.Replaced)),
-		}, " "),
 	}
+	lines = append(lines, upsertSummaryLines(summary, width)...)
 
-	for _, change := range summary.Changes {
-		lines = append(lines, styleTone(map[string]string{"added": toneSuccess, "replaced": toneAccent}[change.Action], strings.ToUpper(change.Action))+"  "+change.Name)
+	fmt.Println(strings.Join(renderPanel(summaryTitle("Legacy migration complete", summary), lines, width), "\n"))
+	return nil
+}
+
+func (a *App) runBatchCommand(cmd *cobra.Command, args []string) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	var input io.Reader = os.Stdin
+	source := "stdin"
+	if args[0] != "-" {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("open batch file: %w", err)
+		}
+		defer file.Close()
+		input = file
+		source = args[0]
+	}
+
+	ops, err := trustpin.ParseBatchOperations(input)
+	if err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+
+	summary, err := a.service().ApplyBatch(ops, trustpin.BatchOptions{DryRun: dryRun})
+	if err != nil {
+		return err
+	}
+	if err := writeBatchSummary(os.Stdout, source, summary, a.outputOptions()); err != nil {
+		return err
 	}
 
-	fmt.Println(strings.Join(renderPanel("Legacy migration complete", lines, width), "\n"))
+	if summary.Failed > 0 {
+		return fmt.Errorf("batch rejected: %d of %d %s failed, nothing was saved", summary.Failed, len(summary.Results), pluralize("operation", "operations", len(summary.Results)))
+	}
 	return nil
 }
 
+func configureImportFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("dry-run", false, "Show the planned changes without saving them")
+	cmd.Flags().String("strategy", string(trustpin.MergeReplace), "How to handle accounts that already exist: replace, skip-existing, keep-both, merge-metadata")
+}
+
+func importOptions(cmd *cobra.Command) (trustpin.ImportOptions, error) {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	value, _ := cmd.Flags().GetString("strategy")
+	strategy, err := trustpin.ParseMergeStrategy(value)
+	if err != nil {
+		return trustpin.ImportOptions{}, err
+	}
+	return trustpin.ImportOptions{Strategy: strategy, DryRun: dryRun}, nil
+}
+
 func (a *App) deleteAccounts(cmd *cobra.Command, args []string) error {
 	force, _ := cmd.Flags().GetBool("force")
 	service := a.service()
@@ -293,19 +566,65 @@ func (a *App) deleteAccounts(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
-	for _, account := range args {
-		removed, err := service.DeleteAccount(account)
+	for _, query := range args {
+		account, err := findAccount(service, query)
+		if err != nil {
+			return err
+		}
+		// Only an ID or the full name deletes without asking; a search
+		// match is confirmed first.
+		exact := strings.EqualFold(account.ID, strings.TrimSpace(query)) || normalizeAccountName(account.Name) == normalizeAccountName(query)
+		if !exact && !force {
+			confirmed, err := confirmPrompt(fmt.Sprintf("Delete %s (matched %q)", account.Name, query))
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				fmt.Printf("Kept %s.\n", account.Name)
+				continue
+			}
+		}
+
+		removed, err := service.DeleteAccount(account.ID)
 		if err != nil {
 			return err
 		}
-		fmt.Printf("Deleted %d %s matching %q.\n", removed, pluralize("account", "accounts", removed), account)
+		fmt.Printf("Deleted %d %s matching %q.\n", removed, pluralize("account", "accounts", removed), query)
 	}
 
 	return nil
 }
 
+func (a *App) configureOutput() error {
+	format, err := normalizeOutputFormat(a.output)
+	if err != nil {
+		return err
+	}
+	a.output = format
+
+	if format != outputTable || !stdoutIsTerminal() {
+		color.NoColor = true
+	}
+	return nil
+}
+
+func (a *App) outputOptions() outputOptions {
+	return outputOptions{Format: a.output, IncludeSecrets: a.includeSecrets}
+}
+
 func (a *App) service() trustpin.Service {
-	return trustpin.NewService(a.storePath)
+	service := trustpin.NewService(a.storePath)
+	service.OnPermissionFix = warnPermissionFix
+	return service
+}
+
+// warnPermissionFix goes to stderr so machine output on stdout stays clean.
+func warnPermissionFix(fix trustpin.PermissionFix) {
+	if fix.Foreign {
+		fmt.Fprintf(os.Stderr, "Warning: %s is owned by uid %d; that user can replace the store or the key.\n", fix.Path, fix.Owner)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s was %04o, readable by other users; changed it to %04o.\n", fix.Path, fix.From, fix.To)
 }
 
 func collectAccountInput(args []string) (string, string, error) {
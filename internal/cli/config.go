@@ -0,0 +1,300 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/milan604/trustPIN/internal/trustpin"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	configFlagName = "config"
+	envPrefix      = "TRUSTPIN_"
+)
+
+// configSections lists the commands whose flags can be preset from the config
+// file. The root command shares the show section because it renders the same
+// dashboard.
+var configSections = []string{"show", "serve", "add", "inspect", "health"}
+
+type configEntry struct {
+	Key   string
+	Flag  *pflag.Flag
+	Value string
+	Src   string
+}
+
+func newConfigCmd(app *App) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:          "config",
+		Short:        "Manage persistent TrustPIN preferences",
+		Long:         "Read and write the TrustPIN config file. Values set here become the defaults for show, serve, add, inspect, and health flags. Precedence is flag > TRUSTPIN_* environment variable > config file > built-in default.",
+		SilenceUsage: true,
+	}
+
+	getCmd := &cobra.Command{
+		Use:          "get <key>",
+		Short:        "Print the effective value of a preference",
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entry, err := app.resolveConfigEntry(cmd.Root(), args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Println(entry.Value)
+			return nil
+		},
+	}
+
+	setCmd := &cobra.Command{
+		Use:          "set <key> <value>",
+		Short:        "Store a preference in the config file",
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.setConfigValue(cmd.Root(), args[0], args[1])
+		},
+	}
+
+	listCmd := &cobra.Command{
+		Use:          "list",
+		Aliases:      []string{"ls"},
+		Short:        "List every preference with its effective value and source",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.listConfig(cmd.Root())
+		},
+	}
+
+	pathCmd := &cobra.Command{
+		Use:          "path",
+		Short:        "Print the config file location",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Println(app.resolvedConfigPath())
+			return nil
+		},
+	}
+
+	configCmd.AddCommand(getCmd, setCmd, listCmd, pathCmd)
+	return configCmd
+}
+
+func (a *App) resolvedConfigPath() string {
+	if strings.TrimSpace(a.configPath) != "" {
+		return a.configPath
+	}
+	if value := strings.TrimSpace(os.Getenv(envPrefix + "CONFIG")); value != "" {
+		return value
+	}
+	return trustpin.DefaultConfigPath()
+}
+
+func (a *App) loadConfig() (trustpin.Config, error) {
+	return trustpin.LoadConfig(a.resolvedConfigPath())
+}
+
+// applyPreferences fills every flag the user did not pass explicitly from the
+// environment or the config file, in that order.
+func (a *App) applyPreferences(cmd *cobra.Command) error {
+	if isConfigCommand(cmd) {
+		return nil
+	}
+
+	config, err := a.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	section := configSection(cmd)
+	var applyErr error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if applyErr != nil || flag.Changed {
+			return
+		}
+
+		key, ok := configKey(cmd, section, flag)
+		if !ok {
+			return
+		}
+
+		value, src := lookupPreference(config, key)
+		if src == "" {
+			return
+		}
+		if err := flag.Value.Set(value); err != nil {
+			applyErr = fmt.Errorf("invalid %s value %q for %s: %w", src, value, key, err)
+		}
+	})
+
+	return applyErr
+}
+
+func (a *App) resolveConfigEntry(root *cobra.Command, key string) (configEntry, error) {
+	entries, err := a.configEntries(root)
+	if err != nil {
+		return configEntry{}, err
+	}
+
+	key = strings.ToLower(strings.TrimSpace(key))
+	for _, entry := range entries {
+		if entry.Key == key {
+			return entry, nil
+		}
+	}
+	return configEntry{}, fmt.Errorf("unknown config key %q (run `trustpin config list` to see supported keys)", key)
+}
+
+func (a *App) setConfigValue(root *cobra.Command, key, value string) error {
+	entry, err := a.resolveConfigEntry(root, key)
+	if err != nil {
+		return err
+	}
+	if err := entry.Flag.Value.Set(value); err != nil {
+		return fmt.Errorf("invalid value %q for %s: %w", value, entry.Key, err)
+	}
+
+	config, err := a.loadConfig()
+	if err != nil {
+		return err
+	}
+	config.Set(entry.Key, value)
+	if err := config.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved %s = %s in %s\n", entry.Key, value, config.Path)
+	return nil
+}
+
+func (a *App) listConfig(root *cobra.Command) error {
+	entries, err := a.configEntries(root)
+	if err != nil {
+		return err
+	}
+
+	keyWidth := 0
+	for _, entry := range entries {
+		keyWidth = max(keyWidth, len(entry.Key))
+	}
+
+	width := min(terminalWidth(), 100)
+	lines := []string{
+		mutedText("Config file " + a.resolvedConfigPath()),
+		mutedText("Precedence: flag > " + envPrefix + "* environment > config file > default"),
+		"",
+	}
+	for _, entry := range entries {
+		tone := toneMuted
+		switch entry.Src {
+		case "config":
+			tone = toneAccent
+		case "env":
+			tone = toneWarning
+		}
+		line := padRight(entry.Key, keyWidth) + "  " + padRight(truncateText(entry.Value, width-keyWidth-20), width-keyWidth-20) + " " + styleTone(tone, entry.Src)
+		lines = append(lines, line)
+	}
+
+	fmt.Println(strings.Join(renderPanel("TrustPIN preferences", lines, width), "\n"))
+	return nil
+}
+
+func (a *App) configEntries(root *cobra.Command) ([]configEntry, error) {
+	config, err := a.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]configEntry, 0)
+	seen := make(map[string]struct{})
+	add := func(key string, flag *pflag.Flag) {
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+
+		value, src := lookupPreference(config, key)
+		if src == "" {
+			value, src = flag.DefValue, "default"
+		}
+		entries = append(entries, configEntry{Key: key, Flag: flag, Value: value, Src: src})
+	}
+
+	root.PersistentFlags().VisitAll(func(flag *pflag.Flag) {
+		if key, ok := configKey(root, "", flag); ok {
+			add(key, flag)
+		}
+	})
+	for _, section := range configSections {
+		cmd, _, err := root.Find([]string{section})
+		if err != nil || cmd == root {
+			continue
+		}
+		cmd.LocalNonPersistentFlags().VisitAll(func(flag *pflag.Flag) {
+			if key, ok := configKey(cmd, section, flag); ok {
+				add(key, flag)
+			}
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return entries, nil
+}
+
+func lookupPreference(config trustpin.Config, key string) (string, string) {
+	if value, ok := os.LookupEnv(preferenceEnvName(key)); ok {
+		return value, "env"
+	}
+	if value, ok := config.Get(key); ok {
+		return value, "config"
+	}
+	return "", ""
+}
+
+func configKey(cmd *cobra.Command, section string, flag *pflag.Flag) (string, bool) {
+	if flag.Name == "help" || flag.Name == configFlagName {
+		return "", false
+	}
+	if cmd.Root().PersistentFlags().Lookup(flag.Name) == flag {
+		return flag.Name, true
+	}
+	if section == "" {
+		return "", false
+	}
+	return section + "." + flag.Name, true
+}
+
+func configSection(cmd *cobra.Command) string {
+	if !cmd.HasParent() {
+		return "show"
+	}
+	for _, section := range configSections {
+		if cmd.Name() == section && cmd.Parent() == cmd.Root() {
+			return section
+		}
+	}
+	return ""
+}
+
+func isConfigCommand(cmd *cobra.Command) bool {
+	for current := cmd; current != nil; current = current.Parent() {
+		if current.Name() == "config" && current.Parent() == current.Root() {
+			return true
+		}
+	}
+	return false
+}
+
+func preferenceEnvName(key string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
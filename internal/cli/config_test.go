@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/milan604/trustPIN/internal/trustpin"
+)
+
+func TestApplyPreferencesPrecedence(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+
+	config := trustpin.Config{Path: configPath}
+	config.Set("show.sort", "name")
+	config.Set("show.compact", "true")
+	config.Set("show.issuer", "GitHub")
+	if err := config.Save(); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+
+	t.Setenv("TRUSTPIN_SHOW_ISSUER", "AWS")
+	t.Setenv("TRUSTPIN_ACCOUNTS_FILE", filepath.Join(tmpDir, "env.enc"))
+
+	root := NewRootCmd(trustpin.NewService(filepath.Join(tmpDir, "accounts.enc")))
+	showCmd, _, err := root.Find([]string{"show"})
+	if err != nil {
+		t.Fatalf("find show: %v", err)
+	}
+	if err := showCmd.ParseFlags([]string{"--config", configPath, "--compact=false"}); err != nil {
+		t.Fatalf("parse flags: %v", err)
+	}
+	if err := root.PersistentPreRunE(showCmd, nil); err != nil {
+		t.Fatalf("apply preferences: %v", err)
+	}
+
+	sortBy, _ := showCmd.Flags().GetString("sort")
+	compact, _ := showCmd.Flags().GetBool("compact")
+	issuer, _ := showCmd.Flags().GetString("issuer")
+	storePath, _ := showCmd.Flags().GetString("accounts-file")
+
+	if sortBy != "name" {
+		t.Fatalf("expected config value for sort, got %q", sortBy)
+	}
+	if compact {
+		t.Fatalf("expected explicit flag to override config")
+	}
+	if issuer != "AWS" {
+		t.Fatalf("expected env value to override config, got %q", issuer)
+	}
+	if storePath != filepath.Join(tmpDir, "env.enc") {
+		t.Fatalf("expected env store path, got %q", storePath)
+	}
+}
+
+func TestConfigRoundTripsNestedKeys(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	config := trustpin.Config{Path: configPath}
+	config.Set("serve.port", "9000")
+	config.Set("accounts-file", "/tmp/store.enc")
+	if err := config.Save(); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+
+	loaded, err := trustpin.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if value, _ := loaded.Get("serve.port"); value != "9000" {
+		t.Fatalf("unexpected serve.port %q", value)
+	}
+	if value, _ := loaded.Get("accounts-file"); value != "/tmp/store.enc" {
+		t.Fatalf("unexpected accounts-file %q", value)
+	}
+}
@@ -2,6 +2,8 @@ package cli
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"sort"
 	"strings"
 	"time"
@@ -30,11 +32,17 @@ var (
 )
 
 type showOptions struct {
-	Search  string
-	Issuer  string
-	SortBy  string
-	Watch   bool
-	Compact bool
+	Search      string
+	Issuer      string
+	SortBy      string
+	GroupBy     string
+	Watch       bool
+	Compact     bool
+	RevealNotes bool
+	Private     bool
+	RevealID    string
+	IdleTimeout time.Duration
+	Output      outputOptions
 }
 
 type accountViewModel struct {
@@ -55,8 +63,15 @@ type accountViewModel struct {
 	Tags            []string
 	Favorite        bool
 	Notes           string
+	RecoveryCodes   int
+	RecoveryUnused  int
+	RevealNotes     bool
+	Private         bool
+	Index           int
 	Algorithm       string
 	Type            string
+	BrandColor      string
+	Score           int
 }
 
 type dashboardStats struct {
@@ -74,23 +89,78 @@ func showDashboard(service trustpin.Service, opts showOptions) error {
 		return err
 	}
 	opts.SortBy = sortBy
+	if opts.GroupBy, err = trustpin.NormalizeGroupBy(opts.GroupBy); err != nil {
+		return err
+	}
+
+	if opts.Output.machine() {
+		return writeDashboardData(service, opts)
+	}
 
 	if !opts.Watch {
-		return renderDashboardFrame(service, opts)
+		_, err := renderDashboardFrame(service, opts)
+		return err
+	}
+
+	keys, restore := watchKeys()
+	defer restore()
+	interrupts, stopInterrupts := watchInterrupts()
+	defer stopInterrupts()
+	locks, stopLocks := watchLockSignals()
+	defer stopLocks()
+
+	// Without key input nothing could unlock the view, so idle locking is off.
+	idleTimeout := opts.IdleTimeout
+	if keys == nil {
+		idleTimeout = 0
 	}
+	idle := newIdleLock(idleTimeout, time.Now())
 
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+	var views []accountViewModel
+	var revealUntil time.Time
 	for {
-		if err := renderDashboardFrame(service, opts); err != nil {
-			return err
+		if opts.RevealID != "" && time.Now().After(revealUntil) {
+			opts.RevealID = ""
+		}
+		if idle.update(time.Now()) {
+			clearScreen()
+			fmt.Print(renderLockScreen(idle.reason))
+		} else {
+			views, err = renderDashboardFrame(service, opts)
+			if err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-interrupts:
+			return nil
+		case <-locks:
+			idle.lock("Locked by a lock signal.")
+			opts.RevealID = ""
+		case key := <-keys:
+			if idle.touch(time.Now()) {
+				continue
+			}
+			if key == 'q' {
+				return nil
+			}
+			opts.RevealID = ""
+			if idx := int(key - '1'); opts.Private && key >= '1' && key <= '9' && idx < len(views) {
+				opts.RevealID = views[idx].Account.ID
+				revealUntil = time.Now().Add(privateRevealDuration)
+			}
 		}
-		time.Sleep(1 * time.Second)
 	}
 }
 
-func renderDashboardFrame(service trustpin.Service, opts showOptions) error {
+func renderDashboardFrame(service trustpin.Service, opts showOptions) ([]accountViewModel, error) {
 	accounts, err := service.LoadAccounts()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	viewModels, stats := buildDashboardView(accounts, opts)
@@ -100,28 +170,49 @@ func renderDashboardFrame(service trustpin.Service, opts showOptions) error {
 	}
 
 	fmt.Print(output)
-	return nil
+	return viewModels, nil
 }
 
-func inspectAccount(service trustpin.Service, query string, watch bool) error {
+func writeDashboardData(service trustpin.Service, opts showOptions) error {
+	accounts, err := service.LoadAccounts()
+	if err != nil {
+		return err
+	}
+
+	views, _ := buildDashboardView(accounts, opts)
+	snapshots := make([]trustpin.AccountSnapshot, 0, len(views))
+	for _, view := range views {
+		snapshot := trustpin.BuildAccountSnapshot(view.Account)
+		snapshot.Score = view.Score
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return writeAccounts(os.Stdout, snapshots, opts.Output)
+}
+
+func inspectAccount(service trustpin.Service, query string, watch, private bool, output outputOptions) error {
 	query = strings.TrimSpace(query)
 	if query == "" {
 		return fmt.Errorf("account query cannot be empty")
 	}
 
+	if output.machine() {
+		return writeInspectData(service, query, output)
+	}
+
 	if !watch {
-		return renderInspectFrame(service, query, false)
+		return renderInspectFrame(service, query, false, private)
 	}
 
 	for {
-		if err := renderInspectFrame(service, query, true); err != nil {
+		if err := renderInspectFrame(service, query, true, private); err != nil {
 			return err
 		}
 		time.Sleep(1 * time.Second)
 	}
 }
 
-func renderInspectFrame(service trustpin.Service, query string, clear bool) error {
+func renderInspectFrame(service trustpin.Service, query string, clear, private bool) error {
 	accounts, err := service.LoadAccounts()
 	if err != nil {
 		return err
@@ -137,18 +228,42 @@ func renderInspectFrame(service trustpin.Service, query string, clear bool) erro
 	}
 
 	view := buildAccountViewModel(account)
+	if private {
+		view.mask()
+	}
 	fmt.Print(renderInspectView(view, clear))
 	return nil
 }
 
-func showHealthReport(service trustpin.Service) error {
-	report, err := service.HealthReport()
+func writeInspectData(service trustpin.Service, query string, output outputOptions) error {
+	accounts, err := service.LoadAccounts()
 	if err != nil {
 		return err
 	}
 
+	account, suggestions, found, ambiguous := resolveInspectAccount(accounts, query)
+	if ambiguous {
+		return fmt.Errorf("the query %q is ambiguous; candidates: %s", query, strings.Join(suggestions, ", "))
+	}
+	if !found {
+		return exitError{code: ExitNotFound, err: fmt.Errorf("no stored account matches %q", query)}
+	}
+
+	return writeAccounts(os.Stdout, []trustpin.AccountSnapshot{trustpin.BuildAccountSnapshot(account)}, output)
+}
+
+func showHealthReport(service trustpin.Service, policy trustpin.HealthPolicy, output outputOptions) (trustpin.HealthReport, error) {
+	report, err := service.HealthReportWithPolicy(policy)
+	if err != nil {
+		return trustpin.HealthReport{}, err
+	}
+
+	if output.machine() {
+		return report, writeHealthReport(os.Stdout, report, output.Format)
+	}
+
 	fmt.Print(renderHealthReport(report))
-	return nil
+	return report, nil
 }
 
 func buildDashboardView(accounts []trustpin.Account, opts showOptions) ([]accountViewModel, dashboardStats) {
@@ -162,7 +277,7 @@ func buildDashboardView(accounts []trustpin.Account, opts showOptions) ([]accoun
 			continue
 		}
 		account = sanitizeAccount(account)
-		issuer, _, hasIssuer := trustpin.SplitAccountName(account.Name)
+		issuer, _, hasIssuer := trustpin.AccountIssuer(account)
 		group := "ungrouped"
 		if hasIssuer {
 			group = strings.ToLower(issuer)
@@ -173,11 +288,14 @@ func buildDashboardView(accounts []trustpin.Account, opts showOptions) ([]accoun
 			customPolicies++
 		}
 
-		if !matchesAccountFilters(account, opts) {
+		score, ok := matchesAccountFilters(account, opts)
+		if !ok {
 			continue
 		}
 
 		view := buildAccountViewModel(account)
+		view.Score = score
+		view.RevealNotes = opts.RevealNotes
 		if view.ErrorText == "" && view.TimeRemaining <= 5 {
 			expiringSoon++
 		}
@@ -185,6 +303,17 @@ func buildDashboardView(accounts []trustpin.Account, opts showOptions) ([]accoun
 	}
 
 	sortViewModels(views, opts.SortBy)
+	if opts.GroupBy != "" {
+		views = groupOrder(views, opts.GroupBy)
+	}
+	if opts.Private {
+		for i := range views {
+			views[i].Index = i + 1
+			if views[i].Account.ID != opts.RevealID {
+				views[i].mask()
+			}
+		}
+	}
 	audit := trustpin.SummarizeHealth(trustpin.AnalyzeAccounts(accounts))
 
 	return views, dashboardStats{
@@ -197,6 +326,32 @@ func buildDashboardView(accounts []trustpin.Account, opts showOptions) ([]accoun
 	}
 }
 
+// groupOrder puts views in the order the grouped dashboard draws them, so
+// the private-mode indexes follow the screen. An account listed under
+// several tags keeps its first place.
+func groupOrder(views []accountViewModel, groupBy string) []accountViewModel {
+	positions := make([]int, len(views))
+	for i := range positions {
+		positions[i] = i
+	}
+
+	ordered := make([]accountViewModel, 0, len(views))
+	seen := make([]bool, len(views))
+	for _, group := range trustpin.GroupAccounts(positions, groupBy, func(i int) trustpin.Account { return views[i].Account }) {
+		for _, i := range group.Accounts {
+			if !seen[i] {
+				seen[i] = true
+				ordered = append(ordered, views[i])
+			}
+		}
+	}
+	return ordered
+}
+
+func viewAccount(view accountViewModel) trustpin.Account {
+	return view.Account
+}
+
 func buildAccountViewModel(account trustpin.Account) accountViewModel {
 	snapshot := trustpin.BuildAccountSnapshot(account)
 	return accountViewModel{
@@ -217,32 +372,31 @@ func buildAccountViewModel(account trustpin.Account) accountViewModel {
 		Tags:            snapshot.Tags,
 		Favorite:        snapshot.Favorite,
 		Notes:           snapshot.Notes,
+		RecoveryCodes:   snapshot.RecoveryCodes,
+		RecoveryUnused:  snapshot.RecoveryUnused,
 		Algorithm:       snapshot.Algorithm,
 		Type:            snapshot.Type,
+		BrandColor:      snapshot.BrandColor,
 	}
 }
 
-func matchesAccountFilters(account trustpin.Account, opts showOptions) bool {
-	issuer, label, _ := trustpin.SplitAccountName(account.Name)
-	haystack := strings.ToLower(strings.Join([]string{account.Name, issuer, label}, " "))
-
-	if opts.Search != "" && !containsAllTerms(haystack, strings.Fields(strings.ToLower(opts.Search))) {
-		return false
-	}
-	if opts.Issuer != "" && !strings.Contains(strings.ToLower(issuer), strings.ToLower(opts.Issuer)) {
-		return false
+// matchesAccountFilters reports whether account passes the dashboard
+// filters and, for a search, how well it matched.
+func matchesAccountFilters(account trustpin.Account, opts showOptions) (int, bool) {
+	score := 0
+	if opts.Search != "" {
+		if score = trustpin.ScoreAccount(account, opts.Search); score == 0 {
+			return 0, false
+		}
 	}
-
-	return true
-}
-
-func containsAllTerms(haystack string, terms []string) bool {
-	for _, term := range terms {
-		if !strings.Contains(haystack, term) {
-			return false
+	if opts.Issuer != "" {
+		issuer, _, _ := trustpin.AccountIssuer(account)
+		if !strings.Contains(strings.ToLower(issuer), strings.ToLower(opts.Issuer)) {
+			return 0, false
 		}
 	}
-	return true
+
+	return score, true
 }
 
 func normalizeSort(value string) (string, error) {
@@ -255,8 +409,14 @@ func normalizeSort(value string) (string, error) {
 		return "issuer", nil
 	case "digits":
 		return "digits", nil
+	case "recent":
+		return "recent", nil
+	case "added":
+		return "added", nil
+	case "relevance":
+		return "relevance", nil
 	default:
-		return "", fmt.Errorf("unsupported sort %q (use expiry, name, issuer, or digits)", value)
+		return "", fmt.Errorf("unsupported sort %q (use expiry, name, issuer, digits, recent, added, or relevance)", value)
 	}
 }
 
@@ -265,6 +425,11 @@ func sortViewModels(accounts []accountViewModel, sortBy string) {
 		left := accounts[i]
 		right := accounts[j]
 
+		// Search scores already include a boost for favorites
+		if sortBy == "relevance" && left.Score != right.Score {
+			return left.Score > right.Score
+		}
+
 		// Favorites always come first, and stay in stable name order among themselves
 		if left.Favorite != right.Favorite {
 			return left.Favorite
@@ -286,6 +451,16 @@ func sortViewModels(accounts []accountViewModel, sortBy string) {
 				return normalizeAccountName(left.FullName) < normalizeAccountName(right.FullName)
 			}
 			return left.Account.Digits < right.Account.Digits
+		case "recent":
+			if !left.Account.LastUsedAt.Equal(right.Account.LastUsedAt) {
+				return left.Account.LastUsedAt.After(right.Account.LastUsedAt)
+			}
+			return normalizeAccountName(left.FullName) < normalizeAccountName(right.FullName)
+		case "added":
+			if !left.Account.CreatedAt.Equal(right.Account.CreatedAt) {
+				return left.Account.CreatedAt.After(right.Account.CreatedAt)
+			}
+			return normalizeAccountName(left.FullName) < normalizeAccountName(right.FullName)
 		default:
 			if left.TimeRemaining == right.TimeRemaining {
 				return normalizeAccountName(left.FullName) < normalizeAccountName(right.FullName)
@@ -322,7 +497,17 @@ func renderDashboard(accounts []accountViewModel, stats dashboardStats, opts sho
 		filterParts = append(filterParts, "issuer "+opts.Issuer)
 	}
 	filterParts = append(filterParts, "sort "+opts.SortBy)
+	if opts.GroupBy != "" {
+		filterParts = append(filterParts, "group "+opts.GroupBy)
+	}
 	filterParts = append(filterParts, map[bool]string{true: "layout compact", false: "layout cards"}[opts.Compact])
+	if opts.Private {
+		hint := "private"
+		if opts.Watch {
+			hint = "private: press 1-9 to reveal an account, any other key to hide"
+		}
+		filterParts = append(filterParts, hint)
+	}
 	headerLines = append(headerLines, mutedText(strings.Join(filterParts, " | ")))
 	headerLines = append(headerLines, mutedText("Storage "+accountFile+" | "+map[bool]string{true: "Ctrl+C exits watch mode", false: "Use --watch to keep the dashboard live"}[opts.Watch]+" | trustpin inspect <account> opens a focused view"))
 
@@ -346,10 +531,20 @@ func renderDashboard(accounts []accountViewModel, stats dashboardStats, opts sho
 		return strings.Join(lines, "\n") + "\n"
 	}
 
+	render := renderCardGrid
 	if opts.Compact || width < 96 {
-		lines = append(lines, renderCompactList(accounts, min(width, 116))...)
+		render = renderCompactList
+	}
+	if opts.GroupBy == "" {
+		lines = append(lines, render(accounts, min(width, 116))...)
 	} else {
-		lines = append(lines, renderCardGrid(accounts, min(width, 116))...)
+		for i, group := range trustpin.GroupAccounts(accounts, opts.GroupBy, viewAccount) {
+			if i > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, renderGroupHeader(group.Label, group.Count, min(width, 116)), "")
+			lines = append(lines, render(group.Accounts, min(width, 116))...)
+		}
 	}
 
 	lines = append(lines, "")
@@ -358,6 +553,11 @@ func renderDashboard(accounts []accountViewModel, stats dashboardStats, opts sho
 	return strings.Join(lines, "\n") + "\n"
 }
 
+func renderGroupHeader(label string, count, width int) string {
+	title := headingText(truncateText(label, width/2)) + " " + mutedText(fmt.Sprintf("%d %s", count, pluralize("account", "accounts", count)))
+	return title + " " + borderText(strings.Repeat("-", max(width-visibleLen(title)-1, 0)))
+}
+
 func renderHealthReport(report trustpin.HealthReport) string {
 	width := min(terminalWidth(), 100)
 	lines := []string{
@@ -392,8 +592,17 @@ func renderHealthReport(report trustpin.HealthReport) string {
 
 	for _, item := range report.Items {
 		lines = append(lines, "")
-		lines = append(lines, styleHealthHeading(item.Level, item.Title))
+		lines = append(lines, alignLine(styleHealthHeading(item.Level, item.Title), mutedText(item.Rule), width-4))
 		lines = append(lines, wrapText(item.Detail, width-4)...)
+		if item.Remediation != "" {
+			for _, line := range wrapText("Fix: "+item.Remediation, width-4) {
+				lines = append(lines, mutedText(line))
+			}
+		}
+	}
+
+	if report.Suppressed > 0 {
+		lines = append(lines, "", mutedText(fmt.Sprintf("%d %s suppressed by health rules.", report.Suppressed, pluralize("finding", "findings", report.Suppressed))))
 	}
 
 	return strings.Join(renderPanel("Workspace status", lines, width), "\n") + "\n"
@@ -412,9 +621,9 @@ func renderCompactList(accounts []accountViewModel, width int) []string {
 	}
 
 	for _, account := range accounts {
-		favPrefix := ""
+		favPrefix := account.indexPrefix()
 		if account.Favorite {
-			favPrefix = "★ "
+			favPrefix += "★ "
 		}
 		left := truncateText(favPrefix+account.FullName, accountWidth)
 		otp := truncateText(account.FormattedOTP, 10)
@@ -485,14 +694,14 @@ func renderAccountCard(account accountViewModel, width int) []string {
 		timerText = fmt.Sprintf("C:%d", account.Account.Counter)
 	}
 
-	favPrefix := ""
+	favPrefix := account.indexPrefix()
 	if account.Favorite {
-		favPrefix = "★ "
+		favPrefix += "★ "
 	}
 
 	lines := []string{
 		alignLine(headingText(truncateText(favPrefix+account.DisplayName, inner-10)), styleTone(account.Tone, timerText), inner),
-		mutedText(truncateText("issuer "+account.Issuer+" | "+account.PolicyLabel, inner)),
+		account.issuerLine(inner),
 		"",
 		styleTone(account.Tone, account.FormattedOTP),
 		alignLine(mutedText("status "+strings.ToLower(account.StatusLabel)), mutedText(account.FullName), inner),
@@ -505,12 +714,22 @@ func renderAccountCard(account accountViewModel, width int) []string {
 	if len(account.Tags) > 0 {
 		lines = append(lines, mutedText("tags   "+strings.Join(account.Tags, ", ")))
 	}
+	for _, line := range account.vaultLines() {
+		lines = append(lines, mutedText(truncateText(line, inner)))
+	}
 
 	lines = append(lines, styleTone(map[bool]string{true: toneDanger, false: toneMuted}[account.ErrorText != ""], truncateText(account.noteLine(), inner)))
 
 	return renderPanel("", lines, width)
 }
 
+// issuerLine shows the issuer in its brand color next to the OTP policy.
+func (account accountViewModel) issuerLine(width int) string {
+	issuer := truncateText(account.Issuer, max(width/2, 8))
+	policy := truncateText("| "+account.PolicyLabel, width-visibleLen("issuer "+issuer+" "))
+	return mutedText("issuer ") + issuerBadge(issuer, account.BrandColor) + " " + mutedText(policy)
+}
+
 func (account accountViewModel) noteLine() string {
 	if account.ErrorText != "" {
 		return account.ErrorText
@@ -518,6 +737,44 @@ func (account accountViewModel) noteLine() string {
 	return "secret " + account.SecretPreview
 }
 
+// vaultLines summarizes notes and recovery codes. Their contents stay hidden
+// unless the dashboard was started with --reveal-notes.
+func (account accountViewModel) vaultLines() []string {
+	if account.Notes == "" && account.RecoveryCodes == 0 {
+		return nil
+	}
+
+	recovery := ""
+	if account.RecoveryCodes > 0 {
+		recovery = fmt.Sprintf("%d of %d recovery codes unused", account.RecoveryUnused, account.RecoveryCodes)
+	}
+	if !account.RevealNotes || account.Private {
+		parts := make([]string, 0, 2)
+		if account.Notes != "" {
+			parts = append(parts, "notes hidden")
+		}
+		if recovery != "" {
+			parts = append(parts, recovery)
+		}
+		return []string{"vault  " + strings.Join(parts, " | ")}
+	}
+
+	lines := make([]string, 0, 2)
+	if account.Notes != "" {
+		lines = append(lines, "notes  "+strings.Join(strings.Fields(account.Notes), " "))
+	}
+	if recovery != "" {
+		unused := make([]string, 0, account.RecoveryUnused)
+		for _, code := range account.Account.RecoveryCodes {
+			if !code.Used {
+				unused = append(unused, code.Code)
+			}
+		}
+		lines = append(lines, "codes  "+strings.Join(unused, ", "))
+	}
+	return lines
+}
+
 func renderInspectView(account accountViewModel, live bool) string {
 	width := min(terminalWidth(), 96)
 	modeLabel := "Focused snapshot for a single account."
@@ -535,60 +792,111 @@ func renderInspectView(account accountViewModel, live bool) string {
 		mutedText(modeLabel),
 		"",
 		alignLine(headingText(account.FullName), styleTone(account.Tone, timer), width-4),
-		mutedText("issuer " + account.Issuer + " | " + account.PolicyLabel),
+		account.issuerLine(width - 4),
 		"",
 		styleTone(account.Tone, account.FormattedOTP),
 		mutedText("status " + strings.ToLower(account.StatusLabel)),
 		mutedText("cycle  " + account.ProgressBar + fmt.Sprintf("  %d%%", account.ProgressPercent)),
-		mutedText("secret " + account.SecretPreview),
+	}
+	now := time.Now()
+	if timeline := account.timelineLines(now); len(timeline) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, timeline...)
+		lines = append(lines, "")
+	}
+	lines = append(lines, mutedText("secret "+account.SecretPreview))
+	if account.Account.ID != "" {
+		lines = append(lines, mutedText("id     "+account.Account.ID))
+	}
+	lines = append(lines,
+		mutedText("added  "+formatTimestamp(account.Account.CreatedAt, now, "before timestamps were recorded")),
+		mutedText("edited "+formatTimestamp(account.Account.UpdatedAt, now, "not since timestamps were recorded")),
+		mutedText("used   "+formatTimestamp(account.Account.LastUsedAt, now, "never")),
+	)
+	if account.RecoveryCodes > 0 {
+		lines = append(lines, mutedText(fmt.Sprintf("codes  %d of %d recovery codes unused", account.RecoveryUnused, account.RecoveryCodes)))
 	}
 
 	if account.ErrorText != "" {
 		lines = append(lines, dangerText(account.ErrorText))
 	}
 	lines = append(lines, "")
+	if account.Private {
+		lines = append(lines, mutedText("Private mode: run `trustpin inspect --reveal` to show the code."))
+	}
 	lines = append(lines, mutedText("Use `trustpin show` to return to the full dashboard."))
 
 	return strings.Join(renderPanel("Account view", lines, width), "\n") + "\n"
 }
 
-func resolveInspectAccount(accounts []trustpin.Account, query string) (trustpin.Account, []string, bool, bool) {
-	query = normalizeAccountName(query)
-	if query == "" {
-		return trustpin.Account{}, nil, false, false
+// timelineLines lists the previous, current and next codes with the
+// windows they are valid in, so a code about to expire can be typed ahead.
+// The next code is highlighted for its last five seconds. Archived and HOTP
+// accounts have no timeline.
+func (account accountViewModel) timelineLines(now time.Time) []string {
+	if account.ErrorText != "" || account.Account.Archived || account.Account.Type == trustpin.TypeHOTP {
+		return nil
+	}
+	windows, err := trustpin.AccountCodeWindows(account.Account, now, 1, 1)
+	if err != nil || len(windows) != 3 {
+		return nil
 	}
 
-	exactMatches := make([]trustpin.Account, 0)
-	partialMatches := make([]trustpin.Account, 0)
-
-	for _, account := range accounts {
-		full := normalizeAccountName(account.Name)
-		issuer, label, _ := trustpin.SplitAccountName(account.Name)
-		labelKey := normalizeAccountName(label)
-		issuerKey := normalizeAccountName(issuer)
-
-		if full == query || labelKey == query || issuerKey == query {
-			exactMatches = append(exactMatches, account)
-			continue
+	labels := []string{"prev   ", "now    ", "next   "}
+	lines := make([]string, 0, len(windows))
+	for i, window := range windows {
+		code := window.Code
+		if account.Account.Type != trustpin.TypeSteam {
+			code = trustpin.FormatOTP(code)
 		}
-
-		haystack := strings.Join([]string{full, labelKey, issuerKey}, " ")
-		if containsAllTerms(haystack, strings.Fields(query)) {
-			partialMatches = append(partialMatches, account)
+		if account.Private {
+			code = maskOTP(code)
+		}
+		span := window.Start.Format("15:04:05") + " - " + window.End.Format("15:04:05")
+
+		switch i {
+		case 1:
+			lines = append(lines, mutedText(labels[i])+styleTone(account.Tone, fmt.Sprintf("%-10s", code))+" "+mutedText(span))
+		case 2:
+			wait := window.Start.Sub(now).Round(time.Second)
+			if wait < 0 {
+				wait = 0
+			}
+			line := labels[i] + fmt.Sprintf("%-10s %s  in %s", code, span, wait)
+			if account.TimeRemaining <= 5 {
+				lines = append(lines, accentText(line))
+			} else {
+				lines = append(lines, mutedText(line))
+			}
+		default:
+			lines = append(lines, mutedText(labels[i]+fmt.Sprintf("%-10s %s", code, span)))
 		}
 	}
+	return lines
+}
 
-	if len(exactMatches) == 1 {
-		return exactMatches[0], nil, true, false
+// resolveInspectAccount picks the account query refers to: the one with that
+// ID or full name, else a search match that clearly outranks the rest.
+// Otherwise it returns the best candidates, or some stored names when nothing
+// matched at all.
+func resolveInspectAccount(accounts []trustpin.Account, query string) (trustpin.Account, []string, bool, bool) {
+	if strings.TrimSpace(query) == "" {
+		return trustpin.Account{}, nil, false, false
 	}
-	if len(exactMatches) > 1 {
-		return trustpin.Account{}, extractAccountNames(exactMatches), false, true
+	if i := trustpin.FindAccountIndex(accounts, query); i >= 0 {
+		return accounts[i], nil, true, false
 	}
-	if len(partialMatches) == 1 {
-		return partialMatches[0], nil, true, false
+
+	matches := trustpin.SearchAccounts(accounts, query)
+	if winner, ok := trustpin.ClearWinner(matches); ok {
+		return winner.Account, nil, true, false
 	}
-	if len(partialMatches) > 1 {
-		return trustpin.Account{}, extractAccountNames(partialMatches), false, true
+	if len(matches) > 0 {
+		names := make([]string, 0, min(len(matches), 6))
+		for _, match := range matches[:min(len(matches), 6)] {
+			names = append(names, match.Account.Name)
+		}
+		return trustpin.Account{}, names, false, true
 	}
 
 	return trustpin.Account{}, extractAccountNames(accounts), false, false
@@ -660,6 +968,19 @@ func styleHealthHeading(level trustpin.HealthLevel, value string) string {
 	}
 }
 
+// issuerBadge draws value in a brand color. Colors too dark to read on a dark
+// terminal are lightened halfway to white.
+func issuerBadge(value, brandColor string) string {
+	r, g, b, ok := trustpin.ParseColor(brandColor)
+	if !ok {
+		return mutedText(value)
+	}
+	if 299*r+587*g+114*b < 80000 {
+		r, g, b = (r+255)/2, (g+255)/2, (b+255)/2
+	}
+	return color.RGB(r, g, b).Add(color.Bold).Sprint(value)
+}
+
 func styleTone(tone, value string) string {
 	switch tone {
 	case toneSuccess:
@@ -696,18 +1017,7 @@ func renderPanel(title string, lines []string, width int) []string {
 
 func printUpsertSummary(title string, summary trustpin.UpsertSummary) {
 	width := min(terminalWidth(), 88)
-	lines := []string{
-		strings.Join([]string{
-			renderMetricBadge(toneSuccess, fmt.Sprintf("%d added", summary.Added)),
-			renderMetricBadge(toneAccent, fmt.Sprintf("%d replaced", summary.Replaced)),
-		}, " "),
-	}
-
-	for _, change := range summary.Changes {
-		lines = append(lines, styleTone(map[string]string{"added": toneSuccess, "replaced": toneAccent}[change.Action], strings.ToUpper(change.Action))+"  "+change.Name)
-	}
-
-	fmt.Println(strings.Join(renderPanel(title, lines, width), "\n"))
+	fmt.Println(strings.Join(renderPanel(summaryTitle(title, summary), upsertSummaryLines(summary, width), width), "\n"))
 }
 
 func printImportSummary(source string, summary trustpin.UpsertSummary, skipped []string) {
@@ -715,16 +1025,8 @@ func printImportSummary(source string, summary trustpin.UpsertSummary, skipped [
 	lines := []string{
 		mutedText("Imported from " + source),
 		"",
-		strings.Join([]string{
-			renderMetricBadge(toneSuccess, fmt.Sprintf("%d added", summary.Added)),
-			renderMetricBadge(toneAccent, fmt.Sprintf("%d replaced", summary.Replaced)),
-			renderMetricBadge(toneWarning, fmt.Sprintf("%d skipped", len(skipped))),
-		}, " "),
-	}
-
-	for _, change := range summary.Changes {
-		lines = append(lines, styleTone(map[string]string{"added": toneSuccess, "replaced": toneAccent}[change.Action], strings.ToUpper(change.Action))+"  "+change.Name)
 	}
+	lines = append(lines, upsertSummaryLines(summary, width, renderMetricBadge(toneWarning, fmt.Sprintf("%d skipped", len(skipped))))...)
 
 	if len(skipped) > 0 {
 		lines = append(lines, "")
@@ -734,7 +1036,114 @@ func printImportSummary(source string, summary trustpin.UpsertSummary, skipped [
 		}
 	}
 
-	fmt.Println(strings.Join(renderPanel("QR import complete", lines, width), "\n"))
+	fmt.Println(strings.Join(renderPanel(summaryTitle("QR import complete", summary), lines, width), "\n"))
+}
+
+func summaryTitle(title string, summary trustpin.UpsertSummary) string {
+	if summary.DryRun {
+		return "Import plan (dry run)"
+	}
+	return title
+}
+
+var changeTones = map[string]string{
+	trustpin.ChangeAdded:    toneSuccess,
+	trustpin.ChangeReplaced: toneAccent,
+	trustpin.ChangeMerged:   toneAccent,
+	trustpin.ChangeKept:     toneMuted,
+}
+
+// upsertSummaryLines renders the counts badge row, ending with any extra
+// badges, followed by one line per change and an indented line per changed
+// field.
+func upsertSummaryLines(summary trustpin.UpsertSummary, width int, extra ...string) []string {
+	badges := []string{
+		renderMetricBadge(toneSuccess, fmt.Sprintf("%d added", summary.Added)),
+		renderMetricBadge(toneAccent, fmt.Sprintf("%d replaced", summary.Replaced)),
+	}
+	if summary.Merged > 0 {
+		badges = append(badges, renderMetricBadge(toneAccent, fmt.Sprintf("%d merged", summary.Merged)))
+	}
+	if summary.Kept > 0 {
+		badges = append(badges, renderMetricBadge(toneMuted, fmt.Sprintf("%d kept", summary.Kept)))
+	}
+	badges = append(badges, extra...)
+	lines := []string{strings.Join(badges, " ")}
+
+	for _, change := range summary.Changes {
+		line := styleTone(changeTones[change.Action], strings.ToUpper(change.Action)) + "  " + change.Name
+		if change.Previous != "" {
+			line += mutedText(" (was " + change.Previous + ")")
+		} else if change.MatchedBy == "secret" {
+			line += mutedText(" (same secret)")
+		}
+		lines = append(lines, line)
+		for _, field := range change.Fields {
+			lines = append(lines, mutedText(truncateText(fmt.Sprintf("    %s: %q -> %q", field.Field, field.Old, field.New), width-4)))
+		}
+	}
+
+	if summary.DryRun {
+		lines = append(lines, "", mutedText("Dry run: nothing was saved. Re-run without --dry-run to apply."))
+	}
+	return lines
+}
+
+func writeBatchSummary(w io.Writer, source string, summary trustpin.BatchSummary, output outputOptions) error {
+	switch output.Format {
+	case outputJSON:
+		return writeJSONOutput(w, summary)
+	case outputYAML:
+		return writeYAMLOutput(w, summary)
+	case "", outputTable:
+		width := min(terminalWidth(), 92)
+		_, err := fmt.Fprintln(w, strings.Join(renderPanel(batchSummaryTitle(summary), batchSummaryLines(source, summary, width), width), "\n"))
+		return err
+	default:
+		return fmt.Errorf("output %q is not supported by the batch command", output.Format)
+	}
+}
+
+func batchSummaryTitle(summary trustpin.BatchSummary) string {
+	switch {
+	case summary.Failed > 0:
+		return "Batch rejected"
+	case summary.DryRun:
+		return "Batch plan (dry run)"
+	default:
+		return "Batch applied"
+	}
+}
+
+func batchSummaryLines(source string, summary trustpin.BatchSummary, width int) []string {
+	lines := []string{
+		mutedText("Operations from " + source),
+		"",
+		renderMetricBadge(toneSuccess, fmt.Sprintf("%d ok", summary.Succeeded)) + " " +
+			renderMetricBadge(toneDanger, fmt.Sprintf("%d failed", summary.Failed)),
+	}
+
+	for _, result := range summary.Results {
+		target := result.Name
+		if target == "" {
+			target = result.ID
+		}
+		line := fmt.Sprintf("%3d  %s  %s", result.Index+1, strings.ToUpper(result.Op), target)
+		if result.Status == trustpin.BatchStatusFailed {
+			lines = append(lines, styleTone(toneDanger, truncateText(line, width-4)))
+			lines = append(lines, mutedText(truncateText("     "+result.Error, width-4)))
+			continue
+		}
+		lines = append(lines, truncateText(line, width-4))
+	}
+
+	switch {
+	case summary.Failed > 0:
+		lines = append(lines, "", mutedText("Nothing was saved. Fix the failed operations and run the batch again."))
+	case summary.DryRun:
+		lines = append(lines, "", mutedText("Dry run: nothing was saved. Re-run without --dry-run to apply."))
+	}
+	return lines
 }
 
 func sanitizeAccount(account trustpin.Account) trustpin.Account {
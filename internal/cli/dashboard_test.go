@@ -1,7 +1,9 @@
 package cli
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/milan604/trustPIN/internal/trustpin"
 )
@@ -27,8 +29,154 @@ func TestResolveInspectAccountPrefersExactMatch(t *testing.T) {
 	}
 }
 
+func TestResolveInspectAccountPrefersFullNameOverSearch(t *testing.T) {
+	accounts := []trustpin.Account{
+		{ID: "11111111-1111-4111-8111-111111111111", Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP"},
+		{ID: "22222222-2222-4222-8222-222222222222", Name: "work", Secret: "KRSXG5CTMVRXEZLU"},
+		{ID: "33333333-3333-4333-8333-333333333333", Name: "GitHub", Secret: "GEZDGNBVGY3TQOJQ"},
+	}
+
+	for query, want := range map[string]string{"work": "work", "GitHub": "GitHub", "github:WORK": "GitHub:work", "22222222-2222-4222-8222-222222222222": "work"} {
+		account, suggestions, found, ambiguous := resolveInspectAccount(accounts, query)
+		if !found || ambiguous || account.Name != want {
+			t.Errorf("resolveInspectAccount(%q) = %q, %v, %v, %v; want %q", query, account.Name, suggestions, found, ambiguous, want)
+		}
+	}
+	if _, _, found, ambiguous := resolveInspectAccount(accounts, "git"); found || !ambiguous {
+		t.Fatalf("expected a partial query to stay ambiguous, got found=%v ambiguous=%v", found, ambiguous)
+	}
+}
+
+func TestDashboardSearchRanksMatches(t *testing.T) {
+	accounts := []trustpin.Account{
+		{Name: "GitLab:ci", Secret: "JBSWY3DPEHPK3PXP"},
+		{Name: "Bank", Secret: "KRSXG5CTMVRXEZLU", Notes: "github recovery email"},
+		{Name: "GitHub:work", Secret: "GEZDGNBVGY3TQOJQ"},
+	}
+
+	views, stats := buildDashboardView(accounts, showOptions{Search: "githbu", SortBy: "relevance"})
+	if stats.Visible != 2 || views[0].FullName != "GitHub:work" || views[0].Score <= views[1].Score {
+		t.Fatalf("expected the misspelled issuer to rank first, got %+v", views)
+	}
+}
+
 func TestNormalizeSortRejectsUnknownValues(t *testing.T) {
 	if _, err := normalizeSort("latency"); err == nil {
 		t.Fatalf("expected invalid sort to fail")
 	}
 }
+
+func TestVaultLinesHideNotesUntilRevealed(t *testing.T) {
+	view := buildAccountViewModel(trustpin.Account{
+		Name:          "GitHub:work",
+		Secret:        "JBSWY3DPEHPK3PXP",
+		Notes:         "backup email on file",
+		RecoveryCodes: []trustpin.RecoveryCode{{Code: "1111-2222", Used: true}, {Code: "3333-4444"}},
+	})
+
+	hidden := strings.Join(view.vaultLines(), "\n")
+	if strings.Contains(hidden, "backup email") || strings.Contains(hidden, "3333-4444") {
+		t.Fatalf("expected notes and codes to be hidden, got %q", hidden)
+	}
+	if !strings.Contains(hidden, "1 of 2 recovery codes unused") {
+		t.Fatalf("expected a recovery summary, got %q", hidden)
+	}
+
+	view.RevealNotes = true
+	revealed := strings.Join(view.vaultLines(), "\n")
+	if !strings.Contains(revealed, "backup email") || !strings.Contains(revealed, "3333-4444") || strings.Contains(revealed, "1111-2222") {
+		t.Fatalf("expected notes and unused codes only, got %q", revealed)
+	}
+}
+
+func TestPrivateModeMasksAllButRevealedAccount(t *testing.T) {
+	accounts := []trustpin.Account{
+		{ID: "11111111-1111-4111-8111-111111111111", Name: "AWS:root", Secret: "KRSXG5CTMVRXEZLU", Notes: "root email"},
+		{ID: "22222222-2222-4222-8222-222222222222", Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP"},
+	}
+
+	views, _ := buildDashboardView(accounts, showOptions{SortBy: "name", Private: true, RevealNotes: true, RevealID: "22222222-2222-4222-8222-222222222222"})
+	if len(views) != 2 {
+		t.Fatalf("expected two views, got %d", len(views))
+	}
+
+	masked, revealed := views[0], views[1]
+	if masked.FormattedOTP != "••• •••" || masked.SecretPreview != "hidden" || masked.indexPrefix() != "1 " {
+		t.Fatalf("expected the first account to be masked, got %+v", masked)
+	}
+	if strings.Contains(strings.Join(masked.vaultLines(), "\n"), "root email") {
+		t.Fatalf("expected notes to stay hidden in private mode")
+	}
+	if strings.Contains(revealed.FormattedOTP, "•") || revealed.indexPrefix() != "2 " {
+		t.Fatalf("expected the revealed account to show its code, got %+v", revealed)
+	}
+}
+
+func TestGroupedDashboardIndexesFollowGroups(t *testing.T) {
+	accounts := []trustpin.Account{
+		{Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP", Tags: []string{"work"}},
+		{Name: "AWS:root", Secret: "KRSXG5CTMVRXEZLU", Tags: []string{"work", "cloud"}},
+		{Name: "GitHub:home", Secret: "JBSWY3DPEHPK3PXP"},
+	}
+
+	opts := showOptions{SortBy: "name", GroupBy: trustpin.GroupByTag, Private: true}
+	views, stats := buildDashboardView(accounts, opts)
+	names := []string{views[0].FullName, views[1].FullName, views[2].FullName}
+	if strings.Join(names, ",") != "AWS:root,GitHub:work,GitHub:home" || views[2].indexPrefix() != "3 " {
+		t.Fatalf("expected views in group order with matching indexes, got %v", names)
+	}
+
+	opts.Compact = true
+	output := renderDashboard(views, stats, opts, "accounts.enc")
+	for _, header := range []string{"cloud 1 account", "work 2 accounts", "Untagged 1 account", "group tag"} {
+		if !strings.Contains(output, header) {
+			t.Fatalf("expected %q in the grouped dashboard:\n%s", header, output)
+		}
+	}
+}
+
+func TestInspectTimelineShowsNeighbouringCodes(t *testing.T) {
+	view := buildAccountViewModel(trustpin.Account{Name: "RFC", Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Digits: 8, Interval: 30})
+	lines := view.timelineLines(time.Unix(59, 0))
+	if len(lines) != 3 || !strings.Contains(lines[1], "9428 7082") || !strings.HasPrefix(lines[2], "next") {
+		t.Fatalf("expected previous, current and next codes, got %q", lines)
+	}
+
+	view.mask()
+	if masked := strings.Join(view.timelineLines(time.Unix(59, 0)), "\n"); strings.Contains(masked, "9428") {
+		t.Fatalf("expected private mode to mask the timeline, got %q", masked)
+	}
+
+	hotp := buildAccountViewModel(trustpin.Account{Name: "Bank", Secret: "JBSWY3DPEHPK3PXP", Type: trustpin.TypeHOTP})
+	if lines := hotp.timelineLines(time.Now()); lines != nil {
+		t.Fatalf("expected no timeline for HOTP, got %q", lines)
+	}
+
+	archived := buildAccountViewModel(trustpin.Account{Name: "Old", Secret: "JBSWY3DPEHPK3PXP", Archived: true})
+	if lines := archived.timelineLines(time.Now()); lines != nil {
+		t.Fatalf("expected no timeline for an archived account, got %q", lines)
+	}
+}
+
+func TestIdleLockBlanksUntilKeyPress(t *testing.T) {
+	start := time.Now()
+	idle := newIdleLock(time.Minute, start)
+
+	if idle.update(start.Add(59 * time.Second)) {
+		t.Fatalf("did not expect the view to lock before the timeout")
+	}
+	if !idle.update(start.Add(time.Minute)) {
+		t.Fatalf("expected the view to lock after the timeout")
+	}
+	if !idle.touch(start.Add(2 * time.Minute)) {
+		t.Fatalf("expected the first key press to unlock the view")
+	}
+	if idle.touch(start.Add(2*time.Minute)) || idle.update(start.Add(2*time.Minute+30*time.Second)) {
+		t.Fatalf("expected later key presses to be handled normally")
+	}
+
+	idle.lock("Locked by a lock signal.")
+	if !idle.update(start.Add(2 * time.Minute)) {
+		t.Fatalf("expected a lock signal to lock the view immediately")
+	}
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/milan604/trustPIN/internal/trustpin"
+	"github.com/spf13/cobra"
+)
+
+func newDoctorCmd(app *App) *cobra.Command {
+	doctorCmd := &cobra.Command{
+		Use:          "doctor",
+		Short:        "Check the encrypted store and repair it from a backup",
+		Long:         "Check that the store and key exist, have private permissions, decrypt, and hold a valid account list, and look for duplicate IDs and leftover plaintext files. Each save keeps earlier versions of the store as backup generations; --restore swaps in the newest one that still decrypts. It refuses while the store itself still opens unless --force is passed.",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE:         app.runDoctorCommand,
+	}
+	doctorCmd.Flags().Bool("restore", false, "Replace the store with the newest intact backup generation")
+	doctorCmd.Flags().BoolP("force", "f", false, "Restore even when the current store still opens, discarding its recent changes")
+	return doctorCmd
+}
+
+func (a *App) runDoctorCommand(cmd *cobra.Command, args []string) error {
+	restore, _ := cmd.Flags().GetBool("restore")
+	force, _ := cmd.Flags().GetBool("force")
+	service := a.service()
+	output := a.outputOptions()
+
+	if restore {
+		backup, kept, err := service.RestoreBackup(time.Now(), force)
+		if errors.Is(err, trustpin.ErrConflict) {
+			return fmt.Errorf("%w; pass --force to restore anyway", err)
+		}
+		if err != nil {
+			return err
+		}
+		out := os.Stdout
+		if output.machine() {
+			out = os.Stderr
+		}
+		fmt.Fprintf(out, "Restored backup generation %d (%d %s, saved %s).\n", backup.Generation, backup.Accounts, pluralize("account", "accounts", backup.Accounts), backup.ModTime.Local().Format("2006-01-02 15:04"))
+		if kept != "" {
+			fmt.Fprintf(out, "The previous store was kept at %s.\n", kept)
+		}
+	}
+
+	report := service.Diagnose()
+	if output.machine() {
+		if err := writeDiagnosticReport(os.Stdout, report, output.Format); err != nil {
+			return err
+		}
+	} else {
+		fmt.Print(renderDiagnosticReport(report))
+	}
+
+	if report.Status == trustpin.DiagnosticError {
+		failing := 0
+		for _, check := range report.Checks {
+			if check.Status == trustpin.DiagnosticError {
+				failing++
+			}
+		}
+		return exitError{
+			code: ExitHealthFailed,
+			err:  fmt.Errorf("doctor found %d %s", failing, pluralize("problem", "problems", failing)),
+		}
+	}
+	return nil
+}
+
+func writeDiagnosticReport(w io.Writer, report trustpin.DiagnosticReport, format string) error {
+	switch format {
+	case outputJSON:
+		return writeJSONOutput(w, report)
+	case outputYAML:
+		return writeYAMLOutput(w, report)
+	case outputCSV:
+		rows := make([][]string, 0, len(report.Checks))
+		for _, check := range report.Checks {
+			rows = append(rows, []string{check.ID, string(check.Status), check.Title, check.Detail, check.Fix})
+		}
+		return writeCSVOutput(w, []string{"check", "status", "title", "detail", "fix"}, rows)
+	default:
+		return fmt.Errorf("output %q is not supported by the doctor command", format)
+	}
+}
+
+func renderDiagnosticReport(report trustpin.DiagnosticReport) string {
+	width := min(terminalWidth(), 100)
+	counts := map[trustpin.DiagnosticStatus]int{}
+	for _, check := range report.Checks {
+		counts[check.Status]++
+	}
+
+	lines := []string{
+		brandText("TRUSTPIN DOCTOR"),
+		mutedText("Store " + report.StorePath),
+		mutedText("Key   " + report.KeyPath),
+		"",
+		strings.Join([]string{
+			renderMetricBadge(toneDanger, fmt.Sprintf("%d %s", counts[trustpin.DiagnosticError], pluralize("error", "errors", counts[trustpin.DiagnosticError]))),
+			renderMetricBadge(toneWarning, fmt.Sprintf("%d %s", counts[trustpin.DiagnosticWarning], pluralize("warning", "warnings", counts[trustpin.DiagnosticWarning]))),
+			renderMetricBadge(toneSuccess, fmt.Sprintf("%d ok", counts[trustpin.DiagnosticOK])),
+		}, " "),
+	}
+
+	for _, check := range report.Checks {
+		lines = append(lines, "")
+		lines = append(lines, alignLine(styleDiagnosticHeading(check), mutedText(check.ID), width-4))
+		lines = append(lines, wrapText(check.Detail, width-4)...)
+		if check.Fix != "" {
+			for _, line := range wrapText("Fix: "+check.Fix, width-4) {
+				lines = append(lines, mutedText(line))
+			}
+		}
+	}
+
+	if len(report.Backups) > 0 {
+		lines = append(lines, "", headingText("Backup generations"))
+		for _, backup := range report.Backups {
+			state := successText(fmt.Sprintf("%d %s", backup.Accounts, pluralize("account", "accounts", backup.Accounts)))
+			if !backup.Intact {
+				state = dangerText("unreadable: " + backup.Problem)
+			}
+			lines = append(lines, fmt.Sprintf("%d  %s  %s", backup.Generation, backup.ModTime.Local().Format("2006-01-02 15:04"), state))
+		}
+	}
+
+	return strings.Join(renderPanel("Store diagnostics", lines, width), "\n") + "\n"
+}
+
+func styleDiagnosticHeading(check trustpin.DiagnosticCheck) string {
+	switch check.Status {
+	case trustpin.DiagnosticError:
+		return dangerText("ERROR | " + check.Title)
+	case trustpin.DiagnosticWarning:
+		return warningText("WARNING | " + check.Title)
+	default:
+		return successText("OK | " + check.Title)
+	}
+}
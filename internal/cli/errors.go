@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"errors"
+
+	"github.com/milan604/trustPIN/internal/trustpin"
+)
+
+// Process exit codes. Scripts can branch on these instead of parsing the
+// error text, so existing values must never change meaning.
+const (
+	ExitOK           = 0
+	ExitError        = 1
+	ExitHealthFailed = 2
+	ExitNotFound     = 3
+	ExitConflict     = 4
+	ExitInvalidInput = 5
+	ExitStoreLocked  = 6
+	ExitWrongKey     = 7
+	ExitCorruptStore = 8
+)
+
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e exitError) Unwrap() error {
+	return e.err
+}
+
+// ExitCode maps an error returned by the root command to a process exit code.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var coded exitError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+
+	switch {
+	case errors.Is(err, trustpin.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, trustpin.ErrConflict):
+		return ExitConflict
+	case errors.Is(err, trustpin.ErrValidation):
+		return ExitInvalidInput
+	case errors.Is(err, trustpin.ErrStoreLocked):
+		return ExitStoreLocked
+	case errors.Is(err, trustpin.ErrWrongKey):
+		return ExitWrongKey
+	case errors.Is(err, trustpin.ErrCorruptStore):
+		return ExitCorruptStore
+	}
+	return ExitError
+}
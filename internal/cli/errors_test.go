@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/milan604/trustPIN/internal/trustpin"
+)
+
+func TestExitCodeFollowsErrorKind(t *testing.T) {
+	tmpDir := t.TempDir()
+	service := trustpin.Service{
+		StorePath: filepath.Join(tmpDir, "accounts.enc"),
+		KeyPath:   filepath.Join(tmpDir, "accounts.key"),
+	}
+	_, deleteErr := service.DeleteAccount("missing")
+
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, ExitOK},
+		{"plain error", errors.New("boom"), ExitError},
+		{"health gate", exitError{code: ExitHealthFailed, err: errors.New("failed")}, ExitHealthFailed},
+		{"missing account", fmt.Errorf("delete: %w", deleteErr), ExitNotFound},
+		{"invalid secret", trustpin.ValidateSecret("!!", trustpin.EncodingBase32), ExitInvalidInput},
+		{"name collision", trustpin.ErrNameCollision, ExitConflict},
+		{"wrong key", trustpin.ErrWrongKey, ExitWrongKey},
+		{"corrupted store", trustpin.ErrCorruptStore, ExitCorruptStore},
+		{"locked store", trustpin.ErrStoreLocked, ExitStoreLocked},
+		{"insecure store", trustpin.ErrInsecureStore, ExitStoreLocked},
+		{"newer store", trustpin.ErrNewerStore, ExitStoreLocked},
+	}
+	for _, c := range cases {
+		if got := ExitCode(c.err); got != c.want {
+			t.Errorf("%s: exit code %d, want %d", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchKeys delivers key presses from stdin while a live view runs. It returns
+// a nil channel when stdin is not a terminal. Call the returned function before
+// exiting to restore the terminal.
+func watchKeys() (<-chan byte, func()) {
+	if !stdinIsTerminal() {
+		return nil, func() {}
+	}
+
+	restore, err := enableKeyInput(int(os.Stdin.Fd()))
+	if err != nil {
+		restore = func() {}
+	}
+
+	keys := make(chan byte, 8)
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			if n == 1 {
+				select {
+				case keys <- buf[0]:
+				default:
+				}
+			}
+		}
+	}()
+	return keys, restore
+}
+
+// watchInterrupts lets a live view shut down cleanly on Ctrl+C so the terminal
+// mode set by watchKeys is restored.
+func watchInterrupts() (<-chan os.Signal, func()) {
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	return interrupts, func() { signal.Stop(interrupts) }
+}
+
+// watchLockSignals delivers lock requests from lockSignals. It returns a nil
+// channel on platforms without them.
+func watchLockSignals() (<-chan os.Signal, func()) {
+	if len(lockSignals) == 0 {
+		return nil, func() {}
+	}
+	locks := make(chan os.Signal, 1)
+	signal.Notify(locks, lockSignals...)
+	return locks, func() { signal.Stop(locks) }
+}
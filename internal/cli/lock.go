@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultIdleTimeout is how long live views and web sessions stay unlocked
+// without activity.
+const defaultIdleTimeout = 5 * time.Minute
+
+// idleLock blanks a live view once no key has been pressed for the timeout,
+// or when a lock signal arrives. A zero timeout only locks on signals.
+type idleLock struct {
+	timeout    time.Duration
+	lastActive time.Time
+	locked     bool
+	reason     string
+}
+
+func newIdleLock(timeout time.Duration, now time.Time) *idleLock {
+	return &idleLock{timeout: timeout, lastActive: now}
+}
+
+// update locks the view when the timeout has passed and reports whether it is
+// locked.
+func (l *idleLock) update(now time.Time) bool {
+	if !l.locked && l.timeout > 0 && now.Sub(l.lastActive) >= l.timeout {
+		l.lock(fmt.Sprintf("Locked after %s without a key press.", l.timeout))
+	}
+	return l.locked
+}
+
+func (l *idleLock) lock(reason string) {
+	l.locked = true
+	l.reason = reason
+}
+
+// touch records a key press. It reports whether the press unlocked the view,
+// in which case the key should not be handled further.
+func (l *idleLock) touch(now time.Time) bool {
+	l.lastActive = now
+	if !l.locked {
+		return false
+	}
+	l.locked = false
+	l.reason = ""
+	return true
+}
+
+func renderLockScreen(reason string) string {
+	width := min(terminalWidth(), 72)
+	lines := []string{
+		renderMetricBadge(toneWarning, "LOCKED"),
+		"",
+		headingText("Codes are hidden."),
+		mutedText(reason),
+		"",
+		"Press any key to unlock. Ctrl+C exits.",
+	}
+	return strings.Join(renderPanel("TrustPIN", lines, width), "\n") + "\n"
+}
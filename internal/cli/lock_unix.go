@@ -0,0 +1,12 @@
+//go:build unix
+
+package cli
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockSignals lock live views and web sessions immediately, so a screen
+// locker hook can run `pkill -USR1 trustpin`.
+var lockSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/milan604/trustPIN/internal/trustpin"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputCSV   = "csv"
+	outputSARIF = "sarif"
+	outputJUnit = "junit"
+
+	redactedValue = "[redacted]"
+)
+
+type outputOptions struct {
+	Format         string
+	IncludeSecrets bool
+}
+
+func (o outputOptions) machine() bool {
+	return o.Format != "" && o.Format != outputTable
+}
+
+type accountRecord struct {
+	trustpin.AccountSnapshot
+	Secret string `json:"secret,omitempty"`
+}
+
+func normalizeOutputFormat(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", outputTable:
+		return outputTable, nil
+	case outputJSON:
+		return outputJSON, nil
+	case outputYAML, "yml":
+		return outputYAML, nil
+	case outputCSV:
+		return outputCSV, nil
+	case outputSARIF:
+		return outputSARIF, nil
+	case outputJUnit:
+		return outputJUnit, nil
+	default:
+		return "", fmt.Errorf("unsupported output %q (use table, json, yaml, csv, sarif, or junit)", value)
+	}
+}
+
+func buildAccountRecords(snapshots []trustpin.AccountSnapshot, includeSecrets bool) []accountRecord {
+	records := make([]accountRecord, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		record := accountRecord{AccountSnapshot: snapshot}
+		if includeSecrets {
+			record.Secret = snapshot.Account.Secret
+		} else {
+			// Notes and recovery codes often hold account recovery details,
+			// so even their counts stay out of scripts and logs by default.
+			record.SecretPreview = redactedValue
+			if record.Notes != "" {
+				record.Notes = redactedValue
+			}
+			record.RecoveryCodes, record.RecoveryUnused = 0, 0
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func writeAccounts(w io.Writer, snapshots []trustpin.AccountSnapshot, opts outputOptions) error {
+	records := buildAccountRecords(snapshots, opts.IncludeSecrets)
+
+	switch opts.Format {
+	case outputJSON:
+		return writeJSONOutput(w, records)
+	case outputYAML:
+		return writeYAMLOutput(w, records)
+	case outputCSV:
+		header := []string{"id", "name", "issuer", "label", "otp", "timeRemaining", "interval", "digits", "algorithm", "type", "counter", "tags", "favorite", "archived", "status", "policy"}
+		if opts.IncludeSecrets {
+			header = append(header, "secret")
+		}
+
+		rows := make([][]string, 0, len(records))
+		for _, record := range records {
+			row := []string{
+				record.ID,
+				record.Name,
+				record.Issuer,
+				record.Label,
+				record.OTP,
+				strconv.FormatInt(record.TimeRemaining, 10),
+				strconv.FormatInt(record.Interval, 10),
+				strconv.Itoa(record.Digits),
+				record.Algorithm,
+				record.Type,
+				strconv.FormatInt(record.Counter, 10),
+				strings.Join(record.Tags, ";"),
+				strconv.FormatBool(record.Favorite),
+				strconv.FormatBool(record.Archived),
+				record.StatusLabel,
+				record.PolicyLabel,
+			}
+			if opts.IncludeSecrets {
+				row = append(row, record.Secret)
+			}
+			rows = append(rows, row)
+		}
+		return writeCSVOutput(w, header, rows)
+	default:
+		return fmt.Errorf("output %q is only supported by the health command", opts.Format)
+	}
+}
+
+func writeHealthReport(w io.Writer, report trustpin.HealthReport, format string) error {
+	switch format {
+	case outputJSON:
+		return writeJSONOutput(w, report)
+	case outputYAML:
+		return writeYAMLOutput(w, report)
+	case outputCSV:
+		rows := make([][]string, 0, len(report.Items))
+		for _, item := range report.Items {
+			rows = append(rows, []string{item.Rule, string(item.Level), item.Title, item.Detail, item.Remediation, healthItemAccounts(item)})
+		}
+		return writeCSVOutput(w, []string{"rule", "level", "title", "detail", "remediation", "account"}, rows)
+	case outputSARIF:
+		return writeSARIFReport(w, report)
+	case outputJUnit:
+		return writeJUnitReport(w, report)
+	default:
+		return fmt.Errorf("unsupported output %q", format)
+	}
+}
+
+func writeJSONOutput(w io.Writer, value interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// writeYAMLOutput round-trips through JSON so YAML keys match the JSON field
+// names and keep their declaration order.
+func writeYAMLOutput(w io.Writer, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	clearYAMLStyle(&node)
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func clearYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearYAMLStyle(child)
+	}
+}
+
+func writeCSVOutput(w io.Writer, header []string, rows [][]string) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
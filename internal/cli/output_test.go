@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/milan604/trustPIN/internal/trustpin"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func goldenAccounts() []trustpin.AccountSnapshot {
+	accounts := []trustpin.Account{
+		{ID: "0b5c3f0e-7a51-4c39-9d59-2f0f4e7d1a01", Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP", Digits: 6, Type: trustpin.TypeHOTP, Counter: 7, Tags: []string{"work", "dev"}, Favorite: true, Notes: "backup email on file", RecoveryCodes: []trustpin.RecoveryCode{{Code: "1111-2222", Used: true}, {Code: "3333-4444"}}},
+		{ID: "6f1d2e8a-93b4-4f0c-8a6e-5c7b9d2e4f02", Name: "AWS SSO:prod", Secret: "MFRGGZDFMZTWQ2LK", Interval: 30, Digits: 6, Archived: true},
+	}
+
+	snapshots := make([]trustpin.AccountSnapshot, 0, len(accounts))
+	for _, account := range accounts {
+		snapshots = append(snapshots, trustpin.BuildAccountSnapshot(account))
+	}
+	return snapshots
+}
+
+func goldenHealthReport() trustpin.HealthReport {
+	accounts := []trustpin.Account{
+		{Name: "Standalone", Secret: "not-a-secret", Interval: 30, Digits: 6},
+		{Name: "Service:counter", Secret: "JBSWY3DPEHPK3PXP", Digits: 6, Type: trustpin.TypeHOTP, Counter: 3},
+	}
+
+	items := trustpin.AnalyzeAccounts(accounts)
+	return trustpin.HealthReport{Items: items, Summary: trustpin.SummarizeHealth(items), Total: len(accounts)}
+}
+
+func TestWriteAccountsGolden(t *testing.T) {
+	cases := map[string]outputOptions{
+		"accounts.json":         {Format: outputJSON},
+		"accounts.yaml":         {Format: outputYAML},
+		"accounts.csv":          {Format: outputCSV},
+		"accounts_secrets.json": {Format: outputJSON, IncludeSecrets: true},
+	}
+
+	for name, opts := range cases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeAccounts(&buf, goldenAccounts(), opts); err != nil {
+				t.Fatalf("write accounts: %v", err)
+			}
+			assertGolden(t, name, buf.Bytes())
+		})
+	}
+}
+
+func TestWriteHealthReportGolden(t *testing.T) {
+	for _, format := range []string{outputJSON, outputYAML, outputCSV, outputSARIF, outputJUnit} {
+		name := "health." + format
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeHealthReport(&buf, goldenHealthReport(), format); err != nil {
+				t.Fatalf("write health report: %v", err)
+			}
+			assertGolden(t, name, buf.Bytes())
+		})
+	}
+}
+
+func TestNormalizeOutputFormatRejectsUnknownValues(t *testing.T) {
+	if _, err := normalizeOutputFormat("xml"); err == nil {
+		t.Fatalf("expected invalid output format to fail")
+	}
+}
+
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("update golden: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("output does not match %s:\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
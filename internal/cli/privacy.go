@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	maskRune              = '•'
+	privateRevealDuration = 10 * time.Second
+)
+
+// maskOTP hides every character of a formatted code but keeps its grouping,
+// so "123 456" becomes "••• •••".
+func maskOTP(code string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return r
+		}
+		return maskRune
+	}, code)
+}
+
+// mask hides the code, secret preview and notes of a view for private mode.
+func (account *accountViewModel) mask() {
+	account.Private = true
+	if account.ErrorText == "" {
+		account.FormattedOTP = maskOTP(account.FormattedOTP)
+	}
+	account.SecretPreview = "hidden"
+}
+
+// indexPrefix labels cards with the key that reveals them in private mode.
+func (account accountViewModel) indexPrefix() string {
+	if account.Index < 1 || account.Index > 9 {
+		return ""
+	}
+	return fmt.Sprintf("%d ", account.Index)
+}
@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/milan604/trustPIN/internal/trustpin"
+	"github.com/spf13/cobra"
+)
+
+type recoveryCodeRecord struct {
+	Index int    `json:"index"`
+	Code  string `json:"code"`
+	Used  bool   `json:"used"`
+}
+
+func newRecoveryCmd(app *App) *cobra.Command {
+	recoveryCmd := &cobra.Command{
+		Use:          "recovery",
+		Aliases:      []string{"codes"},
+		Short:        "Manage encrypted recovery codes for an account",
+		Long:         "Store provider backup codes with an account in the encrypted store, track which ones have been used, and pull the next unused code when you need one.",
+		SilenceUsage: true,
+	}
+
+	listCmd := &cobra.Command{
+		Use:          "list <account>",
+		Aliases:      []string{"ls"},
+		Short:        "Show the recovery codes stored for an account",
+		SilenceUsage: true,
+		Args:         cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.listRecoveryCodes(args[0])
+		},
+	}
+
+	addCmd := &cobra.Command{
+		Use:          "add <account> [code ...]",
+		Short:        "Add recovery codes to an account",
+		Long:         "Add recovery codes to an account. Codes come from the arguments, from --file, or from stdin (one per line or comma-separated). Codes the account already holds are skipped.",
+		SilenceUsage: true,
+		Args:         cobra.MinimumNArgs(1),
+		RunE:         app.addRecoveryCodes,
+	}
+	addCmd.Flags().String("file", "", "Read recovery codes from a file, one per line")
+
+	useCmd := &cobra.Command{
+		Use:          "use <account> [code]",
+		Short:        "Mark a recovery code as used",
+		Long:         "Mark a recovery code as used. Without a code, the next unused one is printed and marked used.",
+		SilenceUsage: true,
+		Args:         cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			code := ""
+			if len(args) > 1 {
+				code = args[1]
+			}
+			return app.useRecoveryCode(args[0], code)
+		},
+	}
+
+	recoveryCmd.AddCommand(listCmd, addCmd, useCmd)
+	return recoveryCmd
+}
+
+func (a *App) listRecoveryCodes(ref string) error {
+	account, err := a.service().GetAccount(ref)
+	if err != nil {
+		return err
+	}
+
+	output := a.outputOptions()
+	if output.machine() {
+		return writeRecoveryCodes(os.Stdout, account, output)
+	}
+
+	width := min(terminalWidth(), 72)
+	unused := trustpin.UnusedRecoveryCodes(account)
+	lines := []string{
+		renderMetricBadge(toneSuccess, fmt.Sprintf("%d unused", unused)) + " " +
+			renderMetricBadge(toneMuted, fmt.Sprintf("%d used", len(account.RecoveryCodes)-unused)),
+		"",
+	}
+	if len(account.RecoveryCodes) == 0 {
+		lines = append(lines, mutedText("No recovery codes stored. Add them with `trustpin recovery add "+account.Name+"`."))
+	}
+	for i, code := range account.RecoveryCodes {
+		line := fmt.Sprintf("%2d  %s", i+1, code.Code)
+		if code.Used {
+			lines = append(lines, mutedText(line+"  used"))
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if len(account.RecoveryCodes) > 0 && unused < trustpin.DefaultMinRecoveryCodes {
+		lines = append(lines, "", warningText("Running low. Generate a fresh set with the provider."))
+	}
+
+	fmt.Println(strings.Join(renderPanel("Recovery codes: "+account.Name, lines, width), "\n"))
+	return nil
+}
+
+func writeRecoveryCodes(w io.Writer, account trustpin.Account, output outputOptions) error {
+	records := make([]recoveryCodeRecord, 0, len(account.RecoveryCodes))
+	for i, code := range account.RecoveryCodes {
+		value := code.Code
+		if !output.IncludeSecrets {
+			value = redactedValue
+		}
+		records = append(records, recoveryCodeRecord{Index: i + 1, Code: value, Used: code.Used})
+	}
+
+	switch output.Format {
+	case outputJSON:
+		return writeJSONOutput(w, records)
+	case outputYAML:
+		return writeYAMLOutput(w, records)
+	case outputCSV:
+		rows := make([][]string, 0, len(records))
+		for _, record := range records {
+			rows = append(rows, []string{fmt.Sprint(record.Index), record.Code, fmt.Sprint(record.Used)})
+		}
+		return writeCSVOutput(w, []string{"index", "code", "used"}, rows)
+	default:
+		return fmt.Errorf("output %q is not supported by the recovery command", output.Format)
+	}
+}
+
+func (a *App) addRecoveryCodes(cmd *cobra.Command, args []string) error {
+	filePath, _ := cmd.Flags().GetString("file")
+
+	codes := args[1:]
+	switch {
+	case len(codes) > 0:
+	case filePath != "":
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("read recovery codes: %w", err)
+		}
+		codes = trustpin.ParseRecoveryCodes(string(data))
+	case !stdinIsTerminal():
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("read recovery codes: %w", err)
+		}
+		codes = trustpin.ParseRecoveryCodes(string(data))
+	default:
+		value, err := promptForValue("Recovery codes (comma-separated)")
+		if err != nil {
+			return err
+		}
+		codes = trustpin.ParseRecoveryCodes(value)
+	}
+
+	account, added, err := a.service().AddRecoveryCodes(args[0], codes)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Added %d recovery %s to %s (%d unused).\n", added, pluralize("code", "codes", added), account.Name, trustpin.UnusedRecoveryCodes(account))
+	if skipped := len(codes) - added; skipped > 0 {
+		fmt.Printf("Skipped %d %s already stored.\n", skipped, pluralize("code", "codes", skipped))
+	}
+	return nil
+}
+
+func (a *App) useRecoveryCode(ref, code string) error {
+	used, remaining, err := a.service().UseRecoveryCode(ref, code)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(code) == "" {
+		fmt.Println(used.Code)
+	}
+	fmt.Fprintf(os.Stderr, "Marked recovery code as used. %d unused %s left.\n", remaining, pluralize("code", "codes", remaining))
+	if remaining < trustpin.DefaultMinRecoveryCodes {
+		fmt.Fprintln(os.Stderr, "Running low on recovery codes. Generate a fresh set with the provider.")
+	}
+	return nil
+}
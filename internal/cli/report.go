@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/milan604/trustPIN/internal/trustpin"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	Name             string        `json:"name"`
+	ShortDescription sarifMessage  `json:"shortDescription"`
+	Help             *sarifMessage `json:"help,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func writeSARIFReport(w io.Writer, report trustpin.HealthReport) error {
+	rules := make([]sarifRule, 0)
+	for _, rule := range trustpin.HealthRules() {
+		entry := sarifRule{
+			ID:               rule.ID,
+			Name:             rule.Title,
+			ShortDescription: sarifMessage{Text: rule.Description},
+		}
+		if rule.Remediation != "" {
+			entry.Help = &sarifMessage{Text: rule.Remediation}
+		}
+		rules = append(rules, entry)
+	}
+
+	results := make([]sarifResult, 0, len(report.Items))
+	for _, item := range report.Items {
+		result := sarifResult{
+			RuleID:  item.Rule,
+			Level:   sarifLevel(item.Level),
+			Message: sarifMessage{Text: item.Detail},
+		}
+		for _, name := range healthItemAccountList(item) {
+			result.Locations = append(result.Locations, sarifLocation{
+				LogicalLocations: []sarifLogicalLocation{{Name: name, Kind: "member"}},
+			})
+		}
+		results = append(results, result)
+	}
+
+	return writeJSONOutput(w, sarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "TrustPIN",
+				InformationURI: "https://github.com/milan604/trustPIN",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	})
+}
+
+func writeJUnitReport(w io.Writer, report trustpin.HealthReport) error {
+	suite := junitTestSuite{Name: "trustpin-health"}
+	for _, item := range report.Items {
+		className := healthItemAccounts(item)
+		if className == "" {
+			className = "workspace"
+		}
+
+		testCase := junitTestCase{
+			Name:      item.Rule,
+			ClassName: className,
+		}
+		if item.Level == trustpin.HealthLevelInfo {
+			testCase.SystemOut = item.Detail
+		} else {
+			testCase.Failure = &junitFailure{
+				Message: item.Title,
+				Type:    string(item.Level),
+				Text:    item.Detail,
+			}
+			suite.Failures++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Tests = len(suite.Cases)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+func sarifLevel(level trustpin.HealthLevel) string {
+	switch level {
+	case trustpin.HealthLevelCritical:
+		return "error"
+	case trustpin.HealthLevelWarning:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+func healthItemAccountList(item trustpin.HealthItem) []string {
+	if item.Account != "" {
+		return []string{item.Account}
+	}
+	return item.Accounts
+}
+
+func healthItemAccounts(item trustpin.HealthItem) string {
+	return strings.Join(healthItemAccountList(item), ";")
+}
@@ -0,0 +1,11 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package cli
+
+import "errors"
+
+// enableKeyInput is unavailable on this platform. Key presses are still read,
+// but only arrive after Enter.
+func enableKeyInput(fd int) (func(), error) {
+	return nil, errors.New("single-key input is not supported on this platform")
+}
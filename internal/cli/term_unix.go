@@ -0,0 +1,27 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package cli
+
+import "golang.org/x/sys/unix"
+
+// enableKeyInput switches the terminal on fd to unbuffered, unechoed input so
+// single key presses reach the dashboard. Signals such as Ctrl+C still work.
+// The returned function restores the previous mode.
+func enableKeyInput(fd int) (func(), error) {
+	original, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := *original
+	raw.Lflag &^= unix.ICANON | unix.ECHO
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, &raw); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		_ = unix.IoctlSetTermios(fd, ioctlWriteTermios, original)
+	}, nil
+}
@@ -6,7 +6,10 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
+
+	"github.com/mattn/go-isatty"
 )
 
 var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
@@ -15,6 +18,16 @@ func clearScreen() {
 	fmt.Print("\033[H\033[2J")
 }
 
+func stdoutIsTerminal() bool {
+	fd := os.Stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
+func stdinIsTerminal() bool {
+	fd := os.Stdin.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
+
 func terminalWidth() int {
 	if value := strings.TrimSpace(os.Getenv("COLUMNS")); value != "" {
 		if width, err := strconv.Atoi(value); err == nil && width >= 72 {
@@ -119,3 +132,28 @@ func pluralize(singular, plural string, count int) string {
 	}
 	return plural
 }
+
+// formatTimestamp renders t as a local time with its age, or fallback when t
+// is unknown.
+func formatTimestamp(t, now time.Time, fallback string) string {
+	if t.IsZero() {
+		return fallback
+	}
+	return t.Local().Format("2006-01-02 15:04") + " (" + relativeAge(now.Sub(t)) + ")"
+}
+
+func relativeAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		minutes := int(d / time.Minute)
+		return fmt.Sprintf("%d %s ago", minutes, pluralize("minute", "minutes", minutes))
+	case d < 24*time.Hour:
+		hours := int(d / time.Hour)
+		return fmt.Sprintf("%d %s ago", hours, pluralize("hour", "hours", hours))
+	default:
+		days := int(d / (24 * time.Hour))
+		return fmt.Sprintf("%d %s ago", days, pluralize("day", "days", days))
+	}
+}
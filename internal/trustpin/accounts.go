@@ -6,12 +6,14 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
+	"time"
 )
 
 const (
@@ -28,21 +30,39 @@ type Service struct {
 	StorePath  string
 	KeyPath    string
 	LegacyPath string
+	// OnPermissionFix, when set, is told each time loading tightens the mode
+	// of the store, the key or the app directory, or finds their directory
+	// owned by another user.
+	OnPermissionFix func(PermissionFix)
 }
 
 type Account struct {
-	Name      string   `json:"Name"`
-	Secret    string   `json:"Secret"`
-	Interval  int64    `json:"Interval"`
-	Digits    int      `json:"Digits"`
-	Algorithm string   `json:"Algorithm,omitempty"`
-	Type      string   `json:"Type,omitempty"`
-	Counter   int64    `json:"Counter,omitempty"`
-	Tags      []string `json:"Tags,omitempty"`
-	Favorite  bool     `json:"Favorite,omitempty"`
-	Notes     string   `json:"Notes,omitempty"`
-	SortOrder int      `json:"SortOrder,omitempty"`
-	Archived  bool     `json:"Archived,omitempty"`
+	ID             string         `json:"ID,omitempty"`
+	Name           string         `json:"Name"`
+	Issuer         string         `json:"Issuer,omitempty"`
+	Label          string         `json:"Label,omitempty"`
+	Secret         string         `json:"Secret"`
+	SecretEncoding string         `json:"SecretEncoding,omitempty"`
+	EncodingGuess  bool           `json:"EncodingGuess,omitempty"`
+	Interval       int64          `json:"Interval"`
+	Digits         int            `json:"Digits"`
+	Algorithm      string         `json:"Algorithm,omitempty"`
+	Type           string         `json:"Type,omitempty"`
+	Counter        int64          `json:"Counter,omitempty"`
+	Tags           []string       `json:"Tags,omitempty"`
+	Favorite       bool           `json:"Favorite,omitempty"`
+	Notes          string         `json:"Notes,omitempty"`
+	RecoveryCodes  []RecoveryCode `json:"RecoveryCodes,omitempty"`
+	SortOrder      int            `json:"SortOrder,omitempty"`
+	Archived       bool           `json:"Archived,omitempty"`
+	// Color overrides the issuer's brand color and Icon replaces its icon.
+	Color string       `json:"Color,omitempty"`
+	Icon  *AccountIcon `json:"Icon,omitempty"`
+	// CreatedAt and UpdatedAt are stamped when the store is written;
+	// LastUsedAt moves when a code is copied, revealed or requested.
+	CreatedAt  time.Time `json:"CreatedAt,omitzero"`
+	UpdatedAt  time.Time `json:"UpdatedAt,omitzero"`
+	LastUsedAt time.Time `json:"LastUsedAt,omitzero"`
 }
 
 const (
@@ -56,13 +76,21 @@ const (
 )
 
 type AccountChange struct {
-	Name   string `json:"name"`
-	Action string `json:"action"`
+	ID        string        `json:"id,omitempty"`
+	Name      string        `json:"name"`
+	Action    string        `json:"action"`
+	Previous  string        `json:"previous,omitempty"`
+	MatchedBy string        `json:"matchedBy,omitempty"`
+	Fields    []FieldChange `json:"fields,omitempty"`
 }
 
 type UpsertSummary struct {
 	Added    int             `json:"added"`
 	Replaced int             `json:"replaced"`
+	Merged   int             `json:"merged"`
+	Kept     int             `json:"kept"`
+	Strategy MergeStrategy   `json:"strategy,omitempty"`
+	DryRun   bool            `json:"dryRun,omitempty"`
 	Changes  []AccountChange `json:"changes"`
 }
 
@@ -95,11 +123,11 @@ func NewService(storePath string) Service {
 
 func ValidateAccountInput(account, secret string) error {
 	if strings.TrimSpace(account) == "" {
-		return fmt.Errorf("account name cannot be empty")
+		return invalidf("name", "account name cannot be empty")
 	}
 
 	if strings.TrimSpace(secret) == "" {
-		return fmt.Errorf("secret cannot be empty")
+		return invalidSecretf("secret cannot be empty")
 	}
 
 	return nil
@@ -107,22 +135,41 @@ func ValidateAccountInput(account, secret string) error {
 
 func ValidateDigits(digits int) error {
 	if digits < 1 || digits > 10 {
-		return fmt.Errorf("digits must be between 1 and 10")
+		return invalidf("digits", "digits must be between 1 and 10")
 	}
 	return nil
 }
 
 func (s Service) LoadAccounts() ([]Account, error) {
+	accounts, _, err := s.loadAccounts()
+	return accounts, err
+}
+
+// loadAccounts is LoadAccounts that also returns the permission fixes it made.
+func (s Service) loadAccounts() ([]Account, []PermissionFix, error) {
+	fixes, err := s.securePaths()
+	if err != nil {
+		return nil, fixes, err
+	}
 	if err := s.ensureInitialized(); err != nil {
-		return nil, err
+		return nil, fixes, deniedAsLocked(err)
 	}
 
 	data, err := os.ReadFile(s.storePath())
 	if err != nil {
-		return nil, err
+		return nil, fixes, deniedAsLocked(err)
 	}
 
-	return s.decodeStoredAccounts(data)
+	accounts, migrated, err := s.decodeStoredAccounts(data)
+	if err != nil {
+		return nil, fixes, err
+	}
+	if migrated {
+		if err := s.SaveAccounts(accounts); err != nil {
+			return nil, fixes, fmt.Errorf("save migrated accounts: %w", err)
+		}
+	}
+	return accounts, fixes, nil
 }
 
 func (s Service) SaveAccounts(accounts []Account) error {
@@ -135,44 +182,16 @@ func (s Service) SaveAccounts(accounts []Account) error {
 		return err
 	}
 
-	payload, err := json.MarshalIndent(accounts, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	encrypted, err := encryptPayload(payload, key)
-	if err != nil {
-		return err
-	}
-
-	file, err := os.OpenFile(s.storePath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	_, err = file.Write(encrypted)
-	return err
+	assignAccountIDs(accounts)
+	return s.writeStore(accounts, key, time.Now())
 }
 
 func (s Service) UpsertAccounts(incoming []Account) (UpsertSummary, error) {
-	accounts, err := s.LoadAccounts()
-	if err != nil {
-		return UpsertSummary{}, fmt.Errorf("load accounts: %w", err)
-	}
-
-	accounts, summary := upsertAccounts(accounts, incoming)
-	sort.Slice(accounts, func(i, j int) bool {
-		return normalizeAccountName(accounts[i].Name) < normalizeAccountName(accounts[j].Name)
-	})
-
-	if err := s.SaveAccounts(accounts); err != nil {
-		return UpsertSummary{}, fmt.Errorf("save accounts: %w", err)
-	}
-
-	return summary, nil
+	return s.ImportAccounts(incoming, ImportOptions{})
 }
 
+// DeleteAccount removes the account with the given ID, or every account with
+// the given name. The reference "all" clears the store.
 func (s Service) DeleteAccount(account string) (int, error) {
 	accounts, err := s.LoadAccounts()
 	if err != nil {
@@ -181,7 +200,7 @@ func (s Service) DeleteAccount(account string) (int, error) {
 
 	target := strings.ToLower(strings.TrimSpace(account))
 	if target == "" {
-		return 0, fmt.Errorf("account name cannot be empty")
+		return 0, invalidf("name", "account name cannot be empty")
 	}
 
 	if target == "all" {
@@ -195,7 +214,7 @@ func (s Service) DeleteAccount(account string) (int, error) {
 	filtered := make([]Account, 0, len(accounts))
 	removed := 0
 	for _, current := range accounts {
-		if normalizeAccountName(current.Name) == target {
+		if strings.EqualFold(current.ID, target) || normalizeAccountName(current.Name) == target {
 			removed++
 			continue
 		}
@@ -203,7 +222,7 @@ func (s Service) DeleteAccount(account string) (int, error) {
 	}
 
 	if removed == 0 {
-		return 0, fmt.Errorf("no account found matching %q", account)
+		return 0, notFoundf("no account found matching %q", account)
 	}
 
 	if err := s.SaveAccounts(filtered); err != nil {
@@ -213,11 +232,13 @@ func (s Service) DeleteAccount(account string) (int, error) {
 	return removed, nil
 }
 
-func (s Service) UpdateAccount(currentName string, updated Account) error {
-	currentKey := normalizeAccountName(currentName)
-	if currentKey == "" {
-		return fmt.Errorf("current account name cannot be empty")
+// UpdateAccount replaces the account matching ref, an ID or a name. The
+// account keeps its ID across renames.
+func (s Service) UpdateAccount(ref string, updated Account) error {
+	if strings.TrimSpace(ref) == "" {
+		return invalidf("id", "current account name cannot be empty")
 	}
+	explicitIssuer := strings.TrimSpace(updated.Issuer) != "" || strings.TrimSpace(updated.Label) != ""
 
 	updated = sanitizeAccount(updated)
 
@@ -226,18 +247,43 @@ func (s Service) UpdateAccount(currentName string, updated Account) error {
 		return fmt.Errorf("load accounts: %w", err)
 	}
 
-	matchIdx := -1
-	for i, account := range accounts {
-		if normalizeAccountName(account.Name) == currentKey {
-			matchIdx = i
-			break
-		}
-	}
+	matchIdx := FindAccountIndex(accounts, ref)
 	if matchIdx == -1 {
-		return fmt.Errorf("no account found matching %q", currentName)
+		return notFoundf("no account found matching %q", ref)
+	}
+	if err := applyAccountUpdate(accounts, matchIdx, updated, explicitIssuer); err != nil {
+		return err
+	}
+	sortAccountsByName(accounts)
+
+	if err := s.SaveAccounts(accounts); err != nil {
+		return fmt.Errorf("save accounts: %w", err)
+	}
+
+	return nil
+}
+
+// applyAccountUpdate validates a sanitized replacement for accounts[idx] and
+// stores it in place. The replacement keeps the current ID, and a blank secret
+// keeps the current secret.
+func applyAccountUpdate(accounts []Account, idx int, updated Account, explicitIssuer bool) error {
+	current := accounts[idx]
+	updated.ID = current.ID
+	if !explicitIssuer && normalizeAccountName(updated.Name) == normalizeAccountName(current.Name) {
+		updated.Issuer = current.Issuer
+		updated.Label = current.Label
+	}
+	if updated.RecoveryCodes == nil {
+		updated.RecoveryCodes = current.RecoveryCodes
+	}
+	if updated.Icon == nil {
+		updated.Icon = current.Icon
 	}
 	if updated.Secret == "" {
-		updated.Secret = accounts[matchIdx].Secret
+		updated.Secret = current.Secret
+		updated.SecretEncoding = current.SecretEncoding
+		updated.EncodingGuess = current.EncodingGuess
+		updated = sanitizeAccount(updated)
 	}
 	if err := ValidateAccountInput(updated.Name, updated.Secret); err != nil {
 		return err
@@ -245,70 +291,58 @@ func (s Service) UpdateAccount(currentName string, updated Account) error {
 	if err := ValidateDigits(updated.Digits); err != nil {
 		return err
 	}
+	if _, err := NormalizeColor(updated.Color); err != nil {
+		return err
+	}
 
 	newNameKey := normalizeAccountName(updated.Name)
-	newSecretKey := normalizeSecret(updated.Secret)
-	originalSecretKey := normalizeSecret(accounts[matchIdx].Secret)
+	newSecretKey := secretIdentity(updated.Secret, updated.SecretEncoding)
+	originalSecretKey := secretIdentity(current.Secret, current.SecretEncoding)
 	for i, account := range accounts {
-		if i == matchIdx {
+		if i == idx {
 			continue
 		}
 
 		if normalizeAccountName(account.Name) == newNameKey {
-			return fmt.Errorf("another account already uses %q", updated.Name)
+			return collisionf(ErrNameCollision, "name", "another account already uses %q", updated.Name)
 		}
-		if newSecretKey != "" && newSecretKey != originalSecretKey && normalizeSecret(account.Secret) == newSecretKey {
-			return fmt.Errorf("another account already uses the same secret")
+		if newSecretKey != "" && newSecretKey != originalSecretKey && secretIdentity(account.Secret, account.SecretEncoding) == newSecretKey {
+			return collisionf(ErrSecretCollision, "secret", "another account already uses the same secret")
 		}
 	}
 
-	accounts[matchIdx] = updated
-	sort.Slice(accounts, func(i, j int) bool {
-		return normalizeAccountName(accounts[i].Name) < normalizeAccountName(accounts[j].Name)
-	})
-
-	if err := s.SaveAccounts(accounts); err != nil {
-		return fmt.Errorf("save accounts: %w", err)
-	}
-
+	accounts[idx] = updated
 	return nil
 }
 
-func (s Service) SetAccountArchived(name string, archived bool) error {
+func (s Service) SetAccountArchived(ref string, archived bool) error {
 	accounts, err := s.LoadAccounts()
 	if err != nil {
 		return fmt.Errorf("load accounts: %w", err)
 	}
 
-	nameKey := normalizeAccountName(name)
-	found := false
-	for i, a := range accounts {
-		if normalizeAccountName(a.Name) == nameKey {
-			accounts[i].Archived = archived
-			found = true
-			break
-		}
-	}
-	if !found {
-		return fmt.Errorf("no account found matching %q", name)
+	idx := FindAccountIndex(accounts, ref)
+	if idx == -1 {
+		return notFoundf("no account found matching %q", ref)
 	}
+	accounts[idx].Archived = archived
 
 	return s.SaveAccounts(accounts)
 }
 
-func (s Service) ImportAccountsFromQR(qrFile string) (ImportResult, error) {
+func (s Service) ImportAccountsFromQR(qrFile string, opts ImportOptions) (ImportResult, error) {
 	payload, err := ReadQRFromFile(qrFile)
 	if err != nil {
-		return ImportResult{}, fmt.Errorf("read QR file: %w", err)
+		return ImportResult{}, invalidInput("qr", "read QR file", err)
 	}
 
 	accounts, err := ParseQRPayload(payload)
 	if err != nil {
-		return ImportResult{}, fmt.Errorf("parse QR payload: %w", err)
+		return ImportResult{}, invalidInput("qr", "parse QR payload", err)
 	}
 
 	if len(accounts) == 0 {
-		return ImportResult{}, fmt.Errorf("no importable accounts found in QR payload")
+		return ImportResult{}, invalidf("qr", "no importable accounts found in QR payload")
 	}
 
 	valid := make([]Account, 0, len(accounts))
@@ -329,10 +363,10 @@ func (s Service) ImportAccountsFromQR(qrFile string) (ImportResult, error) {
 	}
 
 	if len(valid) == 0 {
-		return ImportResult{}, fmt.Errorf("all accounts in the QR payload were skipped")
+		return ImportResult{}, invalidf("qr", "all accounts in the QR payload were skipped")
 	}
 
-	summary, err := s.UpsertAccounts(valid)
+	summary, err := s.ImportAccounts(valid, opts)
 	if err != nil {
 		return ImportResult{}, err
 	}
@@ -343,7 +377,12 @@ func (s Service) ImportAccountsFromQR(qrFile string) (ImportResult, error) {
 	}, nil
 }
 
-func (s Service) MigrateLegacyFrom(path string, removeSource bool) (UpsertSummary, error) {
+func (s Service) MigrateLegacyFrom(path string, removeSource bool, opts ImportOptions) (UpsertSummary, error) {
+	strategy, err := ParseMergeStrategy(string(opts.Strategy))
+	if err != nil {
+		return UpsertSummary{}, err
+	}
+
 	path = strings.TrimSpace(path)
 	if path == "" {
 		path = s.legacyPath()
@@ -362,11 +401,13 @@ func (s Service) MigrateLegacyFrom(path string, removeSource bool) (UpsertSummar
 		return UpsertSummary{}, err
 	}
 
-	merged, summary := upsertAccounts(existing, legacyAccounts)
-	sort.Slice(merged, func(i, j int) bool {
-		return normalizeAccountName(merged[i].Name) < normalizeAccountName(merged[j].Name)
-	})
+	merged, summary := mergeAccounts(existing, legacyAccounts, strategy)
+	summary.DryRun = opts.DryRun
+	if opts.DryRun {
+		return summary, nil
+	}
 
+	sortAccountsByName(merged)
 	if err := s.SaveAccounts(merged); err != nil {
 		return UpsertSummary{}, err
 	}
@@ -405,7 +446,7 @@ func (s Service) ensureInitialized() error {
 			return nil
 		}
 
-		_, err = s.MigrateLegacyFrom(legacy, true)
+		_, err = s.MigrateLegacyFrom(legacy, true, ImportOptions{})
 		return err
 	} else if !os.IsNotExist(err) {
 		return err
@@ -413,7 +454,7 @@ func (s Service) ensureInitialized() error {
 
 	if legacy := s.legacyPath(); legacy != "" && legacy != storePath {
 		if _, err := os.Stat(legacy); err == nil {
-			_, err := s.MigrateLegacyFrom(legacy, true)
+			_, err := s.MigrateLegacyFrom(legacy, true, ImportOptions{})
 			return err
 		}
 	}
@@ -434,32 +475,40 @@ func (s Service) ensureParentDirs() error {
 	return nil
 }
 
-func (s Service) decodeStoredAccounts(data []byte) ([]Account, error) {
+// decodeStoredAccounts reads a store file, encrypted or plaintext, and
+// migrates it to the current schema. migrated reports whether the accounts
+// differ from what is on disk; a plaintext store is encrypted right away.
+func (s Service) decodeStoredAccounts(data []byte) (accounts []Account, migrated bool, err error) {
+	var payload storePayload
 	if bytes.HasPrefix(data, []byte(storeMagic)) {
 		key, err := s.loadKey()
 		if err != nil {
-			return nil, err
-		}
-		plaintext, err := decryptPayload(data, key)
-		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
-		var accounts []Account
-		if err := json.Unmarshal(plaintext, &accounts); err != nil {
-			return nil, err
+		if payload, err = decryptStore(data, key); err != nil {
+			return nil, false, err
 		}
-		return accounts, nil
+		return payload.Accounts, payload.migrate(), nil
 	}
 
-	var accounts []Account
-	if err := json.Unmarshal(data, &accounts); err != nil {
-		return nil, fmt.Errorf("decode encrypted store: %w", err)
+	if payload, err = decodeStorePayload(data); err != nil {
+		return nil, false, err
+	}
+	payload.migrate()
+	if err := s.SaveAccounts(payload.Accounts); err != nil {
+		return nil, false, err
 	}
+	return payload.Accounts, false, nil
+}
 
-	if err := s.SaveAccounts(accounts); err != nil {
-		return nil, err
+// decryptStore opens an encrypted store file and decodes its payload without
+// migrating it.
+func decryptStore(data, key []byte) (storePayload, error) {
+	plaintext, err := decryptPayload(data, key)
+	if err != nil {
+		return storePayload{}, err
 	}
-	return accounts, nil
+	return decodeStorePayload(plaintext)
 }
 
 func (s Service) loadExistingStoreAccounts() ([]Account, error) {
@@ -470,19 +519,20 @@ func (s Service) loadExistingStoreAccounts() ([]Account, error) {
 	if err != nil {
 		return nil, err
 	}
-	return s.decodeStoredAccounts(data)
+	accounts, _, err := s.decodeStoredAccounts(data)
+	return accounts, err
 }
 
 func (s Service) loadKey() ([]byte, error) {
 	data, err := os.ReadFile(s.keyPath())
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("encryption key not found at %s", s.keyPath())
+			return nil, storeError(ErrWrongKey, "encryption key not found at "+s.keyPath(), nil)
 		}
-		return nil, err
+		return nil, deniedAsLocked(err)
 	}
 	if len(data) != 32 {
-		return nil, fmt.Errorf("invalid key length in %s", s.keyPath())
+		return nil, storeError(ErrWrongKey, "invalid key length in "+s.keyPath(), nil)
 	}
 	return data, nil
 }
@@ -491,11 +541,11 @@ func (s Service) loadOrCreateKey() ([]byte, error) {
 	keyPath := s.keyPath()
 	if data, err := os.ReadFile(keyPath); err == nil {
 		if len(data) != 32 {
-			return nil, fmt.Errorf("invalid key length in %s", keyPath)
+			return nil, storeError(ErrWrongKey, "invalid key length in "+keyPath, nil)
 		}
 		return data, nil
 	} else if !os.IsNotExist(err) {
-		return nil, err
+		return nil, deniedAsLocked(err)
 	}
 
 	key := make([]byte, 32)
@@ -543,7 +593,7 @@ func encryptPayload(plaintext, key []byte) ([]byte, error) {
 func decryptPayload(data, key []byte) ([]byte, error) {
 	header := []byte(storeMagic)
 	if !bytes.HasPrefix(data, header) {
-		return nil, fmt.Errorf("unknown encrypted store format")
+		return nil, storeError(ErrCorruptStore, "unknown encrypted store format", nil)
 	}
 
 	block, err := aes.NewCipher(key)
@@ -557,14 +607,29 @@ func decryptPayload(data, key []byte) ([]byte, error) {
 	}
 
 	if len(data) < len(header)+gcm.NonceSize() {
-		return nil, fmt.Errorf("encrypted store is truncated")
+		return nil, storeError(ErrCorruptStore, "encrypted store is truncated", nil)
 	}
 
 	nonceStart := len(header)
 	nonceEnd := nonceStart + gcm.NonceSize()
 	nonce := data[nonceStart:nonceEnd]
 	ciphertext := data[nonceEnd:]
-	return gcm.Open(nil, nonce, ciphertext, header)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		// GCM cannot tell a different key from damaged ciphertext; a key
+		// mix-up is the likelier cause.
+		return nil, storeError(ErrWrongKey, "decrypt store", err)
+	}
+	return plaintext, nil
+}
+
+// deniedAsLocked reports a permission failure on the store or key as
+// ErrStoreLocked and passes other errors through.
+func deniedAsLocked(err error) error {
+	if errors.Is(err, fs.ErrPermission) {
+		return storeError(ErrStoreLocked, "store is not accessible", err)
+	}
+	return err
 }
 
 func loadPlaintextAccounts(path string) ([]Account, error) {
@@ -622,48 +687,33 @@ func (s Service) legacyPath() string {
 }
 
 func upsertAccounts(existing []Account, incoming []Account) ([]Account, UpsertSummary) {
-	summary := UpsertSummary{
-		Changes: make([]AccountChange, 0, len(incoming)),
-	}
-
-	indexByName := buildNameIndex(existing)
-	indexBySecret := buildSecretIndex(existing)
-
-	for _, candidate := range incoming {
-		candidate = sanitizeAccount(candidate)
-		nameKey := normalizeAccountName(candidate.Name)
-		secretKey := normalizeSecret(candidate.Secret)
-
-		matchIdx := -1
-		if idx, ok := indexByName[nameKey]; ok {
-			matchIdx = idx
-		}
-		if matchIdx == -1 {
-			if idx, ok := indexBySecret[secretKey]; ok {
-				matchIdx = idx
-			}
-		}
-
-		if matchIdx >= 0 {
-			existing[matchIdx] = candidate
-			summary.Replaced++
-			summary.Changes = append(summary.Changes, AccountChange{Name: candidate.Name, Action: "replaced"})
-		} else {
-			existing = append(existing, candidate)
-			summary.Added++
-			summary.Changes = append(summary.Changes, AccountChange{Name: candidate.Name, Action: "added"})
-		}
-
-		indexByName = buildNameIndex(existing)
-		indexBySecret = buildSecretIndex(existing)
-	}
-
-	return existing, summary
+	return mergeAccounts(existing, incoming, MergeReplace)
 }
 
 func sanitizeAccount(account Account) Account {
+	account.ID = strings.ToLower(strings.TrimSpace(account.ID))
 	account.Name = strings.TrimSpace(account.Name)
-	account.Secret = strings.TrimSpace(account.Secret)
+	account.Issuer = strings.TrimSpace(account.Issuer)
+	account.Label = strings.TrimSpace(account.Label)
+	switch {
+	case account.Issuer == "" && account.Label == "":
+		account.Issuer, account.Label, _ = SplitAccountName(account.Name)
+	case account.Label == "":
+		account.Label = strings.TrimSpace(strings.TrimPrefix(account.Name, account.Issuer+":"))
+	}
+	if account.Name == "" {
+		account.Name = AccountName(account.Issuer, account.Label)
+	}
+	if account.SecretEncoding != EncodingRaw {
+		account.Secret = strings.TrimSpace(account.Secret)
+	}
+	if encoding, err := NormalizeSecretEncoding(account.SecretEncoding); err == nil {
+		account.SecretEncoding = encoding
+	}
+	if account.SecretEncoding == "" {
+		account.SecretEncoding, account.EncodingGuess = DetectSecretEncoding(account.Secret)
+	}
+	account.Secret = CanonicalSecret(account.Secret, account.SecretEncoding)
 	if account.Interval <= 0 {
 		account.Interval = DefaultInterval
 	}
@@ -678,6 +728,10 @@ func sanitizeAccount(account Account) Account {
 		account.Algorithm = AlgorithmSHA1
 	}
 	account.Notes = strings.TrimSpace(account.Notes)
+	if color, err := NormalizeColor(account.Color); err == nil {
+		account.Color = color
+	}
+	account.RecoveryCodes = sanitizeRecoveryCodes(account.RecoveryCodes)
 	return account
 }
 
@@ -714,7 +768,7 @@ func buildNameIndex(accounts []Account) map[string]int {
 func buildSecretIndex(accounts []Account) map[string]int {
 	index := make(map[string]int, len(accounts))
 	for i, account := range accounts {
-		index[normalizeSecret(account.Secret)] = i
+		index[secretIdentity(account.Secret, account.SecretEncoding)] = i
 	}
 	return index
 }
@@ -723,6 +777,41 @@ func normalizeAccountName(name string) string {
 	return strings.ToLower(strings.TrimSpace(name))
 }
 
+// migrateSecretEncodings tags accounts saved before encodings were recorded,
+// canonicalizing their secrets. It reports whether anything changed.
+func migrateSecretEncodings(accounts []Account) bool {
+	changed := false
+	for i, account := range accounts {
+		if account.SecretEncoding != "" {
+			continue
+		}
+
+		secret, encoding, guessed := detectLegacyEncoding(account.Secret)
+		if encoding == "" {
+			continue
+		}
+		accounts[i].SecretEncoding = encoding
+		accounts[i].EncodingGuess = guessed
+		accounts[i].Secret = CanonicalSecret(secret, encoding)
+		changed = true
+	}
+	return changed
+}
+
+// migrateIssuerLabels fills Issuer and Label from the Issuer:Label name
+// convention for accounts saved before the fields existed.
+func migrateIssuerLabels(accounts []Account) bool {
+	changed := false
+	for i, account := range accounts {
+		if account.Issuer != "" || account.Label != "" || account.Name == "" {
+			continue
+		}
+		accounts[i].Issuer, accounts[i].Label, _ = SplitAccountName(account.Name)
+		changed = true
+	}
+	return changed
+}
+
 func normalizeSecret(secret string) string {
 	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
 }
@@ -1,6 +1,7 @@
 package trustpin
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -493,3 +494,166 @@ func TestParseOtpauthURIWithHOTP(t *testing.T) {
 		t.Fatalf("unexpected parsed values: account=%q secret=%q interval=%d digits=%d algorithm=%q", account, secret, interval, digits, algorithm)
 	}
 }
+
+func TestParseOtpauthAccountIssuerAndLabel(t *testing.T) {
+	tests := []struct {
+		uri    string
+		issuer string
+		label  string
+		name   string
+	}{
+		{"otpauth://totp/ACME%20Co:john%2Fdoe@example.com?secret=JBSWY3DPEHPK3PXP&issuer=ACME%20Co", "ACME Co", "john/doe@example.com", "ACME Co:john/doe@example.com"},
+		{"otpauth://totp/Example:team/alice?secret=JBSWY3DPEHPK3PXP", "Example", "team/alice", "Example:team/alice"},
+		{"otpauth://totp/alice%3Awork?secret=JBSWY3DPEHPK3PXP&issuer=Acme", "Acme", "alice:work", "Acme:alice:work"},
+		{"otpauth://totp/Old:alice?secret=JBSWY3DPEHPK3PXP&issuer=New", "New", "Old:alice", "New:Old:alice"},
+		{"otpauth://totp/?secret=JBSWY3DPEHPK3PXP&issuer=Solo", "Solo", "Solo", "Solo"},
+	}
+
+	for _, tt := range tests {
+		account, err := ParseOtpauthAccount(tt.uri)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tt.uri, err)
+		}
+		if account.Issuer != tt.issuer || account.Label != tt.label || account.Name != tt.name {
+			t.Errorf("parse %q = issuer %q label %q name %q; want %q %q %q", tt.uri, account.Issuer, account.Label, account.Name, tt.issuer, tt.label, tt.name)
+		}
+	}
+}
+
+func TestBuildOTPAuthURIEscapesLabel(t *testing.T) {
+	original := Account{Issuer: "ACME Co", Label: "ops:a/b@example.com", Secret: "JBSWY3DPEHPK3PXP"}
+	uri := BuildOTPAuthURI(original)
+	if !strings.HasPrefix(uri, "otpauth://totp/ACME%20Co:ops%3Aa%2Fb@example.com?") {
+		t.Fatalf("expected escaped label path, got %q", uri)
+	}
+
+	parsed, err := ParseOtpauthAccount(uri)
+	if err != nil {
+		t.Fatalf("parse generated URI: %v", err)
+	}
+	if parsed.Issuer != original.Issuer || parsed.Label != original.Label {
+		t.Fatalf("expected round trip to keep issuer and label, got %q / %q", parsed.Issuer, parsed.Label)
+	}
+}
+
+func TestLoadAccountsSplitsLegacyNames(t *testing.T) {
+	tmpDir := t.TempDir()
+	service := Service{
+		StorePath: filepath.Join(tmpDir, "accounts.enc"),
+		KeyPath:   filepath.Join(tmpDir, "accounts.key"),
+	}
+
+	writeStorePayload(t, service, []Account{
+		{Name: "AWS SSO:prod", Secret: "JBSWY3DPEHPK3PXP"},
+		{Name: "Personal", Secret: "MFRGGZDFMZTWQ2LK"},
+	})
+
+	loaded, err := service.LoadAccounts()
+	if err != nil {
+		t.Fatalf("load accounts: %v", err)
+	}
+	if loaded[0].Issuer != "AWS SSO" || loaded[0].Label != "prod" {
+		t.Fatalf("expected split issuer and label, got %+v", loaded[0])
+	}
+	if loaded[1].Issuer != "" || loaded[1].Label != "Personal" {
+		t.Fatalf("expected label-only account, got %+v", loaded[1])
+	}
+
+	if err := service.UpdateAccount(loaded[1].ID, Account{Name: "Personal", Favorite: true}); err != nil {
+		t.Fatalf("update account: %v", err)
+	}
+	account, err := service.GetAccount(loaded[1].ID)
+	if err != nil {
+		t.Fatalf("get account: %v", err)
+	}
+	if account.Issuer != "" || account.Label != "Personal" || !account.Favorite {
+		t.Fatalf("expected issuer and label to survive an update, got %+v", account)
+	}
+}
+
+func TestServiceErrorsAreCategorised(t *testing.T) {
+	tmpDir := t.TempDir()
+	service := Service{
+		StorePath: filepath.Join(tmpDir, "accounts.enc"),
+		KeyPath:   filepath.Join(tmpDir, "accounts.key"),
+	}
+	if err := service.SaveAccounts([]Account{
+		{Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP", Interval: 30, Digits: 6},
+		{Name: "Slack:me", Secret: "GEZDGNBVGY3TQOJQ", Interval: 30, Digits: 6},
+	}); err != nil {
+		t.Fatalf("save accounts: %v", err)
+	}
+
+	_, deleteErr := service.DeleteAccount("missing")
+	cases := []struct {
+		name  string
+		err   error
+		kind  error
+		field string
+	}{
+		{"missing account", deleteErr, ErrNotFound, ""},
+		{"empty secret", ValidateAccountInput("x", ""), ErrInvalidSecret, "secret"},
+		{"bad digits", ValidateDigits(11), ErrValidation, "digits"},
+		{"name collision", service.UpdateAccount("GitHub:work", Account{Name: "Slack:me"}), ErrNameCollision, "name"},
+		{"secret collision", service.UpdateAccount("GitHub:work", Account{Name: "GitHub:work", Secret: "GEZDGNBVGY3TQOJQ"}), ErrSecretCollision, "secret"},
+		{"bad otpauth uri", parseErr(ParseOtpauthAccount("https://example.com")), ErrValidation, "uri"},
+		{"bad migration data", parseErr(ParseQRPayload("otpauth-migration://offline?data=%%%")), ErrValidation, "qr"},
+		{"bad policy", HealthPolicy{Rules: map[string]HealthRuleSetting{"nope": {}}}.Validate(), ErrInvalidPolicy, ""},
+	}
+	for _, c := range cases {
+		if !errors.Is(c.err, c.kind) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.kind, c.err)
+		}
+		if errors.Is(c.kind, ErrConflict) && !errors.Is(c.err, ErrConflict) {
+			t.Errorf("%s: expected a collision to also be a conflict", c.name)
+		}
+		if got := ErrorField(c.err); got != c.field {
+			t.Errorf("%s: expected field %q, got %q", c.name, c.field, got)
+		}
+	}
+}
+
+func parseErr[T any](_ T, err error) error {
+	return err
+}
+
+func TestStoreFailuresAreTyped(t *testing.T) {
+	cases := []struct {
+		name   string
+		damage func(Service) error
+		kind   error
+	}{
+		{"garbage store", func(s Service) error {
+			return os.WriteFile(s.StorePath, []byte("not a store"), 0o600)
+		}, ErrCorruptStore},
+		{"truncated store", func(s Service) error {
+			return os.WriteFile(s.StorePath, []byte(storeMagic+"abc"), 0o600)
+		}, ErrCorruptStore},
+		{"other key", func(s Service) error {
+			return os.WriteFile(s.KeyPath, []byte(strings.Repeat("k", 32)), 0o600)
+		}, ErrWrongKey},
+		{"short key", func(s Service) error {
+			return os.WriteFile(s.KeyPath, []byte("short"), 0o600)
+		}, ErrWrongKey},
+		{"missing key", func(s Service) error {
+			return os.Remove(s.KeyPath)
+		}, ErrWrongKey},
+	}
+
+	for _, c := range cases {
+		tmpDir := t.TempDir()
+		service := Service{
+			StorePath: filepath.Join(tmpDir, "accounts.enc"),
+			KeyPath:   filepath.Join(tmpDir, "accounts.key"),
+		}
+		if err := service.SaveAccounts([]Account{{Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP", Interval: 30, Digits: 6}}); err != nil {
+			t.Fatalf("%s: save accounts: %v", c.name, err)
+		}
+		if err := c.damage(service); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if _, err := service.LoadAccounts(); !errors.Is(err, c.kind) {
+			t.Errorf("%s: expected %v, got %v", c.name, c.kind, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package trustpin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StoreBackupGenerations is how many earlier versions of the store are kept
+// beside it as <store>.bak.1 (newest) to <store>.bak.N.
+const StoreBackupGenerations = 3
+
+// BackupGeneration describes one kept copy of the store.
+type BackupGeneration struct {
+	Generation int       `json:"generation"`
+	Path       string    `json:"path"`
+	ModTime    time.Time `json:"modTime"`
+	Accounts   int       `json:"accounts"`
+	Intact     bool      `json:"intact"`
+	Problem    string    `json:"problem,omitempty"`
+}
+
+func backupPath(storePath string, generation int) string {
+	return fmt.Sprintf("%s.bak.%d", storePath, generation)
+}
+
+// writeStore encrypts accounts in a current-schema envelope and replaces the
+// store atomically through a temporary file. The store being replaced becomes
+// backup generation 1 when it still decrypts, so a damaged file never pushes
+// a good generation out, and when the accounts or the schema actually
+// changed, so recording a use does not either. Its vault metadata carries
+// over, and a store from a newer schema is never overwritten.
+func (s Service) writeStore(accounts []Account, key []byte, now time.Time) error {
+	path := s.storePath()
+	payload := storePayload{
+		SchemaVersion: StoreSchemaVersion,
+		Vault:         StoreMetadata{ID: NewAccountID(), CreatedAt: now.UTC()},
+		Accounts:      accounts,
+	}
+	if payload.Accounts == nil {
+		payload.Accounts = []Account{}
+	}
+
+	var previous []Account
+	current, err := os.ReadFile(path)
+	intact, upgraded := false, false
+	if err == nil {
+		if existing, err := decryptStore(current, key); err == nil {
+			if existing.SchemaVersion > StoreSchemaVersion {
+				return newerStoreError(existing.SchemaVersion)
+			}
+			if existing.Vault.ID != "" {
+				payload.Vault = existing.Vault
+			}
+			previous = existing.Accounts
+			intact, upgraded = true, existing.SchemaVersion < StoreSchemaVersion
+		}
+	}
+	changed := stampAccounts(payload.Accounts, previous, now)
+	payload.Vault.UpdatedAt = now.UTC()
+
+	plaintext, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return err
+	}
+	data, err := encryptPayload(plaintext, key)
+	if err != nil {
+		return err
+	}
+
+	if intact && (changed || upgraded) {
+		if err := rotateBackups(path, current); err != nil {
+			return fmt.Errorf("rotate store backups: %w", err)
+		}
+	}
+	return writeFileAtomic(path, data)
+}
+
+func rotateBackups(storePath string, current []byte) error {
+	for generation := StoreBackupGenerations; generation > 1; generation-- {
+		err := os.Rename(backupPath(storePath, generation-1), backupPath(storePath, generation))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return writeFileAtomic(backupPath(storePath, 1), current)
+}
+
+// writeFileAtomic writes data to a temporary file beside path and renames it
+// into place, so a crash never leaves a half-written file behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if err := tmp.Chmod(0o600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// StoreBackups lists the backup generations on disk, newest first, and
+// checks whether each one opens with the current key.
+func (s Service) StoreBackups() []BackupGeneration {
+	key, keyErr := s.loadKey()
+
+	var backups []BackupGeneration
+	for generation := 1; generation <= StoreBackupGenerations; generation++ {
+		path := backupPath(s.storePath(), generation)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		backup := BackupGeneration{Generation: generation, Path: path, ModTime: info.ModTime()}
+		data, err := os.ReadFile(path)
+		switch {
+		case err != nil:
+			backup.Problem = err.Error()
+		case keyErr != nil:
+			backup.Problem = keyErr.Error()
+		default:
+			payload, err := decryptStore(data, key)
+			if err != nil {
+				backup.Problem = err.Error()
+			} else {
+				backup.Intact = true
+				backup.Accounts = len(payload.Accounts)
+			}
+		}
+		backups = append(backups, backup)
+	}
+	return backups
+}
+
+// RestoreBackup replaces the store with the newest backup generation that
+// opens with the current key. It refuses while the store itself still opens,
+// since that would silently roll back recent changes, unless force is set.
+// The replaced store is kept beside it with a .damaged-<time> suffix, or
+// .replaced-<time> when it was intact, and the path is returned.
+func (s Service) RestoreBackup(now time.Time, force bool) (BackupGeneration, string, error) {
+	if _, err := s.loadKey(); err != nil {
+		return BackupGeneration{}, "", err
+	}
+	suffix := ".damaged-"
+	if s.storeIntact() {
+		if !force {
+			return BackupGeneration{}, "", conflictf("", "the store at %s is intact; restoring a backup would discard recent changes", s.storePath())
+		}
+		suffix = ".replaced-"
+	}
+
+	for _, backup := range s.StoreBackups() {
+		if !backup.Intact {
+			continue
+		}
+		data, err := os.ReadFile(backup.Path)
+		if err != nil {
+			return BackupGeneration{}, "", err
+		}
+
+		path := s.storePath()
+		kept := ""
+		if _, err := os.Stat(path); err == nil {
+			kept = path + suffix + now.UTC().Format("20060102T150405Z")
+			if err := os.Rename(path, kept); err != nil {
+				return BackupGeneration{}, "", fmt.Errorf("move store aside: %w", err)
+			}
+		}
+		if err := writeFileAtomic(path, data); err != nil {
+			return BackupGeneration{}, "", err
+		}
+		return backup, kept, nil
+	}
+	return BackupGeneration{}, "", notFoundf("no intact backup generation to restore from")
+}
+
+// storeIntact reports whether the store exists and decrypts and decodes with
+// the current key. It changes nothing on disk.
+func (s Service) storeIntact() bool {
+	data, err := os.ReadFile(s.storePath())
+	if err != nil {
+		return false
+	}
+	if !bytes.HasPrefix(data, []byte(storeMagic)) {
+		_, err := decodeStorePayload(data)
+		return err == nil
+	}
+	key, err := s.loadKey()
+	if err != nil {
+		return false
+	}
+	_, err = decryptStore(data, key)
+	return err == nil
+}
@@ -0,0 +1,299 @@
+package trustpin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+const (
+	BatchDelete      = "delete"
+	BatchArchive     = "archive"
+	BatchSetTags     = "set-tags"
+	BatchSetFavorite = "set-favorite"
+	BatchUpdate      = "update"
+
+	BatchStatusOK     = "ok"
+	BatchStatusFailed = "failed"
+)
+
+// BatchOperation is one change in a batch. ID accepts an account ID or name.
+// Archived defaults to true for archive operations.
+type BatchOperation struct {
+	Op       string        `json:"op"`
+	ID       string        `json:"id"`
+	Archived *bool         `json:"archived,omitempty"`
+	Tags     []string      `json:"tags,omitempty"`
+	Favorite *bool         `json:"favorite,omitempty"`
+	Fields   *AccountPatch `json:"fields,omitempty"`
+}
+
+// AccountPatch lists the fields an update operation changes. Nil fields keep
+// their current value.
+type AccountPatch struct {
+	Name      *string   `json:"name,omitempty"`
+	Issuer    *string   `json:"issuer,omitempty"`
+	Label     *string   `json:"label,omitempty"`
+	Secret    *string   `json:"secret,omitempty"`
+	Encoding  *string   `json:"encoding,omitempty"`
+	Interval  *int64    `json:"interval,omitempty"`
+	Digits    *int      `json:"digits,omitempty"`
+	Algorithm *string   `json:"algorithm,omitempty"`
+	Type      *string   `json:"type,omitempty"`
+	Counter   *int64    `json:"counter,omitempty"`
+	Tags      *[]string `json:"tags,omitempty"`
+	Favorite  *bool     `json:"favorite,omitempty"`
+	Notes     *string   `json:"notes,omitempty"`
+	Color     *string   `json:"color,omitempty"`
+	SortOrder *int      `json:"sortOrder,omitempty"`
+	Archived  *bool     `json:"archived,omitempty"`
+}
+
+type BatchOptions struct {
+	DryRun bool `json:"dryRun"`
+}
+
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     string `json:"id,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchSummary reports every operation in a batch. Applied is false when any
+// operation failed or the batch was a dry run; nothing is saved in that case.
+type BatchSummary struct {
+	Applied   bool          `json:"applied"`
+	DryRun    bool          `json:"dryRun,omitempty"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []BatchResult `json:"results"`
+}
+
+// ParseBatchOperations reads one JSON operation per line. Blank lines and
+// lines starting with # are ignored.
+func ParseBatchOperations(r io.Reader) ([]BatchOperation, error) {
+	var ops []BatchOperation
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var op BatchOperation
+		decoder := json.NewDecoder(strings.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&op); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		ops = append(ops, op)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read batch: %w", err)
+	}
+	return ops, nil
+}
+
+// ApplyBatch runs ops in order against a single load of the store and saves
+// once. If any operation fails the store is left untouched; the remaining
+// operations are still checked so every problem is reported at once.
+func (s Service) ApplyBatch(ops []BatchOperation, opts BatchOptions) (BatchSummary, error) {
+	if len(ops) == 0 {
+		return BatchSummary{}, invalidf("operations", "batch contains no operations")
+	}
+
+	accounts, err := s.LoadAccounts()
+	if err != nil {
+		return BatchSummary{}, fmt.Errorf("load accounts: %w", err)
+	}
+
+	accounts, summary := applyBatch(accounts, ops)
+	summary.DryRun = opts.DryRun
+	if summary.Failed > 0 || opts.DryRun {
+		return summary, nil
+	}
+
+	sortAccountsByName(accounts)
+	if err := s.SaveAccounts(accounts); err != nil {
+		return BatchSummary{}, fmt.Errorf("save accounts: %w", err)
+	}
+	summary.Applied = true
+	return summary, nil
+}
+
+// applyBatch applies ops to a copy of accounts. Later operations see the
+// effect of earlier ones, so an account deleted by one operation cannot be
+// updated by the next.
+func applyBatch(accounts []Account, ops []BatchOperation) ([]Account, BatchSummary) {
+	working := slices.Clone(accounts)
+	summary := BatchSummary{Results: make([]BatchResult, 0, len(ops))}
+
+	for i, op := range ops {
+		result := BatchResult{Index: i, Op: strings.ToLower(strings.TrimSpace(op.Op)), ID: strings.TrimSpace(op.ID)}
+		next, name, err := applyBatchOperation(working, op)
+		if err != nil {
+			result.Status = BatchStatusFailed
+			result.Error = err.Error()
+			summary.Failed++
+		} else {
+			working = next
+			result.Status = BatchStatusOK
+			result.Name = name
+			summary.Succeeded++
+		}
+		summary.Results = append(summary.Results, result)
+	}
+	return working, summary
+}
+
+func applyBatchOperation(accounts []Account, op BatchOperation) ([]Account, string, error) {
+	ref := strings.TrimSpace(op.ID)
+	if ref == "" {
+		return nil, "", invalidf("id", "account id is required")
+	}
+	idx := FindAccountIndex(accounts, ref)
+	if idx == -1 {
+		return nil, "", notFoundf("no account found matching %q", ref)
+	}
+	name := accounts[idx].Name
+
+	switch strings.ToLower(strings.TrimSpace(op.Op)) {
+	case BatchDelete:
+		return slices.Delete(slices.Clone(accounts), idx, idx+1), name, nil
+	case BatchArchive:
+		archived := op.Archived == nil || *op.Archived
+		next := slices.Clone(accounts)
+		next[idx].Archived = archived
+		return next, name, nil
+	case BatchSetTags:
+		next := slices.Clone(accounts)
+		next[idx].Tags = normalizeTags(op.Tags)
+		return next, name, nil
+	case BatchSetFavorite:
+		if op.Favorite == nil {
+			return nil, "", invalidf("favorite", "set-favorite requires \"favorite\"")
+		}
+		next := slices.Clone(accounts)
+		next[idx].Favorite = *op.Favorite
+		return next, name, nil
+	case BatchUpdate:
+		if op.Fields == nil {
+			return nil, "", invalidf("fields", "update requires \"fields\"")
+		}
+		updated, explicitIssuer, err := op.Fields.apply(accounts[idx])
+		if err != nil {
+			return nil, "", err
+		}
+		next := slices.Clone(accounts)
+		if err := applyAccountUpdate(next, idx, updated, explicitIssuer); err != nil {
+			return nil, "", err
+		}
+		return next, next[idx].Name, nil
+	default:
+		return nil, "", invalidf("op", "unsupported operation %q (use delete, archive, set-tags, set-favorite, or update)", op.Op)
+	}
+}
+
+// apply returns current with the patch applied and sanitized, and whether the
+// issuer or label were set explicitly.
+func (p AccountPatch) apply(current Account) (Account, bool, error) {
+	next := current
+	explicitIssuer := p.Issuer != nil || p.Label != nil
+	if p.Name != nil {
+		next.Name = *p.Name
+		if !explicitIssuer {
+			next.Issuer, next.Label = "", ""
+		}
+	}
+	if explicitIssuer {
+		if p.Issuer != nil {
+			next.Issuer = *p.Issuer
+		}
+		if p.Label != nil {
+			next.Label = *p.Label
+		}
+		if p.Name == nil {
+			next.Name = AccountName(next.Issuer, next.Label)
+		}
+	}
+	if p.Secret != nil {
+		encoding := ""
+		if p.Encoding != nil {
+			normalized, err := NormalizeSecretEncoding(*p.Encoding)
+			if err != nil {
+				return Account{}, false, err
+			}
+			encoding = normalized
+		}
+		if err := ValidateSecret(*p.Secret, encoding); err != nil {
+			return Account{}, false, err
+		}
+		next.Secret = *p.Secret
+		next.SecretEncoding = encoding
+		next.EncodingGuess = false
+	}
+	if p.Interval != nil {
+		if *p.Interval <= 0 {
+			return Account{}, false, invalidf("interval", "interval must be a positive integer")
+		}
+		next.Interval = *p.Interval
+	}
+	if p.Digits != nil {
+		next.Digits = *p.Digits
+	}
+	if p.Algorithm != nil {
+		next.Algorithm = *p.Algorithm
+	}
+	if p.Type != nil {
+		next.Type = *p.Type
+	}
+	if p.Counter != nil {
+		next.Counter = *p.Counter
+	}
+	if p.Tags != nil {
+		next.Tags = normalizeTags(*p.Tags)
+	}
+	if p.Favorite != nil {
+		next.Favorite = *p.Favorite
+	}
+	if p.Notes != nil {
+		next.Notes = *p.Notes
+	}
+	if p.Color != nil {
+		color, err := NormalizeColor(*p.Color)
+		if err != nil {
+			return Account{}, false, err
+		}
+		next.Color = color
+	}
+	if p.SortOrder != nil {
+		next.SortOrder = *p.SortOrder
+	}
+	if p.Archived != nil {
+		next.Archived = *p.Archived
+	}
+	return sanitizeAccount(next), explicitIssuer, nil
+}
+
+// normalizeTags trims tags and drops blanks and case-insensitive duplicates.
+func normalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || slices.ContainsFunc(out, func(existing string) bool { return strings.EqualFold(existing, tag) }) {
+			continue
+		}
+		out = append(out, tag)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+package trustpin
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestApplyBatchOperations(t *testing.T) {
+	accounts := []Account{
+		sanitizeAccount(Account{ID: "11111111-1111-4111-8111-111111111111", Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP"}),
+		sanitizeAccount(Account{ID: "22222222-2222-4222-8222-222222222222", Name: "AWS:root", Secret: "KRSXG5CTMVRXEZLU"}),
+		sanitizeAccount(Account{ID: "33333333-3333-4333-8333-333333333333", Name: "Slack", Secret: "MFRGGZDFMZTWQ2LK"}),
+	}
+	favorite := true
+	notes := "rotate yearly"
+
+	next, summary := applyBatch(accounts, []BatchOperation{
+		{Op: BatchSetTags, ID: "11111111-1111-4111-8111-111111111111", Tags: []string{"dev", " DEV ", "work"}},
+		{Op: BatchSetFavorite, ID: "aws:root", Favorite: &favorite},
+		{Op: BatchArchive, ID: "33333333-3333-4333-8333-333333333333"},
+		{Op: BatchUpdate, ID: "GitHub:work", Fields: &AccountPatch{Notes: &notes}},
+		{Op: BatchDelete, ID: "22222222-2222-4222-8222-222222222222"},
+	})
+
+	if summary.Failed != 0 || summary.Succeeded != 5 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if len(next) != 2 {
+		t.Fatalf("expected one account to be deleted, got %d", len(next))
+	}
+	if !slices.Equal(next[0].Tags, []string{"dev", "work"}) || next[0].Notes != notes {
+		t.Fatalf("unexpected updated account: %+v", next[0])
+	}
+	if !next[1].Archived {
+		t.Fatalf("expected archive to default to true")
+	}
+	if len(accounts) != 3 || accounts[0].Tags != nil {
+		t.Fatalf("expected the input accounts to be left untouched")
+	}
+}
+
+func TestApplyBatchReportsEveryFailure(t *testing.T) {
+	accounts := []Account{
+		sanitizeAccount(Account{ID: "11111111-1111-4111-8111-111111111111", Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP"}),
+		sanitizeAccount(Account{ID: "22222222-2222-4222-8222-222222222222", Name: "AWS:root", Secret: "KRSXG5CTMVRXEZLU"}),
+	}
+	taken := "AWS:root"
+
+	_, summary := applyBatch(accounts, []BatchOperation{
+		{Op: BatchDelete, ID: "11111111-1111-4111-8111-111111111111"},
+		{Op: BatchArchive, ID: "11111111-1111-4111-8111-111111111111"},
+		{Op: "rename", ID: "AWS:root"},
+		{Op: BatchUpdate, ID: "AWS:root", Fields: &AccountPatch{Name: &taken}},
+	})
+
+	statuses := make([]string, 0, len(summary.Results))
+	for _, result := range summary.Results {
+		statuses = append(statuses, result.Status)
+	}
+	want := []string{BatchStatusOK, BatchStatusFailed, BatchStatusFailed, BatchStatusOK}
+	if !slices.Equal(statuses, want) {
+		t.Fatalf("expected statuses %v, got %v (%+v)", want, statuses, summary.Results)
+	}
+}
+
+func TestServiceApplyBatchIsAllOrNothing(t *testing.T) {
+	tmpDir := t.TempDir()
+	service := Service{
+		StorePath: filepath.Join(tmpDir, "accounts.enc"),
+		KeyPath:   filepath.Join(tmpDir, "accounts.key"),
+	}
+	if _, err := service.UpsertAccounts([]Account{{Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP"}}); err != nil {
+		t.Fatalf("upsert accounts: %v", err)
+	}
+
+	summary, err := service.ApplyBatch([]BatchOperation{
+		{Op: BatchDelete, ID: "GitHub:work"},
+		{Op: BatchDelete, ID: "missing"},
+	}, BatchOptions{})
+	if err != nil {
+		t.Fatalf("apply batch: %v", err)
+	}
+	if summary.Applied || summary.Failed != 1 {
+		t.Fatalf("expected the batch to be rejected, got %+v", summary)
+	}
+	accounts, err := service.LoadAccounts()
+	if err != nil || len(accounts) != 1 {
+		t.Fatalf("expected the store to be unchanged, accounts=%+v err=%v", accounts, err)
+	}
+
+	summary, err = service.ApplyBatch([]BatchOperation{{Op: BatchDelete, ID: "GitHub:work"}}, BatchOptions{})
+	if err != nil || !summary.Applied {
+		t.Fatalf("expected the batch to apply, summary=%+v err=%v", summary, err)
+	}
+	accounts, err = service.LoadAccounts()
+	if err != nil || len(accounts) != 0 {
+		t.Fatalf("expected the account to be deleted, accounts=%+v err=%v", accounts, err)
+	}
+}
+
+func TestParseBatchOperations(t *testing.T) {
+	input := `# cleanup
+{"op":"delete","id":"GitHub:work"}
+
+{"op":"update","id":"AWS:root","fields":{"notes":"prod"}}
+`
+	ops, err := ParseBatchOperations(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parse batch: %v", err)
+	}
+	if len(ops) != 2 || ops[1].Fields == nil || *ops[1].Fields.Notes != "prod" {
+		t.Fatalf("unexpected operations: %+v", ops)
+	}
+
+	if _, err := ParseBatchOperations(strings.NewReader(`{"op":"delete","name":"x"}`)); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("expected a line-numbered error for unknown fields, got %v", err)
+	}
+}
@@ -0,0 +1,237 @@
+package trustpin
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxIconBytes caps a custom account icon. Icons live inside the encrypted
+// store, so they are kept to small badge-sized images.
+const MaxIconBytes = 64 << 10
+
+// brandCatalogJSON maps common issuers to a display name and color. It ships
+// inside the binary so both dashboards work offline. Until it carries logos,
+// issuers are drawn as monograms.
+//
+//go:embed brands.json
+var brandCatalogJSON []byte
+
+// Brand is how an issuer is drawn in the dashboards.
+type Brand struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	// Known is set when the issuer matched the built-in catalog rather than
+	// getting a color derived from its name.
+	Known bool `json:"known"`
+}
+
+// AccountIcon is a custom image stored, encrypted, with an account.
+type AccountIcon struct {
+	Type string `json:"Type"`
+	Data []byte `json:"Data"`
+}
+
+// fallbackPalette colors issuers the catalog does not know. The pick is a
+// hash of the issuer, so an issuer keeps its color from run to run.
+var fallbackPalette = []string{
+	"#2563eb", "#7c3aed", "#db2777", "#dc2626",
+	"#ea580c", "#ca8a04", "#16a34a", "#0891b2",
+}
+
+var brandCatalog = sync.OnceValue(func() map[string]Brand {
+	var entries []struct {
+		Name    string   `json:"name"`
+		Color   string   `json:"color"`
+		Aliases []string `json:"aliases"`
+	}
+	if err := json.Unmarshal(brandCatalogJSON, &entries); err != nil {
+		panic(fmt.Sprintf("trustpin: invalid brand catalog: %v", err))
+	}
+
+	catalog := make(map[string]Brand)
+	for _, entry := range entries {
+		brand := Brand{Name: entry.Name, Color: entry.Color, Known: true}
+		for _, alias := range entry.Aliases {
+			catalog[brandKey(alias)] = brand
+		}
+	}
+	return catalog
+})
+
+// brandKey folds an issuer to lowercase letters and digits, so "Epic Games"
+// and "epicgames" match the same entry.
+func brandKey(value string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(value) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// LookupBrand finds issuer in the built-in catalog. Besides the whole
+// issuer it tries the first word ("AWS SSO") and the first part of a
+// domain ("github.com").
+func LookupBrand(issuer string) (Brand, bool) {
+	issuer = strings.TrimSpace(issuer)
+	if issuer == "" {
+		return Brand{}, false
+	}
+
+	catalog := brandCatalog()
+	candidates := []string{issuer}
+	if fields := strings.Fields(issuer); len(fields) > 1 {
+		candidates = append(candidates, fields[0])
+	}
+	if host, _, found := strings.Cut(issuer, "."); found {
+		candidates = append(candidates, host)
+	}
+	for _, candidate := range candidates {
+		if brand, ok := catalog[brandKey(candidate)]; ok {
+			return brand, true
+		}
+	}
+	return Brand{}, false
+}
+
+// IssuerBrand returns the catalog brand for issuer, or a stable color picked
+// from its name. An empty issuer has no brand.
+func IssuerBrand(issuer string) Brand {
+	issuer = strings.TrimSpace(issuer)
+	if brand, ok := LookupBrand(issuer); ok {
+		return brand
+	}
+	if issuer == "" {
+		return Brand{}
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(brandKey(issuer)))
+	return Brand{Name: issuer, Color: fallbackPalette[h.Sum32()%uint32(len(fallbackPalette))]}
+}
+
+// AccountBrand resolves the brand for account, with its custom color, if
+// any, taking the place of the issuer's.
+func AccountBrand(account Account) Brand {
+	issuer, _, _ := AccountIssuer(account)
+	brand := IssuerBrand(issuer)
+	if color, err := NormalizeColor(account.Color); err == nil && color != "" {
+		brand.Color = color
+	}
+	return brand
+}
+
+// NormalizeColor accepts "#rgb" or "#rrggbb", with or without the "#", and
+// returns the lowercase "#rrggbb" form. An empty value stays empty.
+func NormalizeColor(color string) (string, error) {
+	value := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(color), "#"))
+	if value == "" {
+		return "", nil
+	}
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+	if _, err := strconv.ParseUint(value, 16, 32); err != nil || len(value) != 6 {
+		return "", invalidf("color", "color %q is not a hex color such as #1f6feb", color)
+	}
+	return "#" + value, nil
+}
+
+// ParseColor splits a "#rrggbb" color into its channels.
+func ParseColor(color string) (r, g, b int, ok bool) {
+	normalized, err := NormalizeColor(color)
+	if err != nil || normalized == "" {
+		return 0, 0, 0, false
+	}
+	v, _ := strconv.ParseUint(normalized[1:], 16, 32)
+	return int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff), true
+}
+
+// NewAccountIcon checks that data is a PNG, JPEG, GIF or WebP image within
+// MaxIconBytes. SVG is refused because it can carry script.
+func NewAccountIcon(data []byte) (*AccountIcon, error) {
+	if len(data) == 0 {
+		return nil, invalidf("icon", "icon file is empty")
+	}
+	if len(data) > MaxIconBytes {
+		return nil, invalidf("icon", "icon is %d KiB; the limit is %d KiB", (len(data)+1023)>>10, MaxIconBytes>>10)
+	}
+
+	var kind string
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		kind = "image/png"
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		kind = "image/jpeg"
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		kind = "image/gif"
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		kind = "image/webp"
+	default:
+		return nil, invalidf("icon", "icon must be a PNG, JPEG, GIF or WebP image")
+	}
+	return &AccountIcon{Type: kind, Data: bytes.Clone(data)}, nil
+}
+
+// BrandIconSVG draws a monogram badge for brand: its initial on its color.
+func BrandIconSVG(brand Brand) []byte {
+	background := brand.Color
+	if background == "" {
+		background = "#64748b"
+	}
+	initial := "?"
+	if r, _ := utf8.DecodeRuneInString(strings.TrimSpace(brand.Name)); r != utf8.RuneError {
+		initial = string(unicode.ToUpper(r))
+	}
+	foreground := "#ffffff"
+	if r, g, b, ok := ParseColor(background); ok && 299*r+587*g+114*b > 160000 {
+		foreground = "#111827"
+	}
+
+	return fmt.Appendf(nil, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 32 32" width="32" height="32">`+
+		`<rect width="32" height="32" rx="7" fill="%s"/>`+
+		`<text x="16" y="21.5" text-anchor="middle" font-family="system-ui,-apple-system,Segoe UI,sans-serif" font-size="17" font-weight="700" fill="%s">%s</text>`+
+		`</svg>`, background, foreground, html.EscapeString(initial))
+}
+
+// SetAccountColor sets or, with an empty color, clears the color that
+// overrides the account's brand color.
+func (s Service) SetAccountColor(ref, color string) (Account, error) {
+	normalized, err := NormalizeColor(color)
+	if err != nil {
+		return Account{}, err
+	}
+	return s.updateAccountBrand(ref, func(account *Account) { account.Color = normalized })
+}
+
+// SetAccountIcon stores icon with the account; a nil icon removes it.
+func (s Service) SetAccountIcon(ref string, icon *AccountIcon) (Account, error) {
+	return s.updateAccountBrand(ref, func(account *Account) { account.Icon = icon })
+}
+
+func (s Service) updateAccountBrand(ref string, change func(*Account)) (Account, error) {
+	accounts, err := s.LoadAccounts()
+	if err != nil {
+		return Account{}, fmt.Errorf("load accounts: %w", err)
+	}
+
+	idx := FindAccountIndex(accounts, ref)
+	if idx == -1 {
+		return Account{}, notFoundf("no account found matching %q", ref)
+	}
+	change(&accounts[idx])
+
+	if err := s.SaveAccounts(accounts); err != nil {
+		return Account{}, fmt.Errorf("save accounts: %w", err)
+	}
+	return accounts[idx], nil
+}
@@ -0,0 +1,121 @@
+package trustpin
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLookupBrand(t *testing.T) {
+	tests := []struct {
+		issuer string
+		want   string
+	}{
+		{"GitHub", "GitHub"},
+		{"github.com", "GitHub"},
+		{"AWS SSO", "AWS"},
+		{"Epic Games", "Epic Games"},
+		{"epicgames", "Epic Games"},
+		{"Twitter", "X"},
+	}
+	for _, tt := range tests {
+		brand, ok := LookupBrand(tt.issuer)
+		if !ok || brand.Name != tt.want || !brand.Known {
+			t.Errorf("LookupBrand(%q) = %+v, %v; want %s", tt.issuer, brand, ok, tt.want)
+		}
+	}
+
+	if _, ok := LookupBrand("Internal VPN"); ok {
+		t.Fatalf("expected an unknown issuer not to match the catalog")
+	}
+	first, second := IssuerBrand("Internal VPN"), IssuerBrand("internal vpn")
+	if first.Known || first.Color == "" || first.Color != second.Color {
+		t.Fatalf("expected a stable derived color for unknown issuers, got %+v and %+v", first, second)
+	}
+	if brand := IssuerBrand(""); brand != (Brand{}) {
+		t.Fatalf("expected no brand without an issuer, got %+v", brand)
+	}
+}
+
+func TestAccountBrandPrefersCustomColor(t *testing.T) {
+	account := Account{Name: "GitHub:work"}
+	if got := AccountBrand(account).Color; got != "#181717" {
+		t.Fatalf("expected the catalog color, got %s", got)
+	}
+	account.Color = "#0f0"
+	if got := AccountBrand(account).Color; got != "#00ff00" {
+		t.Fatalf("expected the custom color, got %s", got)
+	}
+}
+
+func TestNormalizeColor(t *testing.T) {
+	for input, want := range map[string]string{"": "", "#1F6FEB": "#1f6feb", "abc": "#aabbcc", " #123456 ": "#123456"} {
+		if got, err := NormalizeColor(input); err != nil || got != want {
+			t.Errorf("NormalizeColor(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"red", "#12345", "#ggg", "+12345"} {
+		if _, err := NormalizeColor(input); !errors.Is(err, ErrValidation) {
+			t.Errorf("NormalizeColor(%q): expected a validation error, got %v", input, err)
+		}
+	}
+}
+
+func TestNewAccountIcon(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+	icon, err := NewAccountIcon(png)
+	if err != nil || icon.Type != "image/png" || !bytes.Equal(icon.Data, png) {
+		t.Fatalf("expected a PNG icon, got %+v, %v", icon, err)
+	}
+
+	rejected := map[string][]byte{
+		"empty":     nil,
+		"svg":       []byte(`<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`),
+		"too large": append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, MaxIconBytes)...),
+	}
+	for name, data := range rejected {
+		if _, err := NewAccountIcon(data); !errors.Is(err, ErrValidation) {
+			t.Errorf("%s: expected a validation error, got %v", name, err)
+		}
+	}
+}
+
+func TestBrandIconSVG(t *testing.T) {
+	svg := string(BrandIconSVG(Brand{Name: "<b>", Color: "#f0b90b"}))
+	if !strings.Contains(svg, `fill="#f0b90b"`) || !strings.Contains(svg, "&lt;") || !strings.Contains(svg, `fill="#111827"`) {
+		t.Fatalf("expected an escaped initial in dark text on a light badge, got %s", svg)
+	}
+}
+
+func TestAccountBrandSurvivesEdits(t *testing.T) {
+	service := newDoctorService(t)
+	if _, err := service.UpsertAccounts([]Account{{Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP"}}); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	icon, _ := NewAccountIcon([]byte("GIF89a........"))
+	if _, err := service.SetAccountIcon("GitHub:work", icon); err != nil {
+		t.Fatalf("set icon: %v", err)
+	}
+	if _, err := service.SetAccountColor("GitHub:work", "#333"); err != nil {
+		t.Fatalf("set color: %v", err)
+	}
+	if _, err := service.SetAccountColor("GitHub:work", "blue"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected an invalid color to be rejected, got %v", err)
+	}
+
+	if err := service.UpdateAccount("GitHub:work", Account{Name: "GitHub:home", Color: "#333333", Interval: 30, Digits: 6}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	account, _ := service.GetAccount("GitHub:home")
+	if account.Icon == nil || account.Icon.Type != "image/gif" || account.Color != "#333333" {
+		t.Fatalf("expected the edit to keep the icon and color, got %+v", account)
+	}
+
+	if _, err := service.SetAccountIcon(account.ID, nil); err != nil {
+		t.Fatalf("clear icon: %v", err)
+	}
+	if account, _ = service.GetAccount(account.ID); account.Icon != nil {
+		t.Fatalf("expected the icon to be removed")
+	}
+}
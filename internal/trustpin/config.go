@@ -0,0 +1,134 @@
+package trustpin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultConfigFileName = "config.yaml"
+	DefaultTLSDirName     = "tls"
+)
+
+type Config struct {
+	Path   string
+	Values map[string]string
+}
+
+func DefaultConfigPath() string {
+	return filepath.Join(defaultAppDir(), DefaultConfigFileName)
+}
+
+// DefaultTLSDir holds the local CA and dashboard certificate generated by
+// `trustpin serve --tls`.
+func DefaultTLSDir() string {
+	return filepath.Join(defaultAppDir(), DefaultTLSDirName)
+}
+
+func LoadConfig(path string) (Config, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		path = DefaultConfigPath()
+	}
+
+	config := Config{Path: filepath.Clean(path), Values: map[string]string{}}
+	data, err := os.ReadFile(config.Path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return Config{}, err
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return Config{}, fmt.Errorf("parse config %s: %w", config.Path, err)
+	}
+	flattenConfig("", raw, config.Values)
+	return config, nil
+}
+
+func (c Config) Get(key string) (string, bool) {
+	value, ok := c.Values[normalizeConfigKey(key)]
+	return value, ok
+}
+
+func (c *Config) Set(key, value string) {
+	if c.Values == nil {
+		c.Values = map[string]string{}
+	}
+	c.Values[normalizeConfigKey(key)] = value
+}
+
+func (c Config) Keys() []string {
+	keys := make([]string, 0, len(c.Values))
+	for key := range c.Values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c Config) Save() error {
+	path := c.Path
+	if strings.TrimSpace(path) == "" {
+		path = DefaultConfigPath()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	nested := make(map[string]interface{})
+	for key, value := range c.Values {
+		parts := strings.Split(key, ".")
+		node := nested
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[part] = child
+			}
+			node = child
+		}
+		node[parts[len(parts)-1]] = value
+	}
+
+	data, err := yaml.Marshal(nested)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func flattenConfig(prefix string, raw map[string]interface{}, out map[string]string) {
+	for key, value := range raw {
+		fullKey := normalizeConfigKey(key)
+		if prefix != "" {
+			fullKey = prefix + "." + fullKey
+		}
+
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			flattenConfig(fullKey, typed, out)
+		case []interface{}:
+			items := make([]string, 0, len(typed))
+			for _, item := range typed {
+				items = append(items, fmt.Sprint(item))
+			}
+			out[fullKey] = strings.Join(items, ",")
+		case nil:
+			out[fullKey] = ""
+		default:
+			out[fullKey] = fmt.Sprint(typed)
+		}
+	}
+}
+
+func normalizeConfigKey(key string) string {
+	return strings.ToLower(strings.TrimSpace(key))
+}
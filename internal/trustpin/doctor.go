@@ -0,0 +1,337 @@
+package trustpin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DiagnosticStatus is the outcome of one doctor check.
+type DiagnosticStatus string
+
+const (
+	DiagnosticOK      DiagnosticStatus = "ok"
+	DiagnosticWarning DiagnosticStatus = "warning"
+	DiagnosticError   DiagnosticStatus = "error"
+)
+
+// Doctor check IDs. They are stable so scripts can match on them.
+const (
+	CheckStoreFile         = "store-file"
+	CheckKeyFile           = "key-file"
+	CheckStorePermissions  = "store-permissions"
+	CheckKeyPermissions    = "key-permissions"
+	CheckDirPermissions    = "directory-permissions"
+	CheckKeyDirPermissions = "key-directory-permissions"
+	CheckStoreFormat       = "store-format"
+	CheckStoreDecrypt      = "store-decrypt"
+	CheckStoreSchema       = "store-schema"
+	CheckSchemaVersion     = "schema-version"
+	CheckDuplicateIDs      = "duplicate-ids"
+	CheckLegacyFiles       = "legacy-files"
+	CheckBackups           = "backups"
+)
+
+type DiagnosticCheck struct {
+	ID     string           `json:"id"`
+	Status DiagnosticStatus `json:"status"`
+	Title  string           `json:"title"`
+	Detail string           `json:"detail"`
+	Fix    string           `json:"fix,omitempty"`
+}
+
+// DiagnosticReport is the result of Diagnose. Status is the worst status of
+// any check.
+type DiagnosticReport struct {
+	Status    DiagnosticStatus   `json:"status"`
+	StorePath string             `json:"storePath"`
+	KeyPath   string             `json:"keyPath"`
+	Checks    []DiagnosticCheck  `json:"checks"`
+	Backups   []BackupGeneration `json:"backups"`
+}
+
+// restoreFix is the advice for a store that cannot be read.
+const restoreFix = "Run `trustpin doctor --restore` to go back to the newest intact backup generation."
+
+// Diagnose inspects the store, its key, and the files around them without
+// changing anything. Checks that depend on an earlier failed one are left out.
+func (s Service) Diagnose() DiagnosticReport {
+	report := DiagnosticReport{
+		Status:    DiagnosticOK,
+		StorePath: s.storePath(),
+		KeyPath:   s.keyPath(),
+		Backups:   s.StoreBackups(),
+	}
+	add := func(check DiagnosticCheck) {
+		report.Checks = append(report.Checks, check)
+		if diagnosticRank(check.Status) > diagnosticRank(report.Status) {
+			report.Status = check.Status
+		}
+	}
+	hasIntactBackup := false
+	for _, backup := range report.Backups {
+		hasIntactBackup = hasIntactBackup || backup.Intact
+	}
+	unreadableFix := "Move the file aside; its accounts cannot be recovered without a backup."
+	if hasIntactBackup {
+		unreadableFix = restoreFix
+	}
+
+	storeInfo, storeErr := os.Stat(report.StorePath)
+	keyInfo, keyErr := os.Stat(report.KeyPath)
+
+	switch {
+	case storeErr == nil:
+		add(DiagnosticCheck{ID: CheckStoreFile, Status: DiagnosticOK, Title: "Store file present", Detail: report.StorePath})
+	case os.IsNotExist(storeErr) && hasIntactBackup:
+		add(DiagnosticCheck{ID: CheckStoreFile, Status: DiagnosticError, Title: "Store file missing", Detail: "The store is gone but backup generations remain.", Fix: restoreFix})
+	case os.IsNotExist(storeErr):
+		add(DiagnosticCheck{ID: CheckStoreFile, Status: DiagnosticWarning, Title: "Store file missing", Detail: "No store exists yet at " + report.StorePath + ".", Fix: "Add an account with `trustpin add`; the store is created on first use."})
+	default:
+		add(DiagnosticCheck{ID: CheckStoreFile, Status: DiagnosticError, Title: "Store file unreadable", Detail: storeErr.Error(), Fix: "Check that this user owns the store and its directory."})
+	}
+
+	switch {
+	case keyErr == nil:
+		add(DiagnosticCheck{ID: CheckKeyFile, Status: DiagnosticOK, Title: "Encryption key present", Detail: report.KeyPath})
+	case os.IsNotExist(keyErr) && storeErr == nil:
+		add(DiagnosticCheck{ID: CheckKeyFile, Status: DiagnosticError, Title: "Encryption key missing", Detail: "The store exists but " + report.KeyPath + " does not, so it cannot be decrypted.", Fix: "Copy accounts.key from the machine or backup the store came from."})
+	case os.IsNotExist(keyErr):
+		add(DiagnosticCheck{ID: CheckKeyFile, Status: DiagnosticWarning, Title: "Encryption key missing", Detail: "A new key is created together with the store."})
+	default:
+		add(DiagnosticCheck{ID: CheckKeyFile, Status: DiagnosticError, Title: "Encryption key unreadable", Detail: keyErr.Error(), Fix: "Check that this user owns the key file and its directory."})
+	}
+
+	if runtime.GOOS != "windows" {
+		if storeErr == nil {
+			add(modeCheck(CheckStorePermissions, "Store", report.StorePath, storeInfo, 0o600))
+		}
+		if keyErr == nil {
+			add(modeCheck(CheckKeyPermissions, "Key", report.KeyPath, keyInfo, 0o600))
+		}
+		dir := filepath.Dir(report.StorePath)
+		if info, err := os.Stat(dir); err == nil {
+			add(dirCheck(CheckDirPermissions, "Store directory", dir, info))
+		}
+		if keyDir := filepath.Dir(report.KeyPath); keyDir != dir {
+			if info, err := os.Stat(keyDir); err == nil {
+				add(dirCheck(CheckKeyDirPermissions, "Key directory", keyDir, info))
+			}
+		}
+	}
+
+	if storeErr == nil {
+		s.diagnoseStoreContents(add, unreadableFix)
+	}
+
+	add(s.legacyFilesCheck())
+	add(backupsCheck(report.Backups))
+	return report
+}
+
+func (s Service) diagnoseStoreContents(add func(DiagnosticCheck), unreadableFix string) {
+	data, err := os.ReadFile(s.storePath())
+	if err != nil {
+		add(DiagnosticCheck{ID: CheckStoreFormat, Status: DiagnosticError, Title: "Store file unreadable", Detail: err.Error(), Fix: "Check that this user owns the store."})
+		return
+	}
+
+	if bytes.HasPrefix(data, []byte(storeMagic)) {
+		add(DiagnosticCheck{ID: CheckStoreFormat, Status: DiagnosticOK, Title: "Encrypted store format", Detail: "The file starts with the " + storeMagic + " header."})
+
+		key, err := s.loadKey()
+		if err != nil {
+			add(DiagnosticCheck{ID: CheckStoreDecrypt, Status: DiagnosticError, Title: "Store cannot be decrypted", Detail: err.Error(), Fix: "Copy the matching accounts.key from the machine or backup the store came from."})
+			return
+		}
+		plaintext, err := decryptPayload(data, key)
+		if err != nil {
+			check := DiagnosticCheck{ID: CheckStoreDecrypt, Status: DiagnosticError, Title: "Store cannot be decrypted", Detail: err.Error(), Fix: unreadableFix}
+			if errors.Is(err, ErrWrongKey) {
+				check.Detail = "The key does not open the store: either it is a different key or the file is damaged."
+				check.Fix = "If the store came from another machine, copy its accounts.key too. " + unreadableFix
+			}
+			add(check)
+			return
+		}
+		add(DiagnosticCheck{ID: CheckStoreDecrypt, Status: DiagnosticOK, Title: "Store decrypts", Detail: "GCM authentication passed with the current key."})
+		data = plaintext
+	} else if json.Valid(data) {
+		add(DiagnosticCheck{ID: CheckStoreFormat, Status: DiagnosticWarning, Title: "Store is plaintext", Detail: "The store holds unencrypted JSON, so secrets are readable on disk.", Fix: "Run `trustpin show` once; the store is encrypted on the next load."})
+	} else {
+		add(DiagnosticCheck{ID: CheckStoreFormat, Status: DiagnosticError, Title: "Store format not recognised", Detail: "The file is neither an encrypted store nor JSON.", Fix: unreadableFix})
+		return
+	}
+
+	payload, err := decodeStorePayload(data)
+	if err != nil {
+		add(DiagnosticCheck{ID: CheckStoreSchema, Status: DiagnosticError, Title: "Store contents are not an account list", Detail: err.Error(), Fix: unreadableFix})
+		return
+	}
+	add(schemaVersionCheck(payload))
+	accounts := payload.Accounts
+	incomplete := 0
+	for _, account := range accounts {
+		if strings.TrimSpace(account.Name) == "" || strings.TrimSpace(account.Secret) == "" {
+			incomplete++
+		}
+	}
+	if incomplete > 0 {
+		add(DiagnosticCheck{ID: CheckStoreSchema, Status: DiagnosticWarning, Title: "Incomplete accounts", Detail: fmt.Sprintf("%d of %d accounts have no name or no secret.", incomplete, len(accounts)), Fix: "Run `trustpin health` to find them, then fix or delete them."})
+	} else {
+		add(DiagnosticCheck{ID: CheckStoreSchema, Status: DiagnosticOK, Title: "Store contents valid", Detail: fmt.Sprintf("%d %s decoded.", len(accounts), pluralize("account", "accounts", len(accounts)))})
+	}
+
+	seen := make(map[string]string, len(accounts))
+	var duplicates []string
+	for _, account := range accounts {
+		if account.ID == "" {
+			continue
+		}
+		if first, ok := seen[account.ID]; ok {
+			duplicates = append(duplicates, fmt.Sprintf("%s (%s, %s)", account.ID, first, account.Name))
+			continue
+		}
+		seen[account.ID] = account.Name
+	}
+	if len(duplicates) > 0 {
+		add(DiagnosticCheck{ID: CheckDuplicateIDs, Status: DiagnosticError, Title: "Duplicate account IDs", Detail: strings.Join(duplicates, "; "), Fix: "Commands that take an ID only reach the first account. Delete the copy by name with `trustpin delete <name>` and add it again."})
+	} else {
+		add(DiagnosticCheck{ID: CheckDuplicateIDs, Status: DiagnosticOK, Title: "Account IDs unique", Detail: "Every account has its own ID."})
+	}
+}
+
+func schemaVersionCheck(payload storePayload) DiagnosticCheck {
+	switch {
+	case payload.SchemaVersion > StoreSchemaVersion:
+		return DiagnosticCheck{
+			ID:     CheckSchemaVersion,
+			Status: DiagnosticWarning,
+			Title:  "Store from a newer TrustPIN",
+			Detail: fmt.Sprintf("The store uses schema %d; this build knows schema %d, so it can show the accounts but refuses to change them.", payload.SchemaVersion, StoreSchemaVersion),
+			Fix:    "Upgrade TrustPIN to the version that wrote the store.",
+		}
+	case payload.SchemaVersion < StoreSchemaVersion:
+		return DiagnosticCheck{ID: CheckSchemaVersion, Status: DiagnosticOK, Title: "Store schema outdated", Detail: fmt.Sprintf("The store uses schema %d and is upgraded to schema %d the next time it is loaded.", payload.SchemaVersion, StoreSchemaVersion)}
+	default:
+		return DiagnosticCheck{ID: CheckSchemaVersion, Status: DiagnosticOK, Title: "Store schema current", Detail: fmt.Sprintf("Schema %d, vault %s, created %s.", payload.SchemaVersion, payload.Vault.ID, payload.Vault.CreatedAt.Format(time.RFC3339))}
+	}
+}
+
+func modeCheck(id, label, path string, info fs.FileInfo, want fs.FileMode) DiagnosticCheck {
+	if owner, ok := fileOwner(info); ok && owner != os.Geteuid() {
+		return DiagnosticCheck{
+			ID:     id,
+			Status: DiagnosticError,
+			Title:  label + " owned by another user",
+			Detail: fmt.Sprintf("%s belongs to uid %d, not to the current user (uid %d); TrustPIN refuses to load it.", path, owner, os.Geteuid()),
+			Fix:    fmt.Sprintf("sudo chown %d %s", os.Geteuid(), shellQuote(path)),
+		}
+	}
+	mode := info.Mode().Perm()
+	if mode&^want == 0 {
+		return DiagnosticCheck{ID: id, Status: DiagnosticOK, Title: label + " permissions", Detail: fmt.Sprintf("%s is %04o.", path, mode)}
+	}
+	return DiagnosticCheck{
+		ID:     id,
+		Status: DiagnosticWarning,
+		Title:  label + " permissions too open",
+		Detail: fmt.Sprintf("%s is %04o; other users may be able to read it. The next load makes it private.", path, mode),
+		Fix:    fmt.Sprintf("chmod %o %s", want, shellQuote(path)),
+	}
+}
+
+// dirCheck reports on the directory holding the store or the key. Loading
+// only makes the app directory private; a directory the user chose is
+// reported when other users can open it, but left as it is.
+func dirCheck(id, label, dir string, info fs.FileInfo) DiagnosticCheck {
+	if owner, ok := fileOwner(info); ok && owner != os.Geteuid() {
+		return DiagnosticCheck{
+			ID:     id,
+			Status: DiagnosticWarning,
+			Title:  label + " owned by another user",
+			Detail: fmt.Sprintf("%s belongs to uid %d, who can replace the store or the key.", dir, owner),
+			Fix:    "Move the store to a directory you own with --accounts-file.",
+		}
+	}
+	if isAppDir(dir) {
+		return modeCheck(id, label, dir, info, 0o700)
+	}
+	mode := info.Mode().Perm()
+	if mode&^0o700 == 0 {
+		return DiagnosticCheck{ID: id, Status: DiagnosticOK, Title: label + " permissions", Detail: fmt.Sprintf("%s is %04o.", dir, mode)}
+	}
+	return DiagnosticCheck{
+		ID:     id,
+		Status: DiagnosticWarning,
+		Title:  label + " permissions too open",
+		Detail: fmt.Sprintf("%s is %04o; other users may be able to open it. TrustPIN leaves directories you chose as they are.", dir, mode),
+		Fix:    fmt.Sprintf("chmod 700 %s", shellQuote(dir)),
+	}
+}
+
+func (s Service) legacyFilesCheck() DiagnosticCheck {
+	candidates := []string{filepath.Join(filepath.Dir(s.storePath()), DefaultLegacyAccountFile)}
+	if legacy := s.legacyPath(); legacy != "" && legacy != candidates[0] {
+		candidates = append(candidates, legacy)
+	}
+
+	var found []string
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			found = append(found, path)
+		}
+	}
+	if len(found) == 0 {
+		return DiagnosticCheck{ID: CheckLegacyFiles, Status: DiagnosticOK, Title: "No legacy plaintext files", Detail: "No accounts.json found beside the store or in the working directory."}
+	}
+	return DiagnosticCheck{
+		ID:     CheckLegacyFiles,
+		Status: DiagnosticWarning,
+		Title:  "Legacy plaintext files found",
+		Detail: strings.Join(found, ", ") + " holds unencrypted secrets.",
+		Fix:    "Run `trustpin migrate " + shellQuote(found[0]) + "`; it imports the accounts and removes the plaintext file.",
+	}
+}
+
+func backupsCheck(backups []BackupGeneration) DiagnosticCheck {
+	intact := 0
+	for _, backup := range backups {
+		if backup.Intact {
+			intact++
+		}
+	}
+	switch {
+	case len(backups) == 0:
+		return DiagnosticCheck{ID: CheckBackups, Status: DiagnosticOK, Title: "No backup generations yet", Detail: fmt.Sprintf("Up to %d earlier versions of the store are kept after each save.", StoreBackupGenerations)}
+	case intact == 0:
+		return DiagnosticCheck{ID: CheckBackups, Status: DiagnosticWarning, Title: "No intact backup generation", Detail: fmt.Sprintf("None of the %d backup generations opens with the current key.", len(backups)), Fix: "Keep the store and key together when copying them between machines."}
+	default:
+		return DiagnosticCheck{ID: CheckBackups, Status: DiagnosticOK, Title: "Backup generations available", Detail: fmt.Sprintf("%d of %d backup generations open with the current key.", intact, len(backups))}
+	}
+}
+
+func diagnosticRank(status DiagnosticStatus) int {
+	switch status {
+	case DiagnosticError:
+		return 2
+	case DiagnosticWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func shellQuote(path string) string {
+	if strings.ContainsAny(path, " '\"\\$`") {
+		return "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+	}
+	return path
+}
@@ -0,0 +1,198 @@
+package trustpin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func newDoctorService(t *testing.T) Service {
+	t.Helper()
+	tmpDir := t.TempDir()
+	return Service{
+		StorePath: filepath.Join(tmpDir, "accounts.enc"),
+		KeyPath:   filepath.Join(tmpDir, "accounts.key"),
+	}
+}
+
+func diagnosticStatuses(report DiagnosticReport) map[string]DiagnosticStatus {
+	statuses := make(map[string]DiagnosticStatus, len(report.Checks))
+	for _, check := range report.Checks {
+		statuses[check.ID] = check.Status
+	}
+	return statuses
+}
+
+func TestSaveAccountsKeepsBackupGenerations(t *testing.T) {
+	service := newDoctorService(t)
+	for i := 1; i <= StoreBackupGenerations+2; i++ {
+		accounts := make([]Account, i)
+		for j := range accounts {
+			accounts[j] = Account{Name: "Account", Secret: "JBSWY3DPEHPK3PXP", Interval: 30, Digits: 6}
+		}
+		if err := service.SaveAccounts(accounts); err != nil {
+			t.Fatalf("save %d: %v", i, err)
+		}
+	}
+
+	backups := service.StoreBackups()
+	if len(backups) != StoreBackupGenerations {
+		t.Fatalf("expected %d generations, got %+v", StoreBackupGenerations, backups)
+	}
+	for i, backup := range backups {
+		want := StoreBackupGenerations + 1 - i
+		if !backup.Intact || backup.Accounts != want {
+			t.Fatalf("generation %d: expected an intact store with %d accounts, got %+v", backup.Generation, want, backup)
+		}
+	}
+}
+
+func TestDoctorRestoresNewestIntactBackup(t *testing.T) {
+	service := newDoctorService(t)
+	for _, name := range []string{"GitHub:work", "Slack:me"} {
+		accounts, _ := service.loadExistingStoreAccounts()
+		accounts = append(accounts, Account{Name: name, Secret: "JBSWY3DPEHPK3PXP", Interval: 30, Digits: 6})
+		if err := service.SaveAccounts(accounts); err != nil {
+			t.Fatalf("save accounts: %v", err)
+		}
+	}
+	if err := os.WriteFile(service.StorePath, []byte(storeMagic+"damaged beyond repair, nonce and all"), 0o600); err != nil {
+		t.Fatalf("damage store: %v", err)
+	}
+
+	report := service.Diagnose()
+	if report.Status != DiagnosticError || diagnosticStatuses(report)[CheckStoreDecrypt] != DiagnosticError {
+		t.Fatalf("expected a failed decrypt check, got %+v", report.Checks)
+	}
+
+	restored, kept, err := service.RestoreBackup(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), false)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored.Generation != 1 || restored.Accounts != 1 {
+		t.Fatalf("expected generation 1 with one account, got %+v", restored)
+	}
+	if filepath.Base(kept) != "accounts.enc.damaged-20260102T030405Z" {
+		t.Fatalf("unexpected damaged copy %q", kept)
+	}
+	accounts, err := service.LoadAccounts()
+	if err != nil || len(accounts) != 1 || accounts[0].Name != "GitHub:work" {
+		t.Fatalf("expected the restored store to load, got %v %+v", err, accounts)
+	}
+	if report := service.Diagnose(); report.Status == DiagnosticError {
+		t.Fatalf("expected a clean report after restoring, got %+v", report.Checks)
+	}
+}
+
+func TestRestoreLeavesIntactStoreAlone(t *testing.T) {
+	service := newDoctorService(t)
+	for _, name := range []string{"GitHub:work", "Slack:me"} {
+		accounts, _ := service.loadExistingStoreAccounts()
+		accounts = append(accounts, Account{Name: name, Secret: "GEZDGNBVGY3TQOJQ", Interval: 30, Digits: 6})
+		if err := service.SaveAccounts(accounts); err != nil {
+			t.Fatalf("save accounts: %v", err)
+		}
+	}
+	before, err := os.ReadFile(service.StorePath)
+	if err != nil {
+		t.Fatalf("read store: %v", err)
+	}
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, _, err := service.RestoreBackup(now, false); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected restoring over an intact store to be refused, got %v", err)
+	}
+	after, err := os.ReadFile(service.StorePath)
+	if err != nil || !bytes.Equal(before, after) {
+		t.Fatalf("expected the intact store to be left unchanged, got %v", err)
+	}
+	if matches, _ := filepath.Glob(service.StorePath + ".damaged-*"); len(matches) != 0 {
+		t.Fatalf("expected no damaged copy of an intact store, got %v", matches)
+	}
+
+	restored, kept, err := service.RestoreBackup(now, true)
+	if err != nil || restored.Accounts != 1 || filepath.Base(kept) != "accounts.enc.replaced-20260102T030405Z" {
+		t.Fatalf("expected --force to restore generation 1 and keep the store, got %+v %q %v", restored, kept, err)
+	}
+}
+
+func TestDoctorFindsDuplicateIDsAndLegacyFiles(t *testing.T) {
+	service := newDoctorService(t)
+	accounts := []Account{
+		{ID: "same", Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP", Interval: 30, Digits: 6},
+		{ID: "same", Name: "Slack:me", Secret: "GEZDGNBVGY3TQOJQ", Interval: 30, Digits: 6},
+	}
+	// SaveAccounts would renumber the copy, so write the store by hand.
+	key, err := service.loadOrCreateKey()
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	legacy, _ := json.Marshal(accounts)
+	encrypted, err := encryptPayload(legacy, key)
+	if err != nil {
+		t.Fatalf("encrypt store: %v", err)
+	}
+	if err := os.WriteFile(service.StorePath, encrypted, 0o600); err != nil {
+		t.Fatalf("write store: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(service.StorePath), DefaultLegacyAccountFile), legacy, 0o600); err != nil {
+		t.Fatalf("write legacy file: %v", err)
+	}
+
+	statuses := diagnosticStatuses(service.Diagnose())
+	if statuses[CheckStoreDecrypt] != DiagnosticOK || statuses[CheckStoreSchema] != DiagnosticOK {
+		t.Fatalf("expected the store itself to pass, got %v", statuses)
+	}
+	if statuses[CheckDuplicateIDs] != DiagnosticError {
+		t.Fatalf("expected duplicate IDs to be reported, got %v", statuses)
+	}
+	if statuses[CheckLegacyFiles] != DiagnosticWarning {
+		t.Fatalf("expected the legacy file to be reported, got %v", statuses)
+	}
+}
+
+func TestDoctorFlagsOpenPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not checked on Windows")
+	}
+	service := newDoctorService(t)
+	if err := service.SaveAccounts([]Account{}); err != nil {
+		t.Fatalf("save accounts: %v", err)
+	}
+	if err := os.Chmod(service.KeyPath, 0o644); err != nil {
+		t.Fatalf("chmod key: %v", err)
+	}
+
+	statuses := diagnosticStatuses(service.Diagnose())
+	if statuses[CheckStorePermissions] != DiagnosticOK || statuses[CheckKeyPermissions] != DiagnosticWarning {
+		t.Fatalf("expected only the key mode to be flagged, got %v", statuses)
+	}
+}
+
+func TestDoctorChecksKeyDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not checked on Windows")
+	}
+	tmpDir := t.TempDir()
+	keyDir := filepath.Join(tmpDir, "keys")
+	service := Service{StorePath: filepath.Join(tmpDir, "store", "accounts.enc"), KeyPath: filepath.Join(keyDir, "accounts.key")}
+	if err := service.SaveAccounts([]Account{}); err != nil {
+		t.Fatalf("save accounts: %v", err)
+	}
+	if statuses := diagnosticStatuses(service.Diagnose()); statuses[CheckKeyDirPermissions] != DiagnosticOK {
+		t.Fatalf("expected a private key directory to pass, got %v", statuses)
+	}
+
+	if err := os.Chmod(keyDir, 0o755); err != nil {
+		t.Fatalf("chmod key directory: %v", err)
+	}
+	statuses := diagnosticStatuses(service.Diagnose())
+	if statuses[CheckKeyDirPermissions] != DiagnosticWarning || statuses[CheckDirPermissions] != DiagnosticOK {
+		t.Fatalf("expected the open key directory to be flagged, got %v", statuses)
+	}
+}
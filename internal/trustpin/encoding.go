@@ -0,0 +1,194 @@
+package trustpin
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	EncodingBase32      = "base32"
+	EncodingBase32NoPad = "base32-nopad"
+	EncodingBase64      = "base64"
+	EncodingHex         = "hex"
+	EncodingRaw         = "raw"
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NormalizeSecretEncoding validates a user-supplied encoding name. An empty
+// result means the encoding should be detected from the secret.
+func NormalizeSecretEncoding(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "auto":
+		return "", nil
+	case EncodingBase32, "b32":
+		return EncodingBase32, nil
+	case EncodingBase32NoPad, "base32nopad", "b32-nopad":
+		return EncodingBase32NoPad, nil
+	case EncodingBase64, "b64":
+		return EncodingBase64, nil
+	case EncodingHex, "base16":
+		return EncodingHex, nil
+	case EncodingRaw, "text", "ascii":
+		return EncodingRaw, nil
+	default:
+		return "", invalidf("encoding", "unsupported secret encoding %q (use base32, base32-nopad, base64, hex, or raw)", value)
+	}
+}
+
+// DetectSecretEncoding guesses the encoding of newly entered secrets:
+// base32 (any case, padding optional), then hex, then base64. A base64 result
+// is reported as ambiguous because it is just as likely to be a mistyped
+// base32 key.
+func DetectSecretEncoding(secret string) (encoding string, ambiguous bool) {
+	if b32 := cleanBase32(secret); b32 != "" {
+		if _, err := decodeBase32(b32); err == nil {
+			if strings.Contains(b32, "=") || len(b32)%8 == 0 {
+				return EncodingBase32, false
+			}
+			return EncodingBase32NoPad, false
+		}
+	}
+
+	if value := cleanHex(secret); value != "" {
+		if _, err := hex.DecodeString(value); err == nil {
+			return EncodingHex, false
+		}
+	}
+
+	if value := cleanBase64(secret); value != "" {
+		if _, err := decodeBase64(value); err == nil {
+			return EncodingBase64, true
+		}
+	}
+
+	return "", false
+}
+
+// detectLegacyEncoding reproduces how releases before explicit encodings
+// decoded a stored secret, so migrated entries keep generating the same
+// codes. Those releases uppercased the secret and dropped its spaces before
+// trying strict base32 and then base64, so the secret to store is returned
+// in that form. Anything other than strict base32 is reported as a guess.
+func detectLegacyEncoding(secret string) (legacy, encoding string, guessed bool) {
+	if normalized := normalizeSecret(secret); normalized != "" {
+		if _, err := base32.StdEncoding.DecodeString(normalized); err == nil {
+			return normalized, EncodingBase32, false
+		}
+		if _, err := base64.StdEncoding.DecodeString(normalized); err == nil {
+			return normalized, EncodingBase64, true
+		}
+	}
+
+	encoding, _ = DetectSecretEncoding(secret)
+	return secret, encoding, encoding != ""
+}
+
+// DecodeSecret turns a stored secret into key bytes. An empty encoding falls
+// back to detection.
+func DecodeSecret(secret, encoding string) ([]byte, error) {
+	if encoding == "" {
+		encoding, _ = DetectSecretEncoding(secret)
+	}
+
+	switch encoding {
+	case EncodingBase32, EncodingBase32NoPad:
+		return decodeBase32(cleanBase32(secret))
+	case EncodingBase64:
+		return decodeBase64(cleanBase64(secret))
+	case EncodingHex:
+		return hex.DecodeString(cleanHex(secret))
+	case EncodingRaw:
+		if secret == "" {
+			return nil, fmt.Errorf("secret is empty")
+		}
+		return []byte(secret), nil
+	default:
+		return nil, fmt.Errorf("secret is not valid base32, base64, or hex")
+	}
+}
+
+// CanonicalSecret rewrites a secret into the stored form for its encoding.
+// Secrets that do not decode are returned trimmed but otherwise untouched.
+func CanonicalSecret(secret, encoding string) string {
+	if encoding == EncodingRaw {
+		return secret
+	}
+
+	key, err := DecodeSecret(secret, encoding)
+	if err != nil {
+		return strings.TrimSpace(secret)
+	}
+
+	switch encoding {
+	case EncodingBase32:
+		return base32.StdEncoding.EncodeToString(key)
+	case EncodingBase32NoPad:
+		return base32NoPadding.EncodeToString(key)
+	case EncodingBase64:
+		return base64.StdEncoding.EncodeToString(key)
+	case EncodingHex:
+		return hex.EncodeToString(key)
+	default:
+		return strings.TrimSpace(secret)
+	}
+}
+
+// ValidateSecret reports whether a secret decodes under the given encoding,
+// or under any supported encoding when none is given.
+func ValidateSecret(secret, encoding string) error {
+	if _, err := DecodeSecret(secret, encoding); err != nil {
+		if encoding == "" {
+			return invalidSecretf("secret is not valid base32, base64, or hex")
+		}
+		return &Error{Kind: ErrInvalidSecret, Field: "secret", Message: "secret is not valid " + encoding, Err: err}
+	}
+	return nil
+}
+
+// secretIdentity compares secrets by key material so the same key stored in
+// two encodings, cases or spacings is still recognised as shared.
+func secretIdentity(secret, encoding string) string {
+	key, err := DecodeSecret(secret, encoding)
+	if err != nil || len(key) == 0 {
+		return normalizeSecret(secret)
+	}
+	return base32NoPadding.EncodeToString(key)
+}
+
+func cleanBase32(secret string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(secret), ""))
+}
+
+func cleanBase64(secret string) string {
+	return strings.Join(strings.Fields(secret), "")
+}
+
+func cleanHex(secret string) string {
+	replacer := strings.NewReplacer(" ", "", ":", "", "-", "")
+	value := strings.ToLower(replacer.Replace(strings.TrimSpace(secret)))
+	return strings.TrimPrefix(value, "0x")
+}
+
+func decodeBase32(secret string) ([]byte, error) {
+	trimmed := strings.TrimRight(secret, "=")
+	if trimmed == "" {
+		return nil, fmt.Errorf("secret is empty")
+	}
+	return base32NoPadding.DecodeString(trimmed)
+}
+
+func decodeBase64(secret string) ([]byte, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret is empty")
+	}
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding} {
+		if key, err := encoding.DecodeString(secret); err == nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("secret is not valid base64")
+}
@@ -0,0 +1,143 @@
+package trustpin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectSecretEncoding(t *testing.T) {
+	tests := []struct {
+		secret    string
+		encoding  string
+		ambiguous bool
+	}{
+		{"JBSWY3DPEHPK3PXP", EncodingBase32, false},
+		{"jbsw y3dp ehpk 3pxp", EncodingBase32, false},
+		{"JBSWY3DPEHPK3PX", EncodingBase32NoPad, false},
+		{"3132333435363738393031323334353637383930", EncodingHex, false},
+		{"aGVsbG8gd29ybGQhISE=", EncodingBase64, true},
+		{"not a secret!", "", false},
+	}
+
+	for _, tt := range tests {
+		encoding, ambiguous := DetectSecretEncoding(tt.secret)
+		if encoding != tt.encoding || ambiguous != tt.ambiguous {
+			t.Errorf("DetectSecretEncoding(%q) = %q, %v; want %q, %v", tt.secret, encoding, ambiguous, tt.encoding, tt.ambiguous)
+		}
+	}
+}
+
+func TestCanonicalSecretPreservesKey(t *testing.T) {
+	tests := []struct {
+		secret   string
+		encoding string
+		want     string
+	}{
+		{"jbsw y3dp ehpk 3pxp", EncodingBase32, "JBSWY3DPEHPK3PXP"},
+		{"mzxw6", EncodingBase32NoPad, "MZXW6"},
+		{"mzxw6", EncodingBase32, "MZXW6==="},
+		{"0x48:65:6C:6C:6F", EncodingHex, "48656c6c6f"},
+		{"aGVsbG8", EncodingBase64, "aGVsbG8="},
+		{" raw secret ", EncodingRaw, " raw secret "},
+	}
+
+	for _, tt := range tests {
+		if got := CanonicalSecret(tt.secret, tt.encoding); got != tt.want {
+			t.Errorf("CanonicalSecret(%q, %q) = %q, want %q", tt.secret, tt.encoding, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeAccountKeepsBase64Case(t *testing.T) {
+	account := sanitizeAccount(Account{Name: "API", Secret: "aGVsbG8gd29ybGQhISE="})
+	if account.SecretEncoding != EncodingBase64 || !account.EncodingGuess {
+		t.Fatalf("expected guessed base64 encoding, got %q guess=%v", account.SecretEncoding, account.EncodingGuess)
+	}
+	if account.Secret != "aGVsbG8gd29ybGQhISE=" {
+		t.Fatalf("expected base64 secret case to be preserved, got %q", account.Secret)
+	}
+}
+
+func TestSecretIdentityMatchesAcrossEncodings(t *testing.T) {
+	base32Key := secretIdentity("jbswy3dpehpk3pxp", EncodingBase32)
+	hexKey := secretIdentity("48656c6c6f21deadbeef", EncodingHex)
+	if base32Key != hexKey {
+		t.Fatalf("expected identical key material to match, got %q and %q", base32Key, hexKey)
+	}
+}
+
+func TestLoadAccountsMigratesSecretEncodings(t *testing.T) {
+	tmpDir := t.TempDir()
+	service := Service{
+		StorePath: filepath.Join(tmpDir, "accounts.enc"),
+		KeyPath:   filepath.Join(tmpDir, "accounts.key"),
+	}
+
+	key, err := service.loadOrCreateKey()
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	payload, err := json.Marshal([]Account{
+		{Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP", Interval: 30, Digits: 6},
+		{Name: "Legacy:lower", Secret: "jbsw y3dp ehpk 3pxp", Interval: 30, Digits: 6},
+		{Name: "Legacy:b64", Secret: "aGVsbG8gd29ybGQhISE=", Interval: 30, Digits: 6},
+	})
+	if err != nil {
+		t.Fatalf("marshal accounts: %v", err)
+	}
+	encrypted, err := encryptPayload(payload, key)
+	if err != nil {
+		t.Fatalf("encrypt accounts: %v", err)
+	}
+	if err := os.WriteFile(service.StorePath, encrypted, 0o600); err != nil {
+		t.Fatalf("write store: %v", err)
+	}
+
+	loaded, err := service.LoadAccounts()
+	if err != nil {
+		t.Fatalf("load accounts: %v", err)
+	}
+	if loaded[0].SecretEncoding != EncodingBase32 || loaded[0].EncodingGuess {
+		t.Fatalf("expected strict base32 to migrate cleanly, got %+v", loaded[0])
+	}
+	if loaded[1].SecretEncoding != EncodingBase32 || loaded[1].EncodingGuess {
+		t.Fatalf("expected a lowercase, spaced legacy secret to stay base32, got %+v", loaded[1])
+	}
+	before, _, _ := GenerateAccountCode(Account{Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP"})
+	if after, _, err := GenerateAccountCode(loaded[1]); err != nil || after != before {
+		t.Fatalf("expected the lowercase legacy secret to keep its codes, got %q (%v), want %q", after, err, before)
+	}
+
+	// Old releases uppercased secrets before their base64 fallback, so that
+	// is the key a mixed-case base64 secret has always produced.
+	if loaded[2].SecretEncoding != EncodingBase64 || !loaded[2].EncodingGuess {
+		t.Fatalf("expected a non-base32 legacy secret to keep its base64 reading, got %+v", loaded[2])
+	}
+	legacyKey, _ := base64.StdEncoding.DecodeString("AGVSBG8GD29YBGQHISE=")
+	migratedKey, err := DecodeSecret(loaded[2].Secret, loaded[2].SecretEncoding)
+	if err != nil || !bytes.Equal(migratedKey, legacyKey) {
+		t.Fatalf("expected migration to keep the legacy key, got %x (%v)", migratedKey, err)
+	}
+
+	reloaded, err := service.LoadAccounts()
+	if err != nil {
+		t.Fatalf("reload accounts: %v", err)
+	}
+	if reloaded[1].SecretEncoding != EncodingBase32 || reloaded[2].SecretEncoding != EncodingBase64 {
+		t.Fatalf("expected migration to be persisted, got %+v", reloaded)
+	}
+
+	matched := false
+	for _, item := range AnalyzeAccounts(loaded) {
+		if item.Rule == RuleAmbiguousEncoding && item.Account == "Legacy:b64" {
+			matched = true
+		}
+	}
+	if !matched {
+		t.Fatalf("expected ambiguous-encoding finding for Legacy:b64")
+	}
+}
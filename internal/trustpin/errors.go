@@ -0,0 +1,112 @@
+package trustpin
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error categories. Use errors.Is to branch on them; the concrete error keeps
+// a readable message and, for validation failures, the offending field.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+)
+
+// Specific failures. Each also matches its category above, so callers that
+// only care about "conflict" or "validation" need not list every case.
+var (
+	ErrNameCollision   = fmt.Errorf("name collision: %w", ErrConflict)
+	ErrSecretCollision = fmt.Errorf("secret collision: %w", ErrConflict)
+	ErrInvalidSecret   = fmt.Errorf("invalid secret: %w", ErrValidation)
+	ErrInvalidPolicy   = fmt.Errorf("invalid health policy: %w", ErrValidation)
+)
+
+// Store failures. These are not caused by the request, so they have no
+// category: the store is unreadable until someone fixes it.
+var (
+	// ErrStoreLocked means the store or its key exists but this user may not
+	// open it.
+	ErrStoreLocked = errors.New("store locked")
+	// ErrInsecureStore means the store or key belongs to another user, or
+	// they or the app directory could not be made private. It is a kind of
+	// lock.
+	ErrInsecureStore = fmt.Errorf("insecure store permissions: %w", ErrStoreLocked)
+	// ErrNewerStore means a newer TrustPIN wrote the store. It can still be
+	// read, but this build refuses to write it.
+	ErrNewerStore = fmt.Errorf("store schema too new: %w", ErrStoreLocked)
+	// ErrWrongKey means the encryption key is missing, malformed, or does not
+	// open the store.
+	ErrWrongKey = errors.New("wrong encryption key")
+	// ErrCorruptStore means the store file is not a readable account store.
+	ErrCorruptStore = errors.New("corrupted store")
+)
+
+// Error is a categorised failure returned by the service. Kind is one of the
+// sentinels above and Field names the input that caused it, when known.
+type Error struct {
+	Kind    error
+	Field   string
+	Message string
+	// Err is the underlying cause, if any.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+// ErrorField returns the input field err refers to, or "" when it has none.
+func ErrorField(err error) string {
+	var typed *Error
+	if errors.As(err, &typed) {
+		return typed.Field
+	}
+	return ""
+}
+
+func notFoundf(format string, args ...any) error {
+	return &Error{Kind: ErrNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func conflictf(field, format string, args ...any) error {
+	return &Error{Kind: ErrConflict, Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// collisionf reports a clash with another account; kind is ErrNameCollision or
+// ErrSecretCollision.
+func collisionf(kind error, field, format string, args ...any) error {
+	return &Error{Kind: kind, Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+func invalidf(field, format string, args ...any) error {
+	return &Error{Kind: ErrValidation, Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+func invalidSecretf(format string, args ...any) error {
+	return &Error{Kind: ErrInvalidSecret, Field: "secret", Message: fmt.Sprintf(format, args...)}
+}
+
+func policyErrorf(format string, args ...any) error {
+	return &Error{Kind: ErrInvalidPolicy, Message: fmt.Sprintf(format, args...)}
+}
+
+// storeError marks err as a store failure of the given kind.
+func storeError(kind error, message string, err error) error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// invalidInput marks err, for example a parse failure, as a validation error.
+func invalidInput(field, message string, err error) error {
+	return &Error{Kind: ErrValidation, Field: field, Message: message, Err: err}
+}
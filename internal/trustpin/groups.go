@@ -0,0 +1,111 @@
+package trustpin
+
+import (
+	"sort"
+	"strings"
+)
+
+// Ways accounts can be grouped in the dashboards.
+const (
+	GroupByIssuer = "issuer"
+	GroupByTag    = "tag"
+	GroupByType   = "type"
+)
+
+// AccountGroup is one section of a grouped account list. Key is stable
+// across runs and spellings, so clients can remember a group's state by it;
+// the fallback group for accounts without an issuer or tags has an empty key.
+type AccountGroup[T any] struct {
+	Key      string `json:"key"`
+	Label    string `json:"label"`
+	Count    int    `json:"count"`
+	Accounts []T    `json:"accounts"`
+}
+
+// NormalizeGroupBy checks a grouping name. An empty value or "none" turns
+// grouping off and returns "".
+func NormalizeGroupBy(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "none":
+		return "", nil
+	case GroupByIssuer:
+		return GroupByIssuer, nil
+	case GroupByTag, "tags":
+		return GroupByTag, nil
+	case GroupByType:
+		return GroupByType, nil
+	default:
+		return "", invalidf("groupBy", "unsupported grouping %q (use issuer, tag, or type)", value)
+	}
+}
+
+// GroupAccounts splits items into groups by issuer, tag, or OTP type,
+// keeping their order within each group. Groups are sorted by name, ignoring
+// case, with the fallback group last. With tag grouping an account with
+// several tags appears in each of their groups.
+func GroupAccounts[T any](items []T, by string, account func(T) Account) []AccountGroup[T] {
+	groups := make([]AccountGroup[T], 0)
+	index := make(map[string]int)
+	for _, item := range items {
+		for _, ref := range accountGroupRefs(account(item), by) {
+			i, ok := index[ref.key]
+			if !ok {
+				i = len(groups)
+				index[ref.key] = i
+				groups = append(groups, AccountGroup[T]{Key: ref.key, Label: ref.label})
+			}
+			groups[i].Accounts = append(groups[i].Accounts, item)
+			groups[i].Count++
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if (groups[i].Key == "") != (groups[j].Key == "") {
+			return groups[j].Key == ""
+		}
+		return groups[i].Key < groups[j].Key
+	})
+	return groups
+}
+
+type groupRef struct {
+	key   string
+	label string
+}
+
+func accountGroupRefs(account Account, by string) []groupRef {
+	switch by {
+	case GroupByTag:
+		refs := make([]groupRef, 0, len(account.Tags))
+		seen := make(map[string]bool, len(account.Tags))
+		for _, tag := range account.Tags {
+			tag = strings.TrimSpace(tag)
+			key := strings.ToLower(tag)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			refs = append(refs, groupRef{key: key, label: tag})
+		}
+		if len(refs) == 0 {
+			refs = append(refs, groupRef{label: "Untagged"})
+		}
+		return refs
+	case GroupByType:
+		switch NormalizeType(account.Type) {
+		case TypeHOTP:
+			return []groupRef{{key: TypeHOTP, label: "HOTP"}}
+		case TypeSteam:
+			return []groupRef{{key: TypeSteam, label: "Steam Guard"}}
+		default:
+			return []groupRef{{key: TypeTOTP, label: "TOTP"}}
+		}
+	default:
+		issuer, _, hasIssuer := AccountIssuer(account)
+		issuer = strings.TrimSpace(issuer)
+		if !hasIssuer || issuer == "" {
+			return []groupRef{{label: "Standalone"}}
+		}
+		return []groupRef{{key: strings.ToLower(issuer), label: issuer}}
+	}
+}
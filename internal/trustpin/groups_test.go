@@ -0,0 +1,45 @@
+package trustpin
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGroupAccounts(t *testing.T) {
+	accounts := []Account{
+		{Name: "GitHub:work", Tags: []string{"work", "dev"}},
+		{Name: "standalone"},
+		{Name: "AWS:prod", Type: TypeHOTP, Tags: []string{"work"}},
+		{Name: "github:home"},
+	}
+	identity := func(account Account) Account { return account }
+
+	byIssuer := GroupAccounts(accounts, GroupByIssuer, identity)
+	if len(byIssuer) != 3 || byIssuer[0].Label != "AWS" || byIssuer[1].Key != "github" || byIssuer[1].Count != 2 || byIssuer[2].Label != "Standalone" {
+		t.Fatalf("unexpected issuer groups: %+v", byIssuer)
+	}
+	if byIssuer[1].Accounts[0].Name != "GitHub:work" || byIssuer[1].Label != "GitHub" {
+		t.Fatalf("expected groups to keep the incoming order and first spelling, got %+v", byIssuer[1])
+	}
+
+	byTag := GroupAccounts(accounts, GroupByTag, identity)
+	if len(byTag) != 3 || byTag[0].Key != "dev" || byTag[1].Key != "work" || byTag[1].Count != 2 || byTag[2].Label != "Untagged" || byTag[2].Count != 2 {
+		t.Fatalf("unexpected tag groups: %+v", byTag)
+	}
+
+	byType := GroupAccounts(accounts, GroupByType, identity)
+	if len(byType) != 2 || byType[0].Label != "HOTP" || byType[1].Count != 3 {
+		t.Fatalf("unexpected type groups: %+v", byType)
+	}
+}
+
+func TestNormalizeGroupBy(t *testing.T) {
+	for input, want := range map[string]string{"": "", "none": "", "Issuer": GroupByIssuer, "tags": GroupByTag, "type": GroupByType} {
+		if got, err := NormalizeGroupBy(input); err != nil || got != want {
+			t.Errorf("NormalizeGroupBy(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := NormalizeGroupBy("folder"); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+}
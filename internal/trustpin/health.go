@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 )
 
 type HealthLevel string
@@ -14,11 +15,41 @@ const (
 	HealthLevelInfo     HealthLevel = "info"
 )
 
+const (
+	RuleInvalidSecret    = "invalid-secret"
+	RuleCounterBased     = "hotp-counter"
+	RuleSteamGuard       = "steam-guard"
+	RuleCustomPolicy     = "custom-policy"
+	RuleDuplicateName    = "duplicate-name"
+	RuleSharedSecret     = "shared-secret"
+	RuleMissingIssuer    = "missing-issuer"
+	RuleMinDigits        = "min-digits"
+	RuleMaxInterval      = "max-interval"
+	RuleAllowedAlgorithm = "allowed-algorithm"
+	RuleIssuerPrefix     = "issuer-prefix"
+	RuleRequiredTags     = "required-tags"
+
+	RuleShortSecret       = "short-secret"
+	RuleWeakSecret        = "weak-secret"
+	RuleDemoSecret        = "demo-secret"
+	RuleAmbiguousEncoding = "ambiguous-encoding"
+	RuleLargeCounter      = "hotp-counter-large"
+	RuleSteamSecretLength = "steam-secret-length"
+	RuleMissingRecovery   = "missing-recovery"
+	RuleLowRecoveryCodes  = "low-recovery-codes"
+	RuleStaleArchived     = "stale-archived"
+	RuleFilePermissions   = "file-permissions"
+	RuleUnusedAccount     = "unused-account"
+)
+
 type HealthItem struct {
-	Level   HealthLevel `json:"level"`
-	Title   string      `json:"title"`
-	Detail  string      `json:"detail"`
-	Account string      `json:"account,omitempty"`
+	Rule        string      `json:"rule"`
+	Level       HealthLevel `json:"level"`
+	Title       string      `json:"title"`
+	Detail      string      `json:"detail"`
+	Remediation string      `json:"remediation,omitempty"`
+	Account     string      `json:"account,omitempty"`
+	Accounts    []string    `json:"accounts,omitempty"`
 }
 
 type HealthSummary struct {
@@ -28,46 +59,66 @@ type HealthSummary struct {
 }
 
 type HealthReport struct {
-	Items   []HealthItem  `json:"items"`
-	Summary HealthSummary `json:"summary"`
-	Total   int           `json:"total"`
+	Items      []HealthItem  `json:"items"`
+	Summary    HealthSummary `json:"summary"`
+	Total      int           `json:"total"`
+	Suppressed int           `json:"suppressed,omitempty"`
 }
 
 func AnalyzeAccounts(accounts []Account) []HealthItem {
+	items, _ := AnalyzeAccountsWithPolicy(accounts, DefaultHealthPolicy())
+	return items
+}
+
+// AnalyzeAccountsWithPolicy runs every enabled rule and returns the findings
+// that survive the policy's suppressions, plus how many were suppressed.
+func AnalyzeAccountsWithPolicy(accounts []Account, policy HealthPolicy) ([]HealthItem, int) {
 	items := make([]HealthItem, 0)
 	if len(accounts) == 0 {
-		return items
+		return items, 0
 	}
 
 	nameGroups := make(map[string][]string)
 	secretGroups := make(map[string][]string)
 	missingIssuer := make([]string, 0)
+	missingRecovery := make([]string, 0)
+	archived := make([]string, 0)
+	now := time.Unix(getCurrentTime(), 0)
 
 	for _, account := range accounts {
 		account = sanitizeAccount(account)
 		nameKey := normalizeAccountName(account.Name)
 		nameGroups[nameKey] = append(nameGroups[nameKey], account.Name)
 
-		secretKey := normalizeSecret(account.Secret)
+		secretKey := secretIdentity(account.Secret, account.SecretEncoding)
 		if secretKey != "" {
 			secretGroups[secretKey] = append(secretGroups[secretKey], account.Name)
 		}
 
-		if _, _, hasIssuer := SplitAccountName(account.Name); !hasIssuer {
+		issuer, _, hasIssuer := AccountIssuer(account)
+		if !hasIssuer {
 			missingIssuer = append(missingIssuer, account.Name)
 		}
+		if account.Notes == "" && len(account.RecoveryCodes) == 0 {
+			missingRecovery = append(missingRecovery, account.Name)
+		}
+		if policy.staleArchived(account, now) {
+			archived = append(archived, account.Name)
+		}
 
-		if _, _, err := GenerateTOTP(account.Secret, account.Interval, account.Digits); err != nil {
+		if _, err := DecodeSecret(account.Secret, account.SecretEncoding); err != nil {
 			items = append(items, HealthItem{
+				Rule:    RuleInvalidSecret,
 				Level:   HealthLevelCritical,
 				Title:   "Invalid secret",
-				Detail:  fmt.Sprintf("%s cannot generate OTP codes because the secret is not valid base32 or base64.", account.Name),
+				Detail:  fmt.Sprintf("%s cannot generate OTP codes because the secret is not valid %s.", account.Name, describeEncoding(account.SecretEncoding)),
 				Account: account.Name,
 			})
 		}
 
 		if account.Type == TypeHOTP {
 			items = append(items, HealthItem{
+				Rule:    RuleCounterBased,
 				Level:   HealthLevelInfo,
 				Title:   "Counter-based OTP",
 				Detail:  fmt.Sprintf("%s uses HOTP (counter %d). Counter desync may require re-enrollment.", account.Name, account.Counter),
@@ -77,6 +128,7 @@ func AnalyzeAccounts(accounts []Account) []HealthItem {
 
 		if account.Type == TypeSteam {
 			items = append(items, HealthItem{
+				Rule:    RuleSteamGuard,
 				Level:   HealthLevelInfo,
 				Title:   "Steam Guard",
 				Detail:  fmt.Sprintf("%s uses Steam Guard authentication with 5-character alphanumeric codes.", account.Name),
@@ -91,52 +143,99 @@ func AnalyzeAccounts(accounts []Account) []HealthItem {
 			}
 
 			items = append(items, HealthItem{
+				Rule:    RuleCustomPolicy,
 				Level:   level,
 				Title:   "Custom policy",
 				Detail:  fmt.Sprintf("%s uses %d digits and a %ds rotation window.", account.Name, account.Digits, account.Interval),
 				Account: account.Name,
 			})
 		}
+
+		if minCodes := policy.minRecoveryCodes(); len(account.RecoveryCodes) > 0 && UnusedRecoveryCodes(account) < minCodes {
+			unused := UnusedRecoveryCodes(account)
+			items = append(items, HealthItem{
+				Rule:    RuleLowRecoveryCodes,
+				Level:   HealthLevelWarning,
+				Title:   "Running out of recovery codes",
+				Detail:  fmt.Sprintf("%s has %d unused recovery %s left out of %d.", account.Name, unused, pluralize("code", "codes", unused), len(account.RecoveryCodes)),
+				Account: account.Name,
+			})
+		}
+
+		items = append(items, secretFindings(account, policy)...)
+		items = append(items, policy.thresholdFindings(account, issuer)...)
 	}
 
-	for _, names := range nameGroups {
+	for _, key := range sortedGroupKeys(nameGroups) {
+		names := nameGroups[key]
 		if len(names) <= 1 {
 			continue
 		}
 		sort.Strings(names)
 		items = append(items, HealthItem{
-			Level:  HealthLevelWarning,
-			Title:  "Duplicate names",
-			Detail: fmt.Sprintf("Multiple entries share the same account name: %s.", strings.Join(names, ", ")),
+			Rule:     RuleDuplicateName,
+			Level:    HealthLevelWarning,
+			Title:    "Duplicate names",
+			Detail:   fmt.Sprintf("Multiple entries share the same account name: %s.", strings.Join(names, ", ")),
+			Accounts: names,
 		})
 	}
 
-	for _, names := range secretGroups {
+	for _, key := range sortedGroupKeys(secretGroups) {
+		names := secretGroups[key]
 		if len(names) <= 1 {
 			continue
 		}
 		sort.Strings(names)
 		items = append(items, HealthItem{
-			Level:  HealthLevelWarning,
-			Title:  "Shared secret",
-			Detail: fmt.Sprintf("These accounts appear to reuse the same secret: %s.", strings.Join(names, ", ")),
+			Rule:     RuleSharedSecret,
+			Level:    HealthLevelWarning,
+			Title:    "Shared secret",
+			Detail:   fmt.Sprintf("These accounts appear to reuse the same secret: %s.", strings.Join(names, ", ")),
+			Accounts: names,
 		})
 	}
 
 	if len(missingIssuer) > 0 {
 		sort.Strings(missingIssuer)
 		items = append(items, HealthItem{
-			Level:  HealthLevelInfo,
-			Title:  "Ungrouped names",
-			Detail: fmt.Sprintf("%d %s do not include an issuer prefix. Naming them as Issuer:Label improves dashboard grouping.", len(missingIssuer), pluralize("account", "accounts", len(missingIssuer))),
+			Rule:     RuleMissingIssuer,
+			Level:    HealthLevelInfo,
+			Title:    "Ungrouped names",
+			Detail:   fmt.Sprintf("%d %s do not include an issuer prefix. Naming them as Issuer:Label improves dashboard grouping.", len(missingIssuer), pluralize("account", "accounts", len(missingIssuer))),
+			Accounts: missingIssuer,
+		})
+	}
+
+	if len(missingRecovery) > 0 {
+		sort.Strings(missingRecovery)
+		items = append(items, HealthItem{
+			Rule:     RuleMissingRecovery,
+			Level:    HealthLevelInfo,
+			Title:    "No recovery information",
+			Detail:   fmt.Sprintf("%d %s have no notes or recovery codes. Losing this device would lock you out of them.", len(missingRecovery), pluralize("account", "accounts", len(missingRecovery))),
+			Accounts: missingRecovery,
 		})
 	}
 
+	if len(archived) > 0 {
+		sort.Strings(archived)
+		items = append(items, HealthItem{
+			Rule:     RuleStaleArchived,
+			Level:    HealthLevelInfo,
+			Title:    "Stale archived entries",
+			Detail:   fmt.Sprintf("%d %s archived over %d days ago still keep live secrets in the store: %s.", len(archived), pluralize("account", "accounts", len(archived)), policy.staleArchivedDays(), strings.Join(archived, ", ")),
+			Accounts: archived,
+		})
+	}
+
+	items, suppressed := policy.apply(items)
+
 	sort.SliceStable(items, func(i, j int) bool {
 		return healthPriority(items[i].Level) < healthPriority(items[j].Level)
 	})
 
-	return items
+	return items, suppressed
 }
 
 func SummarizeHealth(items []HealthItem) HealthSummary {
@@ -154,20 +253,44 @@ func SummarizeHealth(items []HealthItem) HealthSummary {
 	return summary
 }
 
+// HealthReport audits the store using the policy file from the app directory
+// when one exists, and the built-in defaults otherwise.
 func (s Service) HealthReport() (HealthReport, error) {
-	accounts, err := s.LoadAccounts()
+	policy, err := LoadHealthPolicy("")
+	if err != nil {
+		return HealthReport{}, err
+	}
+	return s.HealthReportWithPolicy(policy)
+}
+
+func (s Service) HealthReportWithPolicy(policy HealthPolicy) (HealthReport, error) {
+	accounts, fixes, err := s.loadAccounts()
 	if err != nil {
 		return HealthReport{}, err
 	}
 
-	items := AnalyzeAccounts(accounts)
+	items, suppressed := AnalyzeAccountsWithPolicy(accounts, policy)
+	if len(fixes) > 0 {
+		storeItems, storeSuppressed := policy.apply(permissionFindings(fixes))
+		items = append(storeItems, items...)
+		suppressed += storeSuppressed
+		sort.SliceStable(items, func(i, j int) bool {
+			return healthPriority(items[i].Level) < healthPriority(items[j].Level)
+		})
+	}
 	return HealthReport{
-		Items:   items,
-		Summary: SummarizeHealth(items),
-		Total:   len(accounts),
+		Items:      items,
+		Summary:    SummarizeHealth(items),
+		Total:      len(accounts),
+		Suppressed: suppressed,
 	}, nil
 }
 
+// HealthLevelAtLeast reports whether level is as severe as threshold.
+func HealthLevelAtLeast(level, threshold HealthLevel) bool {
+	return healthPriority(level) <= healthPriority(threshold)
+}
+
 func healthPriority(level HealthLevel) int {
 	switch level {
 	case HealthLevelCritical:
@@ -179,6 +302,15 @@ func healthPriority(level HealthLevel) int {
 	}
 }
 
+func sortedGroupKeys(groups map[string][]string) []string {
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func pluralize(singular, plural string, count int) string {
 	if count == 1 {
 		return singular
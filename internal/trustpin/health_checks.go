@@ -0,0 +1,151 @@
+package trustpin
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	minimumSecretBits     = 80
+	recommendedSecretBits = 128
+	steamSecretBytes      = 20
+	defaultMaxHOTPCounter = 1_000_000
+)
+
+// knownDemoSecrets are keys published in RFCs, tutorials and library READMEs.
+var knownDemoSecrets = map[string]string{
+	"JBSWY3DPEHPK3PXP":                 "the widely published JBSWY3DPEHPK3PXP demo key",
+	"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ": "the RFC 4226/6238 test key",
+}
+
+// secretFindings inspects the decoded key material of a single account.
+func secretFindings(account Account, policy HealthPolicy) []HealthItem {
+	items := make([]HealthItem, 0)
+	if strings.TrimSpace(account.Secret) == "" {
+		return items
+	}
+
+	if demo, ok := knownDemoSecrets[secretIdentity(account.Secret, account.SecretEncoding)]; ok {
+		items = append(items, HealthItem{
+			Rule:    RuleDemoSecret,
+			Level:   HealthLevelCritical,
+			Title:   "Published demo secret",
+			Detail:  fmt.Sprintf("%s uses %s, which anyone can use to generate its codes.", account.Name, demo),
+			Account: account.Name,
+		})
+	}
+
+	if account.EncodingGuess {
+		items = append(items, HealthItem{
+			Rule:    RuleAmbiguousEncoding,
+			Level:   HealthLevelWarning,
+			Title:   "Ambiguous encoding",
+			Detail:  fmt.Sprintf("%s was saved without an explicit encoding and was read as %s. Most providers issue base32 secrets, so the stored value may have been altered.", account.Name, describeEncoding(account.SecretEncoding)),
+			Account: account.Name,
+		})
+	}
+
+	decoded, err := DecodeSecret(account.Secret, account.SecretEncoding)
+	if err != nil || len(decoded) == 0 {
+		return items
+	}
+
+	bits := len(decoded) * 8
+	switch {
+	case bits < minimumSecretBits:
+		items = append(items, HealthItem{
+			Rule:    RuleShortSecret,
+			Level:   HealthLevelWarning,
+			Title:   "Short secret",
+			Detail:  fmt.Sprintf("%s has a %d-bit secret, below the %d-bit minimum.", account.Name, bits, minimumSecretBits),
+			Account: account.Name,
+		})
+	case bits < recommendedSecretBits:
+		items = append(items, HealthItem{
+			Rule:    RuleShortSecret,
+			Level:   HealthLevelInfo,
+			Title:   "Short secret",
+			Detail:  fmt.Sprintf("%s has a %d-bit secret, below the recommended %d bits.", account.Name, bits, recommendedSecretBits),
+			Account: account.Name,
+		})
+	}
+
+	if pattern := secretPattern(decoded); pattern != "" {
+		items = append(items, HealthItem{
+			Rule:    RuleWeakSecret,
+			Level:   HealthLevelWarning,
+			Title:   "Low-entropy secret",
+			Detail:  fmt.Sprintf("%s has a secret made of %s.", account.Name, pattern),
+			Account: account.Name,
+		})
+	}
+
+	if account.Type == TypeSteam && len(decoded) != steamSecretBytes {
+		items = append(items, HealthItem{
+			Rule:    RuleSteamSecretLength,
+			Level:   HealthLevelWarning,
+			Title:   "Unexpected Steam secret",
+			Detail:  fmt.Sprintf("%s has a %d-byte secret; Steam Guard shared secrets are %d bytes.", account.Name, len(decoded), steamSecretBytes),
+			Account: account.Name,
+		})
+	}
+
+	if account.Type == TypeHOTP {
+		limit := policy.Thresholds.MaxHOTPCounter
+		if limit <= 0 {
+			limit = defaultMaxHOTPCounter
+		}
+		if account.Counter > limit {
+			items = append(items, HealthItem{
+				Rule:    RuleLargeCounter,
+				Level:   HealthLevelWarning,
+				Title:   "Large HOTP counter",
+				Detail:  fmt.Sprintf("%s is at counter %d, above %d. This usually means the counter was mistyped or the account was replayed.", account.Name, account.Counter, limit),
+				Account: account.Name,
+			})
+		}
+	}
+
+	return items
+}
+
+// secretPattern describes an obviously non-random key, or returns "".
+func secretPattern(decoded []byte) string {
+	if len(decoded) < 2 {
+		return ""
+	}
+
+	if bytes.Count(decoded, decoded[:1]) == len(decoded) {
+		return "a single repeated byte"
+	}
+
+	for period := 2; period <= 4 && period*2 <= len(decoded); period++ {
+		if bytes.Equal(decoded[period:], decoded[:len(decoded)-period]) {
+			return fmt.Sprintf("a repeating %d-byte pattern", period)
+		}
+	}
+
+	ascending, descending := true, true
+	for i := 1; i < len(decoded); i++ {
+		if decoded[i] != decoded[i-1]+1 {
+			ascending = false
+		}
+		if decoded[i] != decoded[i-1]-1 {
+			descending = false
+		}
+	}
+	if ascending || descending {
+		return "sequential bytes"
+	}
+
+	distinct := make(map[byte]struct{}, len(decoded))
+	for _, b := range decoded {
+		distinct[b] = struct{}{}
+	}
+	if len(decoded) >= 8 && len(distinct) <= len(decoded)/4 {
+		return fmt.Sprintf("only %d distinct byte values", len(distinct))
+	}
+
+	return ""
+}
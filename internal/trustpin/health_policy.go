@@ -0,0 +1,344 @@
+package trustpin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultHealthPolicyFileName = "health-rules.yaml"
+
+// HealthRule describes a check that AnalyzeAccounts can report. Policy-only
+// rules stay silent until their threshold is configured.
+type HealthRule struct {
+	ID          string
+	Title       string
+	Description string
+	Remediation string
+}
+
+var healthRules = []HealthRule{
+	{ID: RuleInvalidSecret, Title: "Invalid secret", Description: "The secret cannot be decoded, so no OTP codes can be generated.", Remediation: "Re-import the account from the provider's QR code or edit the secret."},
+	{ID: RuleCounterBased, Title: "Counter-based OTP", Description: "HOTP accounts can drift out of sync with the server counter.", Remediation: "Prefer TOTP when the provider supports it, or resynchronise the counter with the provider."},
+	{ID: RuleSteamGuard, Title: "Steam Guard", Description: "Steam Guard accounts use a proprietary 5-character code format."},
+	{ID: RuleCustomPolicy, Title: "Custom policy", Description: "The TOTP account deviates from the standard 6 digits / 30 seconds policy.", Remediation: "Confirm the digits and interval match what the provider issued."},
+	{ID: RuleDuplicateName, Title: "Duplicate names", Description: "Several entries share the same account name.", Remediation: "Rename or delete the duplicates so each entry is unambiguous."},
+	{ID: RuleSharedSecret, Title: "Shared secret", Description: "Several entries reuse the same secret.", Remediation: "Delete the stale copies; if the accounts are distinct, re-enroll one of them."},
+	{ID: RuleMissingIssuer, Title: "Ungrouped names", Description: "Accounts without an Issuer:Label name cannot be grouped.", Remediation: "Rename the accounts as Issuer:Label."},
+	{ID: RuleMinDigits, Title: "Too few digits", Description: "The account generates fewer digits than the policy minimum.", Remediation: "Re-enroll the account with a longer code if the provider allows it."},
+	{ID: RuleMaxInterval, Title: "Interval too long", Description: "The account rotates more slowly than the policy maximum.", Remediation: "Re-enroll the account with a shorter rotation interval."},
+	{ID: RuleAllowedAlgorithm, Title: "Disallowed algorithm", Description: "The account uses a hash algorithm outside the policy allow-list.", Remediation: "Re-enroll the account with an allowed algorithm."},
+	{ID: RuleIssuerPrefix, Title: "Issuer prefix", Description: "The account issuer does not start with the required prefix.", Remediation: "Rename the account so its issuer carries the required prefix."},
+	{ID: RuleRequiredTags, Title: "Missing tags", Description: "The account is missing one or more required tags.", Remediation: "Add the missing tags when editing the account."},
+	{ID: RuleShortSecret, Title: "Short secret", Description: "The decoded secret is shorter than 80 bits (warning) or 128 bits (info).", Remediation: "Re-enroll the account if the provider issues longer secrets; RFC 4226 requires at least 128 bits and recommends 160."},
+	{ID: RuleWeakSecret, Title: "Low-entropy secret", Description: "The decoded secret is repeated, sequential or otherwise patterned.", Remediation: "Re-enroll the account so the provider issues a freshly generated secret."},
+	{ID: RuleDemoSecret, Title: "Published demo secret", Description: "The secret is a well-known example key.", Remediation: "Replace the account with a real enrollment from the provider, or delete it if it was only a test."},
+	{ID: RuleAmbiguousEncoding, Title: "Ambiguous encoding", Description: "The secret was saved without an encoding, and TrustPIN inferred a non-base32 one, such as base64 or hex, from its value.", Remediation: "Check the secret against the provider's setup page and re-enter it in its original encoding."},
+	{ID: RuleLargeCounter, Title: "Large HOTP counter", Description: "The HOTP counter is implausibly large.", Remediation: "Verify the counter with the provider and correct it."},
+	{ID: RuleSteamSecretLength, Title: "Unexpected Steam secret", Description: "The Steam Guard shared secret is not 20 bytes.", Remediation: "Re-export the shared secret from the Steam mobile authenticator."},
+	{ID: RuleMissingRecovery, Title: "No recovery information", Description: "The account has no notes or recovery codes.", Remediation: "Store the provider's backup codes with the account."},
+	{ID: RuleLowRecoveryCodes, Title: "Running out of recovery codes", Description: "Fewer unused recovery codes remain than the policy minimum (3 by default).", Remediation: "Generate a fresh set of backup codes with the provider and add them with `trustpin recovery add`."},
+	{ID: RuleStaleArchived, Title: "Stale archived entries", Description: "Accounts archived and left unchanged for longer than the policy's staleArchivedDays (90 by default) still hold live secrets.", Remediation: "Delete archived accounts you no longer need, and disable 2FA at the provider first if the account is gone."},
+	{ID: RuleFilePermissions, Title: "Permissions tightened", Description: "The store, key or app directory was readable by other users when it was loaded, and TrustPIN made it private; or a directory holding them belongs to another user.", Remediation: "Find what loosened the mode, such as a copy, a restore from backup or a permissive umask, so it does not happen again."},
+	{ID: RuleUnusedAccount, Title: "Unused account", Description: "The account has not been used for longer than the policy's maxUnusedDays.", Remediation: "Check whether the account still exists; archive or delete it if it does not."},
+}
+
+type HealthRuleSetting struct {
+	Enabled *bool       `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Level   HealthLevel `yaml:"level,omitempty" json:"level,omitempty"`
+}
+
+type HealthThresholds struct {
+	MinDigits            int      `yaml:"minDigits,omitempty" json:"minDigits,omitempty"`
+	MaxInterval          int64    `yaml:"maxInterval,omitempty" json:"maxInterval,omitempty"`
+	AllowedAlgorithms    []string `yaml:"allowedAlgorithms,omitempty" json:"allowedAlgorithms,omitempty"`
+	RequiredIssuerPrefix string   `yaml:"requiredIssuerPrefix,omitempty" json:"requiredIssuerPrefix,omitempty"`
+	RequiredTags         []string `yaml:"requiredTags,omitempty" json:"requiredTags,omitempty"`
+	MaxHOTPCounter       int64    `yaml:"maxHotpCounter,omitempty" json:"maxHotpCounter,omitempty"`
+	MinRecoveryCodes     int      `yaml:"minRecoveryCodes,omitempty" json:"minRecoveryCodes,omitempty"`
+	MaxUnusedDays        int      `yaml:"maxUnusedDays,omitempty" json:"maxUnusedDays,omitempty"`
+	StaleArchivedDays    int      `yaml:"staleArchivedDays,omitempty" json:"staleArchivedDays,omitempty"`
+}
+
+// DefaultStaleArchivedDays is how long an account may stay archived before
+// the health audit suggests deleting it, unless the policy sets
+// staleArchivedDays.
+const DefaultStaleArchivedDays = 90
+
+type HealthSuppression struct {
+	Rule    string `yaml:"rule" json:"rule"`
+	Account string `yaml:"account,omitempty" json:"account,omitempty"`
+	Reason  string `yaml:"reason,omitempty" json:"reason,omitempty"`
+}
+
+type HealthPolicy struct {
+	Rules      map[string]HealthRuleSetting `yaml:"rules,omitempty" json:"rules,omitempty"`
+	Thresholds HealthThresholds             `yaml:"thresholds,omitempty" json:"thresholds,omitempty"`
+	Suppress   []HealthSuppression          `yaml:"suppress,omitempty" json:"suppress,omitempty"`
+}
+
+func HealthRules() []HealthRule {
+	rules := make([]HealthRule, len(healthRules))
+	copy(rules, healthRules)
+	return rules
+}
+
+func DefaultHealthPolicy() HealthPolicy {
+	return HealthPolicy{Rules: map[string]HealthRuleSetting{}}
+}
+
+func DefaultHealthPolicyPath() string {
+	return filepath.Join(defaultAppDir(), DefaultHealthPolicyFileName)
+}
+
+// LoadHealthPolicy reads a policy file. An empty path loads the default policy
+// file when it exists; an explicit path must exist.
+func LoadHealthPolicy(path string) (HealthPolicy, error) {
+	explicit := strings.TrimSpace(path) != ""
+	if !explicit {
+		path = DefaultHealthPolicyPath()
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !explicit {
+		return DefaultHealthPolicy(), nil
+	}
+	if err != nil {
+		return HealthPolicy{}, fmt.Errorf("read health rules: %w", err)
+	}
+
+	policy := DefaultHealthPolicy()
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return HealthPolicy{}, &Error{Kind: ErrInvalidPolicy, Message: "parse health rules " + path, Err: err}
+	}
+	if err := policy.Validate(); err != nil {
+		return HealthPolicy{}, fmt.Errorf("health rules %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+func (p HealthPolicy) Validate() error {
+	known := make(map[string]struct{}, len(healthRules))
+	for _, rule := range healthRules {
+		known[rule.ID] = struct{}{}
+	}
+
+	ids := make([]string, 0, len(p.Rules))
+	for id := range p.Rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if _, ok := known[id]; !ok {
+			return policyErrorf("unknown rule %q", id)
+		}
+		switch p.Rules[id].Level {
+		case "", HealthLevelCritical, HealthLevelWarning, HealthLevelInfo:
+		default:
+			return policyErrorf("rule %q has unsupported level %q", id, p.Rules[id].Level)
+		}
+	}
+
+	for _, suppression := range p.Suppress {
+		if _, ok := known[suppression.Rule]; !ok {
+			return policyErrorf("suppression references unknown rule %q", suppression.Rule)
+		}
+	}
+	if p.Thresholds.MinDigits < 0 || p.Thresholds.MaxInterval < 0 || p.Thresholds.MaxHOTPCounter < 0 || p.Thresholds.MinRecoveryCodes < 0 || p.Thresholds.MaxUnusedDays < 0 || p.Thresholds.StaleArchivedDays < 0 {
+		return policyErrorf("thresholds cannot be negative")
+	}
+	return nil
+}
+
+func (p HealthPolicy) enabled(rule string) bool {
+	setting, ok := p.Rules[rule]
+	if !ok || setting.Enabled == nil {
+		return true
+	}
+	return *setting.Enabled
+}
+
+func (p HealthPolicy) minRecoveryCodes() int {
+	if p.Thresholds.MinRecoveryCodes > 0 {
+		return p.Thresholds.MinRecoveryCodes
+	}
+	return DefaultMinRecoveryCodes
+}
+
+// staleArchived reports whether account was archived more than the policy's
+// staleArchivedDays ago. Archiving stamps UpdatedAt; an archived account from
+// before timestamps were recorded is old enough by definition.
+func (p HealthPolicy) staleArchived(account Account, now time.Time) bool {
+	if !account.Archived {
+		return false
+	}
+	return account.UpdatedAt.IsZero() || now.Sub(account.UpdatedAt) > time.Duration(p.staleArchivedDays())*24*time.Hour
+}
+
+func (p HealthPolicy) staleArchivedDays() int {
+	if p.Thresholds.StaleArchivedDays > 0 {
+		return p.Thresholds.StaleArchivedDays
+	}
+	return DefaultStaleArchivedDays
+}
+
+func (p HealthPolicy) thresholdFindings(account Account, issuer string) []HealthItem {
+	items := make([]HealthItem, 0)
+	t := p.Thresholds
+
+	if t.MinDigits > 0 && account.Type != TypeSteam && account.Digits < t.MinDigits {
+		items = append(items, HealthItem{
+			Rule:    RuleMinDigits,
+			Level:   HealthLevelWarning,
+			Title:   "Too few digits",
+			Detail:  fmt.Sprintf("%s uses %d digits; policy requires at least %d.", account.Name, account.Digits, t.MinDigits),
+			Account: account.Name,
+		})
+	}
+
+	if t.MaxInterval > 0 && account.Type != TypeHOTP && account.Interval > t.MaxInterval {
+		items = append(items, HealthItem{
+			Rule:    RuleMaxInterval,
+			Level:   HealthLevelWarning,
+			Title:   "Interval too long",
+			Detail:  fmt.Sprintf("%s rotates every %ds; policy allows at most %ds.", account.Name, account.Interval, t.MaxInterval),
+			Account: account.Name,
+		})
+	}
+
+	if len(t.AllowedAlgorithms) > 0 {
+		allowed := false
+		for _, algorithm := range t.AllowedAlgorithms {
+			if NormalizeAlgorithm(algorithm) == account.Algorithm {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			items = append(items, HealthItem{
+				Rule:    RuleAllowedAlgorithm,
+				Level:   HealthLevelWarning,
+				Title:   "Disallowed algorithm",
+				Detail:  fmt.Sprintf("%s uses %s; policy allows %s.", account.Name, account.Algorithm, strings.Join(t.AllowedAlgorithms, ", ")),
+				Account: account.Name,
+			})
+		}
+	}
+
+	if prefix := strings.TrimSpace(t.RequiredIssuerPrefix); prefix != "" && !strings.HasPrefix(strings.ToLower(issuer), strings.ToLower(prefix)) {
+		items = append(items, HealthItem{
+			Rule:    RuleIssuerPrefix,
+			Level:   HealthLevelWarning,
+			Title:   "Issuer prefix",
+			Detail:  fmt.Sprintf("%s does not use an issuer starting with %q.", account.Name, prefix),
+			Account: account.Name,
+		})
+	}
+
+	if len(t.RequiredTags) > 0 {
+		have := make(map[string]struct{}, len(account.Tags))
+		for _, tag := range account.Tags {
+			have[strings.ToLower(strings.TrimSpace(tag))] = struct{}{}
+		}
+		missing := make([]string, 0)
+		for _, tag := range t.RequiredTags {
+			if _, ok := have[strings.ToLower(strings.TrimSpace(tag))]; !ok {
+				missing = append(missing, tag)
+			}
+		}
+		if len(missing) > 0 {
+			items = append(items, HealthItem{
+				Rule:    RuleRequiredTags,
+				Level:   HealthLevelWarning,
+				Title:   "Missing tags",
+				Detail:  fmt.Sprintf("%s is missing required %s: %s.", account.Name, pluralize("tag", "tags", len(missing)), strings.Join(missing, ", ")),
+				Account: account.Name,
+			})
+		}
+	}
+
+	if t.MaxUnusedDays > 0 && !account.Archived {
+		if item, ok := unusedAccountFinding(account, t.MaxUnusedDays, time.Unix(getCurrentTime(), 0)); ok {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// unusedAccountFinding flags an account not used for more than maxDays,
+// counting from when it was added if it was never used. Accounts from before
+// timestamps were recorded have neither and are skipped.
+func unusedAccountFinding(account Account, maxDays int, now time.Time) (HealthItem, bool) {
+	since, detail := account.LastUsedAt, "was last used"
+	if since.IsZero() {
+		since, detail = account.CreatedAt, "has not been used since it was added"
+	}
+	if since.IsZero() {
+		return HealthItem{}, false
+	}
+	days := int(now.Sub(since).Hours() / 24)
+	if days <= maxDays {
+		return HealthItem{}, false
+	}
+	return HealthItem{
+		Rule:    RuleUnusedAccount,
+		Level:   HealthLevelWarning,
+		Title:   "Unused account",
+		Detail:  fmt.Sprintf("%s %s %d days ago; policy allows %d.", account.Name, detail, days, maxDays),
+		Account: account.Name,
+	}, true
+}
+
+// apply drops disabled rules and suppressed findings, applies level
+// overrides and fills in remediation text from the rule catalog.
+func (p HealthPolicy) apply(items []HealthItem) ([]HealthItem, int) {
+	remediation := make(map[string]string, len(healthRules))
+	for _, rule := range healthRules {
+		remediation[rule.ID] = rule.Remediation
+	}
+
+	kept := make([]HealthItem, 0, len(items))
+	suppressed := 0
+	for _, item := range items {
+		if !p.enabled(item.Rule) {
+			continue
+		}
+		if p.suppresses(item) {
+			suppressed++
+			continue
+		}
+		if level := p.Rules[item.Rule].Level; level != "" {
+			item.Level = level
+		}
+		if item.Remediation == "" {
+			item.Remediation = remediation[item.Rule]
+		}
+		kept = append(kept, item)
+	}
+	return kept, suppressed
+}
+
+func (p HealthPolicy) suppresses(item HealthItem) bool {
+	for _, suppression := range p.Suppress {
+		if suppression.Rule != item.Rule {
+			continue
+		}
+
+		target := normalizeAccountName(suppression.Account)
+		if target == "" || normalizeAccountName(item.Account) == target {
+			return true
+		}
+		for _, name := range item.Accounts {
+			if normalizeAccountName(name) == target {
+				return true
+			}
+		}
+	}
+	return false
+}
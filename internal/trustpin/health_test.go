@@ -1,6 +1,11 @@
 package trustpin
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
 
 func TestAnalyzeAccountsFlagsInvalidSecretsAndUngroupedNames(t *testing.T) {
 	accounts := []Account{
@@ -34,3 +39,134 @@ func TestSplitAccountName(t *testing.T) {
 		t.Fatalf("unexpected split %q / %q", issuer, label)
 	}
 }
+
+func TestAnalyzeAccountsWithPolicyThresholdsAndSuppressions(t *testing.T) {
+	disabled := false
+	policy := HealthPolicy{
+		Rules: map[string]HealthRuleSetting{
+			RuleMissingIssuer: {Enabled: &disabled},
+			RuleCounterBased:  {Level: HealthLevelCritical},
+		},
+		Thresholds: HealthThresholds{
+			MinDigits:            8,
+			AllowedAlgorithms:    []string{"sha256"},
+			RequiredIssuerPrefix: "Corp",
+			RequiredTags:         []string{"owner"},
+		},
+		Suppress: []HealthSuppression{{Rule: RuleMinDigits, Account: "corp-vpn:alice"}},
+	}
+
+	items, suppressed := AnalyzeAccountsWithPolicy([]Account{
+		{Name: "Corp-VPN:alice", Secret: "7UY5KALYJQKWLHM4GJUBDHQ7RQLNIK5X", Interval: 30, Digits: 6, Algorithm: AlgorithmSHA256, Tags: []string{"Owner"}},
+		{Name: "Standalone", Secret: "MFRGGZDFMZTWQ2LK", Digits: 6, Type: TypeHOTP},
+	}, policy)
+
+	if suppressed != 1 {
+		t.Fatalf("expected one suppressed finding, got %d", suppressed)
+	}
+
+	rules := make(map[string]HealthItem)
+	for _, item := range items {
+		if item.Rule == "" {
+			t.Fatalf("expected every finding to carry a rule id: %+v", item)
+		}
+		if item.Account == "Corp-VPN:alice" {
+			t.Fatalf("expected compliant account to have no findings, got %+v", item)
+		}
+		rules[item.Rule] = item
+	}
+
+	for _, rule := range []string{RuleMinDigits, RuleAllowedAlgorithm, RuleIssuerPrefix, RuleRequiredTags} {
+		if _, ok := rules[rule]; !ok {
+			t.Fatalf("expected %s finding, got %+v", rule, items)
+		}
+	}
+	if _, ok := rules[RuleMissingIssuer]; ok {
+		t.Fatalf("expected disabled rule to be skipped")
+	}
+	if rules[RuleCounterBased].Level != HealthLevelCritical {
+		t.Fatalf("expected level override, got %q", rules[RuleCounterBased].Level)
+	}
+}
+
+func TestLoadHealthPolicyRejectsUnknownRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "health-rules.yaml")
+	if err := os.WriteFile(path, []byte("rules:\n  no-such-rule:\n    enabled: false\n"), 0o600); err != nil {
+		t.Fatalf("write rules: %v", err)
+	}
+
+	if _, err := LoadHealthPolicy(path); err == nil {
+		t.Fatalf("expected unknown rule to be rejected")
+	}
+}
+
+func TestAnalyzeAccountsFlagsWeakSecrets(t *testing.T) {
+	items := AnalyzeAccounts([]Account{
+		{Name: "Demo:key", Secret: "JBSWY3DPEHPK3PXP", Notes: "codes"},
+		{Name: "Zero:key", Secret: "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", Notes: "codes"},
+		{Name: "Steam:short", Secret: "7UY5KALYJQKWLHM4", Type: TypeSteam, Notes: "codes"},
+		{Name: "Counter:big", Secret: "7UY5KALYJQKWLHM4GJUBDHQ7RQLNIK5X", Type: TypeHOTP, Counter: 5_000_000, Notes: "codes"},
+		{Name: "Base64:only", Secret: "dGVzdC1zZWNyZXQtdmFsdWUx", Notes: "codes"},
+		{Name: "Strong:key", Secret: "7UY5KALYJQKWLHM4GJUBDHQ7RQLNIK5X", Archived: true},
+	})
+
+	found := make(map[string][]string)
+	for _, item := range items {
+		if item.Remediation == "" && item.Rule != RuleSteamGuard {
+			t.Fatalf("expected remediation text for %s", item.Rule)
+		}
+		found[item.Rule] = append(found[item.Rule], item.Account)
+	}
+
+	expect := map[string]string{
+		RuleDemoSecret:        "Demo:key",
+		RuleWeakSecret:        "Zero:key",
+		RuleSteamSecretLength: "Steam:short",
+		RuleLargeCounter:      "Counter:big",
+		RuleAmbiguousEncoding: "Base64:only",
+		RuleShortSecret:       "Demo:key",
+	}
+	for rule, account := range expect {
+		matched := false
+		for _, name := range found[rule] {
+			if name == account {
+				matched = true
+			}
+		}
+		if !matched {
+			t.Fatalf("expected %s finding for %s, got %v", rule, account, found)
+		}
+	}
+
+	if _, ok := found[RuleMissingRecovery]; !ok {
+		t.Fatalf("expected missing recovery finding")
+	}
+	if _, ok := found[RuleStaleArchived]; !ok {
+		t.Fatalf("expected archived entry finding")
+	}
+}
+
+func TestStaleArchivedNeedsAge(t *testing.T) {
+	now := time.Now()
+	policy := DefaultHealthPolicy()
+	cases := []struct {
+		name    string
+		account Account
+		want    bool
+	}{
+		{"active", Account{UpdatedAt: now.AddDate(-1, 0, 0)}, false},
+		{"archived last week", Account{Archived: true, UpdatedAt: now.AddDate(0, 0, -7)}, false},
+		{"archived last year", Account{Archived: true, UpdatedAt: now.AddDate(-1, 0, 0)}, true},
+		{"archived before timestamps", Account{Archived: true}, true},
+	}
+	for _, c := range cases {
+		if got := policy.staleArchived(c.account, now); got != c.want {
+			t.Errorf("%s: stale %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	policy.Thresholds.StaleArchivedDays = 5
+	if !policy.staleArchived(cases[1].account, now) {
+		t.Fatalf("expected staleArchivedDays to shorten the threshold")
+	}
+}
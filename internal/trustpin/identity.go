@@ -0,0 +1,94 @@
+package trustpin
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+// NewAccountID returns a random RFC 4122 version 4 UUID.
+func NewAccountID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("trustpin: read random account ID: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// FindAccountIndex resolves a reference that is either an account ID or an
+// account name. IDs win over names so a rename can never redirect a lookup.
+func FindAccountIndex(accounts []Account, ref string) int {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return -1
+	}
+
+	for i, account := range accounts {
+		if account.ID != "" && strings.EqualFold(account.ID, ref) {
+			return i
+		}
+	}
+
+	nameKey := normalizeAccountName(ref)
+	for i, account := range accounts {
+		if normalizeAccountName(account.Name) == nameKey {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetAccount loads the account matching an ID or name.
+func (s Service) GetAccount(ref string) (Account, error) {
+	accounts, err := s.LoadAccounts()
+	if err != nil {
+		return Account{}, fmt.Errorf("load accounts: %w", err)
+	}
+
+	idx := FindAccountIndex(accounts, ref)
+	if idx == -1 {
+		return Account{}, notFoundf("no account found matching %q", ref)
+	}
+	return accounts[idx], nil
+}
+
+// ReorderAccounts applies sort positions keyed by account ID or name.
+// References that match nothing are ignored.
+func (s Service) ReorderAccounts(order map[string]int) error {
+	accounts, err := s.LoadAccounts()
+	if err != nil {
+		return fmt.Errorf("load accounts: %w", err)
+	}
+
+	for ref, sortOrder := range order {
+		if idx := FindAccountIndex(accounts, ref); idx >= 0 {
+			accounts[idx].SortOrder = sortOrder
+		}
+	}
+
+	if err := s.SaveAccounts(accounts); err != nil {
+		return fmt.Errorf("save accounts: %w", err)
+	}
+	return nil
+}
+
+// assignAccountIDs gives every account a unique ID, replacing blanks and
+// duplicates. It reports whether anything changed.
+func assignAccountIDs(accounts []Account) bool {
+	changed := false
+	seen := make(map[string]struct{}, len(accounts))
+	for i := range accounts {
+		id := strings.ToLower(strings.TrimSpace(accounts[i].ID))
+		if _, dup := seen[id]; id == "" || dup {
+			id = NewAccountID()
+		}
+		if id != accounts[i].ID {
+			accounts[i].ID = id
+			changed = true
+		}
+		seen[id] = struct{}{}
+	}
+	return changed
+}
@@ -0,0 +1,96 @@
+package trustpin
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewAccountIDIsUUIDv4(t *testing.T) {
+	first, second := NewAccountID(), NewAccountID()
+	if !uuidPattern.MatchString(first) {
+		t.Fatalf("expected a version 4 UUID, got %q", first)
+	}
+	if first == second {
+		t.Fatalf("expected unique IDs, got %q twice", first)
+	}
+}
+
+func TestAssignAccountIDsBackfillsBlanksAndDuplicates(t *testing.T) {
+	accounts := []Account{
+		{Name: "A", ID: "fixed"},
+		{Name: "B", ID: "fixed"},
+		{Name: "C"},
+	}
+
+	if !assignAccountIDs(accounts) {
+		t.Fatalf("expected IDs to be assigned")
+	}
+	if accounts[0].ID != "fixed" {
+		t.Fatalf("expected existing ID to be kept, got %q", accounts[0].ID)
+	}
+	if accounts[1].ID == "fixed" || accounts[2].ID == "" || accounts[1].ID == accounts[2].ID {
+		t.Fatalf("expected fresh unique IDs, got %+v", accounts)
+	}
+	if assignAccountIDs(accounts) {
+		t.Fatalf("expected a second pass to be a no-op")
+	}
+}
+
+func TestFindAccountIndexPrefersIDOverName(t *testing.T) {
+	accounts := []Account{
+		{ID: "11111111-1111-4111-8111-111111111111", Name: "22222222-2222-4222-8222-222222222222"},
+		{ID: "22222222-2222-4222-8222-222222222222", Name: "GitHub:work"},
+	}
+
+	if idx := FindAccountIndex(accounts, "22222222-2222-4222-8222-222222222222"); idx != 1 {
+		t.Fatalf("expected ID match at index 1, got %d", idx)
+	}
+	if idx := FindAccountIndex(accounts, "github:WORK"); idx != 1 {
+		t.Fatalf("expected name match at index 1, got %d", idx)
+	}
+	if idx := FindAccountIndex(accounts, "missing"); idx != -1 {
+		t.Fatalf("expected no match, got %d", idx)
+	}
+}
+
+func TestServiceKeepsIDAcrossRenames(t *testing.T) {
+	tmpDir := t.TempDir()
+	service := Service{
+		StorePath: filepath.Join(tmpDir, "accounts.enc"),
+		KeyPath:   filepath.Join(tmpDir, "accounts.key"),
+	}
+
+	summary, err := service.UpsertAccounts([]Account{{Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP"}})
+	if err != nil {
+		t.Fatalf("upsert accounts: %v", err)
+	}
+	id := summary.Changes[0].ID
+	if !uuidPattern.MatchString(id) {
+		t.Fatalf("expected added account to report its ID, got %q", id)
+	}
+
+	if err := service.UpdateAccount(id, Account{Name: "GitHub:personal"}); err != nil {
+		t.Fatalf("update by ID: %v", err)
+	}
+	if err := service.SetAccountArchived("github:personal", true); err != nil {
+		t.Fatalf("archive by name: %v", err)
+	}
+
+	account, err := service.GetAccount(id)
+	if err != nil {
+		t.Fatalf("get account: %v", err)
+	}
+	if account.ID != id || account.Name != "GitHub:personal" || !account.Archived {
+		t.Fatalf("unexpected account after rename: %+v", account)
+	}
+
+	if err := service.ReorderAccounts(map[string]int{id: 4}); err != nil {
+		t.Fatalf("reorder accounts: %v", err)
+	}
+	if removed, err := service.DeleteAccount(id); err != nil || removed != 1 {
+		t.Fatalf("delete by ID: removed=%d err=%v", removed, err)
+	}
+}
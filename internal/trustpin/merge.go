@@ -0,0 +1,271 @@
+package trustpin
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type MergeStrategy string
+
+const (
+	MergeReplace      MergeStrategy = "replace"
+	MergeSkipExisting MergeStrategy = "skip-existing"
+	MergeKeepBoth     MergeStrategy = "keep-both"
+	MergeMetadata     MergeStrategy = "merge-metadata"
+)
+
+const (
+	ChangeAdded    = "added"
+	ChangeReplaced = "replaced"
+	ChangeMerged   = "merged"
+	ChangeKept     = "kept"
+)
+
+// ImportOptions controls how incoming accounts are reconciled with the store.
+// The zero value replaces matching accounts and saves the result.
+type ImportOptions struct {
+	Strategy MergeStrategy `json:"strategy"`
+	DryRun   bool          `json:"dryRun"`
+}
+
+// FieldChange is one field that an import would change on an existing account.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+func ParseMergeStrategy(value string) (MergeStrategy, error) {
+	switch MergeStrategy(strings.ToLower(strings.TrimSpace(value))) {
+	case "", MergeReplace:
+		return MergeReplace, nil
+	case MergeSkipExisting, "skip":
+		return MergeSkipExisting, nil
+	case MergeKeepBoth, "both":
+		return MergeKeepBoth, nil
+	case MergeMetadata, "merge":
+		return MergeMetadata, nil
+	default:
+		return "", invalidf("strategy", "unsupported merge strategy %q (use replace, skip-existing, keep-both, or merge-metadata)", value)
+	}
+}
+
+// ImportAccounts reconciles incoming accounts with the store using the given
+// strategy. With DryRun set the plan is returned without saving anything.
+func (s Service) ImportAccounts(incoming []Account, opts ImportOptions) (UpsertSummary, error) {
+	strategy, err := ParseMergeStrategy(string(opts.Strategy))
+	if err != nil {
+		return UpsertSummary{}, err
+	}
+
+	accounts, err := s.LoadAccounts()
+	if err != nil {
+		return UpsertSummary{}, fmt.Errorf("load accounts: %w", err)
+	}
+
+	accounts, summary := mergeAccounts(accounts, incoming, strategy)
+	summary.DryRun = opts.DryRun
+	if opts.DryRun {
+		return summary, nil
+	}
+
+	sortAccountsByName(accounts)
+	if err := s.SaveAccounts(accounts); err != nil {
+		return UpsertSummary{}, fmt.Errorf("save accounts: %w", err)
+	}
+	return summary, nil
+}
+
+// mergeAccounts plans an import against existing. Incoming accounts match an
+// existing one by ID, then name, then shared secret. existing is not modified.
+func mergeAccounts(existing []Account, incoming []Account, strategy MergeStrategy) ([]Account, UpsertSummary) {
+	merged := slices.Clone(existing)
+	summary := UpsertSummary{
+		Strategy: strategy,
+		Changes:  make([]AccountChange, 0, len(incoming)),
+	}
+
+	for _, candidate := range incoming {
+		candidate = sanitizeAccount(candidate)
+		matchIdx, matchedBy := findImportMatch(merged, candidate)
+
+		if matchIdx == -1 || strategy == MergeKeepBoth {
+			if matchIdx >= 0 || candidate.ID == "" || FindAccountIndex(merged, candidate.ID) >= 0 {
+				candidate.ID = NewAccountID()
+			}
+			if matchIdx >= 0 {
+				name := uniqueAccountName(merged, candidate.Name)
+				candidate.Label += strings.TrimPrefix(name, candidate.Name)
+				candidate.Name = name
+			}
+			merged = append(merged, candidate)
+			summary.Added++
+			summary.Changes = append(summary.Changes, AccountChange{ID: candidate.ID, Name: candidate.Name, Action: ChangeAdded, MatchedBy: matchedBy})
+			continue
+		}
+
+		current := merged[matchIdx]
+		change := AccountChange{ID: current.ID, Name: candidate.Name, Previous: current.Name, MatchedBy: matchedBy}
+		switch strategy {
+		case MergeSkipExisting:
+			change.Name = current.Name
+			change.Action = ChangeKept
+			summary.Kept++
+		case MergeMetadata:
+			next := mergeAccountMetadata(current, candidate)
+			change.Name = next.Name
+			change.Action = ChangeMerged
+			change.Fields = diffAccounts(current, next)
+			merged[matchIdx] = next
+			summary.Merged++
+		default:
+			candidate.ID = current.ID
+			if candidate.RecoveryCodes == nil {
+				candidate.RecoveryCodes = current.RecoveryCodes
+			}
+			if candidate.Color == "" {
+				candidate.Color = current.Color
+			}
+			if candidate.Icon == nil {
+				candidate.Icon = current.Icon
+			}
+			change.Action = ChangeReplaced
+			change.Fields = diffAccounts(current, candidate)
+			merged[matchIdx] = candidate
+			summary.Replaced++
+		}
+		if change.Previous == change.Name {
+			change.Previous = ""
+		}
+		summary.Changes = append(summary.Changes, change)
+	}
+
+	return merged, summary
+}
+
+func findImportMatch(accounts []Account, candidate Account) (int, string) {
+	if candidate.ID != "" {
+		for i, account := range accounts {
+			if account.ID == candidate.ID {
+				return i, "id"
+			}
+		}
+	}
+
+	nameKey := normalizeAccountName(candidate.Name)
+	for i, account := range accounts {
+		if normalizeAccountName(account.Name) == nameKey {
+			return i, "name"
+		}
+	}
+
+	if secretKey := secretIdentity(candidate.Secret, candidate.SecretEncoding); secretKey != "" {
+		for i, account := range accounts {
+			if secretIdentity(account.Secret, account.SecretEncoding) == secretKey {
+				return i, "secret"
+			}
+		}
+	}
+	return -1, ""
+}
+
+// mergeAccountMetadata takes the OTP parameters from incoming and keeps the
+// user's name, notes, favorite, ordering and archive state from current.
+// Tags are unioned.
+func mergeAccountMetadata(current, incoming Account) Account {
+	next := current
+	next.Secret = incoming.Secret
+	next.SecretEncoding = incoming.SecretEncoding
+	next.EncodingGuess = incoming.EncodingGuess
+	next.Interval = incoming.Interval
+	next.Digits = incoming.Digits
+	next.Algorithm = incoming.Algorithm
+	next.Type = incoming.Type
+	next.Counter = incoming.Counter
+	if next.Notes == "" {
+		next.Notes = incoming.Notes
+	}
+	for _, code := range incoming.RecoveryCodes {
+		if findRecoveryCode(next.RecoveryCodes, code.Code) == -1 {
+			next.RecoveryCodes = append(slices.Clone(next.RecoveryCodes), code)
+		}
+	}
+	next.Favorite = current.Favorite || incoming.Favorite
+	for _, tag := range incoming.Tags {
+		if !slices.ContainsFunc(next.Tags, func(existing string) bool { return strings.EqualFold(existing, tag) }) {
+			next.Tags = append(slices.Clone(next.Tags), tag)
+		}
+	}
+	return next
+}
+
+// diffAccounts lists the user-visible fields that differ between two
+// versions of an account. Secrets are compared by key material and shown
+// only as previews; notes can hold recovery details, so only their length is
+// shown.
+func diffAccounts(before, after Account) []FieldChange {
+	changes := make([]FieldChange, 0)
+	add := func(field, from, to string) {
+		if from != to {
+			changes = append(changes, FieldChange{Field: field, Old: from, New: to})
+		}
+	}
+
+	add("name", before.Name, after.Name)
+	if secretIdentity(before.Secret, before.SecretEncoding) != secretIdentity(after.Secret, after.SecretEncoding) {
+		changes = append(changes, FieldChange{Field: "secret", Old: PreviewSecret(before.Secret), New: PreviewSecret(after.Secret)})
+	}
+	add("type", before.Type, after.Type)
+	add("algorithm", before.Algorithm, after.Algorithm)
+	add("digits", strconv.Itoa(before.Digits), strconv.Itoa(after.Digits))
+	add("interval", strconv.FormatInt(before.Interval, 10), strconv.FormatInt(after.Interval, 10))
+	add("counter", strconv.FormatInt(before.Counter, 10), strconv.FormatInt(after.Counter, 10))
+	add("tags", strings.Join(before.Tags, ", "), strings.Join(after.Tags, ", "))
+	add("favorite", strconv.FormatBool(before.Favorite), strconv.FormatBool(after.Favorite))
+	if before.Notes != after.Notes {
+		change := FieldChange{Field: "notes", Old: describeNotes(before.Notes), New: describeNotes(after.Notes)}
+		if change.Old == change.New {
+			change.New = "changed"
+		}
+		changes = append(changes, change)
+	}
+	add("color", before.Color, after.Color)
+	add("archived", strconv.FormatBool(before.Archived), strconv.FormatBool(after.Archived))
+	return changes
+}
+
+// describeNotes stands in for notes in a diff.
+func describeNotes(notes string) string {
+	if notes == "" {
+		return ""
+	}
+	n := utf8.RuneCountInString(notes)
+	return fmt.Sprintf("%d %s", n, pluralize("character", "characters", n))
+}
+
+// uniqueAccountName appends " (2)", " (3)", ... until name is unused.
+func uniqueAccountName(accounts []Account, name string) string {
+	taken := make(map[string]struct{}, len(accounts))
+	for _, account := range accounts {
+		taken[normalizeAccountName(account.Name)] = struct{}{}
+	}
+	if _, ok := taken[normalizeAccountName(name)]; !ok {
+		return name
+	}
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)", name, n)
+		if _, ok := taken[normalizeAccountName(candidate)]; !ok {
+			return candidate
+		}
+	}
+}
+
+func sortAccountsByName(accounts []Account) {
+	sort.Slice(accounts, func(i, j int) bool {
+		return normalizeAccountName(accounts[i].Name) < normalizeAccountName(accounts[j].Name)
+	})
+}
@@ -0,0 +1,125 @@
+package trustpin
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseMergeStrategy(t *testing.T) {
+	cases := map[string]MergeStrategy{
+		"":               MergeReplace,
+		"replace":        MergeReplace,
+		"Skip":           MergeSkipExisting,
+		"keep-both":      MergeKeepBoth,
+		"merge-metadata": MergeMetadata,
+	}
+	for input, want := range cases {
+		got, err := ParseMergeStrategy(input)
+		if err != nil || got != want {
+			t.Fatalf("ParseMergeStrategy(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseMergeStrategy("overwrite"); err == nil {
+		t.Fatalf("expected an error for an unknown strategy")
+	}
+}
+
+func TestMergeAccountsStrategies(t *testing.T) {
+	existing := []Account{sanitizeAccount(Account{
+		ID:       "11111111-1111-4111-8111-111111111111",
+		Name:     "GitHub:work",
+		Secret:   "JBSWY3DPEHPK3PXP",
+		Tags:     []string{"dev"},
+		Notes:    "primary",
+		Favorite: true,
+	})}
+	incoming := []Account{{Name: "GitHub:work", Secret: "KRSXG5CTMVRXEZLU", Tags: []string{"work"}, Digits: 8}}
+
+	t.Run("replace", func(t *testing.T) {
+		merged, summary := mergeAccounts(existing, incoming, MergeReplace)
+		if summary.Replaced != 1 || len(merged) != 1 {
+			t.Fatalf("unexpected summary: %+v", summary)
+		}
+		if merged[0].ID != existing[0].ID || merged[0].Notes != "" {
+			t.Fatalf("expected the incoming account to replace the existing one, got %+v", merged[0])
+		}
+		fields := summary.Changes[0].Fields
+		if !slices.ContainsFunc(fields, func(f FieldChange) bool { return f.Field == "secret" }) {
+			t.Fatalf("expected a secret diff, got %+v", fields)
+		}
+		if !slices.Contains(fields, FieldChange{Field: "notes", Old: "7 characters", New: ""}) {
+			t.Fatalf("expected the notes diff to show only their length, got %+v", fields)
+		}
+		if existing[0].Notes != "primary" {
+			t.Fatalf("expected existing accounts to be left untouched")
+		}
+	})
+
+	t.Run("skip-existing", func(t *testing.T) {
+		merged, summary := mergeAccounts(existing, incoming, MergeSkipExisting)
+		if summary.Kept != 1 || merged[0].Secret != existing[0].Secret {
+			t.Fatalf("expected the existing account to be kept, summary=%+v", summary)
+		}
+	})
+
+	t.Run("keep-both", func(t *testing.T) {
+		merged, summary := mergeAccounts(existing, incoming, MergeKeepBoth)
+		if summary.Added != 1 || len(merged) != 2 {
+			t.Fatalf("expected a second copy, summary=%+v", summary)
+		}
+		dup := merged[1]
+		if dup.Name != "GitHub:work (2)" || dup.Label != "work (2)" || dup.ID == existing[0].ID {
+			t.Fatalf("unexpected copy: %+v", dup)
+		}
+	})
+
+	t.Run("merge-metadata", func(t *testing.T) {
+		merged, summary := mergeAccounts(existing, incoming, MergeMetadata)
+		if summary.Merged != 1 {
+			t.Fatalf("unexpected summary: %+v", summary)
+		}
+		got := merged[0]
+		if got.Secret != "KRSXG5CTMVRXEZLU" || got.Digits != 8 {
+			t.Fatalf("expected OTP parameters from the import, got %+v", got)
+		}
+		if got.Notes != "primary" || !got.Favorite || !slices.Equal(got.Tags, []string{"dev", "work"}) {
+			t.Fatalf("expected user metadata to be kept, got %+v", got)
+		}
+	})
+}
+
+func TestMergeAccountsMatchesBySecret(t *testing.T) {
+	existing := []Account{sanitizeAccount(Account{ID: "11111111-1111-4111-8111-111111111111", Name: "GitHub", Secret: "JBSWY3DPEHPK3PXP"})}
+	incoming := []Account{{Name: "GitHub:octocat", Secret: "jbsw y3dp ehpk 3pxp"}}
+
+	_, summary := mergeAccounts(existing, incoming, MergeReplace)
+	change := summary.Changes[0]
+	if change.MatchedBy != "secret" || change.Previous != "GitHub" || change.Action != ChangeReplaced {
+		t.Fatalf("expected a secret match that renames the account, got %+v", change)
+	}
+}
+
+func TestImportAccountsDryRunDoesNotSave(t *testing.T) {
+	tmpDir := t.TempDir()
+	service := Service{
+		StorePath: filepath.Join(tmpDir, "accounts.enc"),
+		KeyPath:   filepath.Join(tmpDir, "accounts.key"),
+	}
+
+	summary, err := service.ImportAccounts([]Account{{Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP"}}, ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run import: %v", err)
+	}
+	if !summary.DryRun || summary.Added != 1 {
+		t.Fatalf("unexpected dry run summary: %+v", summary)
+	}
+
+	accounts, err := service.LoadAccounts()
+	if err != nil {
+		t.Fatalf("load accounts: %v", err)
+	}
+	if len(accounts) != 0 {
+		t.Fatalf("expected a dry run to leave the store empty, got %+v", accounts)
+	}
+}
@@ -3,7 +3,7 @@ package trustpin
 import (
 	"encoding/base32"
 	"encoding/base64"
-	"errors"
+	"strings"
 
 	"github.com/golang/protobuf/proto"
 )
@@ -33,7 +33,7 @@ func (*migrationPayloadOTPParameters) ProtoMessage()    {}
 
 func parseMigrationData(dataB64 string) ([]Account, error) {
 	if dataB64 == "" {
-		return nil, errors.New("empty migration data")
+		return nil, invalidf("qr", "empty migration data")
 	}
 
 	raw, err := base64.StdEncoding.DecodeString(dataB64)
@@ -42,14 +42,14 @@ func parseMigrationData(dataB64 string) ([]Account, error) {
 		if err != nil {
 			raw, err = base64.URLEncoding.DecodeString(dataB64)
 			if err != nil {
-				return nil, err
+				return nil, invalidInput("qr", "decode migration data", err)
 			}
 		}
 	}
 
 	var payload migrationPayload
 	if err := proto.Unmarshal(raw, &payload); err != nil {
-		return nil, err
+		return nil, invalidInput("qr", "parse migration payload", err)
 	}
 
 	out := make([]Account, 0, len(payload.OtpParameters))
@@ -64,13 +64,13 @@ func parseMigrationData(dataB64 string) ([]Account, error) {
 			issuer = *param.Issuer
 		}
 
-		accountName := name
-		if issuer != "" {
-			if accountName != "" {
-				accountName = issuer + ":" + accountName
-			} else {
-				accountName = issuer
-			}
+		// Exporters commonly repeat the issuer as a label prefix.
+		if issuer != "" && strings.HasPrefix(strings.ToLower(name), strings.ToLower(issuer)+":") {
+			name = strings.TrimSpace(name[len(issuer)+1:])
+		}
+		accountName := AccountName(issuer, name)
+		if name == "" {
+			name = issuer
 		}
 
 		digits := DefaultDigits
@@ -89,6 +89,8 @@ func parseMigrationData(dataB64 string) ([]Account, error) {
 
 		out = append(out, Account{
 			Name:     accountName,
+			Issuer:   issuer,
+			Label:    name,
 			Secret:   base32.StdEncoding.EncodeToString(param.Secret),
 			Interval: DefaultInterval,
 			Digits:   digits,
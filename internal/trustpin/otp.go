@@ -5,12 +5,11 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
-	"encoding/base32"
-	"encoding/base64"
 	"encoding/binary"
 	"fmt"
 	"hash"
 	"math"
+	"net/url"
 	"strings"
 	"time"
 )
@@ -18,30 +17,47 @@ import (
 const steamChars = "23456789BCDFGHJKMNPQRTVWXY"
 
 type AccountSnapshot struct {
-	Account         Account  `json:"-"`
-	Name            string   `json:"name"`
-	DisplayName     string   `json:"displayName"`
-	Issuer          string   `json:"issuer"`
-	Label           string   `json:"label"`
-	OTP             string   `json:"otp"`
-	FormattedOTP    string   `json:"formattedOTP"`
-	TimeRemaining   int64    `json:"timeRemaining"`
-	Interval        int64    `json:"interval"`
-	Digits          int      `json:"digits"`
-	Algorithm       string   `json:"algorithm"`
-	Type            string   `json:"type"`
-	Counter         int64    `json:"counter,omitempty"`
-	Tags            []string `json:"tags,omitempty"`
-	Favorite        bool     `json:"favorite"`
-	Notes           string   `json:"notes,omitempty"`
-	SortOrder       int      `json:"sortOrder"`
-	Archived        bool     `json:"archived"`
-	StatusLabel     string   `json:"statusLabel"`
-	Tone            string   `json:"tone"`
-	ProgressPercent int      `json:"progressPercent"`
-	PolicyLabel     string   `json:"policyLabel"`
-	SecretPreview   string   `json:"secretPreview"`
-	ErrorText       string   `json:"errorText,omitempty"`
+	Account       Account `json:"-"`
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	DisplayName   string  `json:"displayName"`
+	Issuer        string  `json:"issuer"`
+	Label         string  `json:"label"`
+	OTP           string  `json:"otp"`
+	FormattedOTP  string  `json:"formattedOTP"`
+	TimeRemaining int64   `json:"timeRemaining"`
+	// NextOTP is the code of the following time step, which starts at
+	// NextWindowStart. HOTP and undecodable accounts have none.
+	NextOTP          string    `json:"nextOTP,omitempty"`
+	NextFormattedOTP string    `json:"nextFormattedOTP,omitempty"`
+	NextWindowStart  time.Time `json:"nextWindowStart,omitzero"`
+	Interval         int64     `json:"interval"`
+	Digits           int       `json:"digits"`
+	Algorithm        string    `json:"algorithm"`
+	Encoding         string    `json:"encoding"`
+	Type             string    `json:"type"`
+	Counter          int64     `json:"counter,omitempty"`
+	Tags             []string  `json:"tags,omitempty"`
+	Favorite         bool      `json:"favorite"`
+	Notes            string    `json:"notes,omitempty"`
+	RecoveryCodes    int       `json:"recoveryCodes,omitempty"`
+	RecoveryUnused   int       `json:"recoveryUnused,omitempty"`
+	SortOrder        int       `json:"sortOrder"`
+	Archived         bool      `json:"archived"`
+	Color            string    `json:"color,omitempty"`
+	BrandColor       string    `json:"brandColor,omitempty"`
+	CustomIcon       bool      `json:"customIcon,omitempty"`
+	CreatedAt        time.Time `json:"createdAt,omitzero"`
+	UpdatedAt        time.Time `json:"updatedAt,omitzero"`
+	LastUsedAt       time.Time `json:"lastUsedAt,omitzero"`
+	StatusLabel      string    `json:"statusLabel"`
+	Tone             string    `json:"tone"`
+	ProgressPercent  int       `json:"progressPercent"`
+	PolicyLabel      string    `json:"policyLabel"`
+	SecretPreview    string    `json:"secretPreview"`
+	ErrorText        string    `json:"errorText,omitempty"`
+	// Score is set on search results; higher is a better match.
+	Score int `json:"score,omitempty"`
 }
 
 func hashFunc(algorithm string) func() hash.Hash {
@@ -55,13 +71,7 @@ func hashFunc(algorithm string) func() hash.Hash {
 	}
 }
 
-func generateOTPCode(secret string, counter uint64, digits int, algorithm string) (string, error) {
-	secret = normalizeSecret(secret)
-
-	secretBytes, err := decodeSecret(secret)
-	if err != nil {
-		return "", err
-	}
+func generateOTPCode(secretBytes []byte, counter uint64, digits int, algorithm string) string {
 	if digits <= 0 {
 		digits = DefaultDigits
 	}
@@ -77,7 +87,7 @@ func generateOTPCode(secret string, counter uint64, digits int, algorithm string
 	truncatedHash := binary.BigEndian.Uint32(hashResult[offset:offset+4]) & 0x7FFFFFFF
 
 	otpCode := truncatedHash % uint32(math.Pow10(digits))
-	return fmt.Sprintf("%0*d", digits, otpCode), nil
+	return fmt.Sprintf("%0*d", digits, otpCode)
 }
 
 func GenerateTOTP(secret string, interval int64, digits int) (otp string, timeRemaining int64, err error) {
@@ -85,39 +95,79 @@ func GenerateTOTP(secret string, interval int64, digits int) (otp string, timeRe
 }
 
 func GenerateTOTPWithAlgorithm(secret string, interval int64, digits int, algorithm string) (otp string, timeRemaining int64, err error) {
-	if interval <= 0 {
-		interval = DefaultInterval
+	key, err := DecodeSecret(secret, "")
+	if err != nil {
+		return "", 0, err
+	}
+	otp, timeRemaining = generateTOTP(key, interval, digits, algorithm)
+	return otp, timeRemaining, nil
+}
+
+func GenerateHOTP(secret string, counter int64, digits int, algorithm string) (string, error) {
+	key, err := DecodeSecret(secret, "")
+	if err != nil {
+		return "", err
 	}
+	return generateHOTP(key, counter, digits, algorithm), nil
+}
 
-	counter := uint64(getCurrentTime() / interval)
-	otp, err = generateOTPCode(secret, counter, digits, algorithm)
+func GenerateSteamCode(secret string, interval int64) (string, int64, error) {
+	key, err := DecodeSecret(secret, "")
 	if err != nil {
 		return "", 0, err
 	}
+	code, remaining := generateSteamCode(key, interval)
+	return code, remaining, nil
+}
 
-	timeRemaining = interval - (getCurrentTime() % interval)
-	return otp, timeRemaining, nil
+// GenerateAccountCode produces the current code for an account using its
+// stored secret encoding. HOTP accounts report -1 seconds remaining.
+func GenerateAccountCode(account Account) (string, int64, error) {
+	account = sanitizeAccount(account)
+	key, err := DecodeSecret(account.Secret, account.SecretEncoding)
+	if err != nil {
+		return "", 0, err
+	}
+
+	switch account.Type {
+	case TypeSteam:
+		code, remaining := generateSteamCode(key, account.Interval)
+		return code, remaining, nil
+	case TypeHOTP:
+		return generateHOTP(key, account.Counter, account.Digits, account.Algorithm), -1, nil
+	default:
+		code, remaining := generateTOTP(key, account.Interval, account.Digits, account.Algorithm)
+		return code, remaining, nil
+	}
 }
 
-func GenerateHOTP(secret string, counter int64, digits int, algorithm string) (string, error) {
+func generateTOTP(key []byte, interval int64, digits int, algorithm string) (string, int64) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	now := getCurrentTime()
+	otp := generateOTPCode(key, uint64(now/interval), digits, algorithm)
+	return otp, interval - (now % interval)
+}
+
+func generateHOTP(key []byte, counter int64, digits int, algorithm string) string {
 	if counter < 0 {
 		counter = 0
 	}
-	return generateOTPCode(secret, uint64(counter), digits, algorithm)
+	return generateOTPCode(key, uint64(counter), digits, algorithm)
 }
 
-func GenerateSteamCode(secret string, interval int64) (string, int64, error) {
+func generateSteamCode(secretBytes []byte, interval int64) (string, int64) {
 	if interval <= 0 {
 		interval = DefaultInterval
 	}
 
-	secret = normalizeSecret(secret)
-	secretBytes, err := decodeSecret(secret)
-	if err != nil {
-		return "", 0, err
-	}
+	now := getCurrentTime()
+	return steamCode(secretBytes, uint64(now/interval)), interval - (now % interval)
+}
 
-	counter := uint64(getCurrentTime() / interval)
+func steamCode(secretBytes []byte, counter uint64) string {
 	var counterBytes [8]byte
 	binary.BigEndian.PutUint64(counterBytes[:], counter)
 
@@ -133,18 +183,55 @@ func GenerateSteamCode(secret string, interval int64) (string, int64, error) {
 		code[i] = steamChars[fullCode%uint32(len(steamChars))]
 		fullCode /= uint32(len(steamChars))
 	}
+	return string(code)
+}
+
+// CodeWindow is the code a time-based account shows during one time step.
+type CodeWindow struct {
+	Code  string
+	Start time.Time
+	End   time.Time
+}
+
+// AccountCodeWindows returns the codes for the time step containing at,
+// plus before steps ahead of it and after steps following it, oldest first.
+// HOTP codes follow a counter rather than the clock, so they have none.
+func AccountCodeWindows(account Account, at time.Time, before, after int) ([]CodeWindow, error) {
+	account = sanitizeAccount(account)
+	if account.Type == TypeHOTP {
+		return nil, invalidf("type", "HOTP codes do not change with time")
+	}
+	key, err := DecodeSecret(account.Secret, account.SecretEncoding)
+	if err != nil {
+		return nil, err
+	}
 
-	timeRemaining := interval - (getCurrentTime() % interval)
-	return string(code), timeRemaining, nil
+	interval := account.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	current := at.Unix() / interval
+	windows := make([]CodeWindow, 0, before+1+after)
+	for step := max(current-int64(before), 0); step <= current+int64(after); step++ {
+		code := generateOTPCode(key, uint64(step), account.Digits, account.Algorithm)
+		if account.Type == TypeSteam {
+			code = steamCode(key, uint64(step))
+		}
+		windows = append(windows, CodeWindow{
+			Code:  code,
+			Start: time.Unix(step*interval, 0),
+			End:   time.Unix((step+1)*interval, 0),
+		})
+	}
+	return windows, nil
 }
 
 func BuildAccountSnapshot(account Account) AccountSnapshot {
 	account = sanitizeAccount(account)
 
-	issuer, label, hasIssuer := SplitAccountName(account.Name)
+	issuer, label, hasIssuer := AccountIssuer(account)
 	if !hasIssuer {
 		issuer = "Standalone"
-		label = account.Name
 	}
 
 	tags := account.Tags
@@ -162,6 +249,7 @@ func BuildAccountSnapshot(account Account) AccountSnapshot {
 		}
 		return AccountSnapshot{
 			Account:         account,
+			ID:              account.ID,
 			Name:            account.Name,
 			DisplayName:     label,
 			Issuer:          issuer,
@@ -172,13 +260,22 @@ func BuildAccountSnapshot(account Account) AccountSnapshot {
 			Interval:        account.Interval,
 			Digits:          account.Digits,
 			Algorithm:       account.Algorithm,
+			Encoding:        account.SecretEncoding,
 			Type:            account.Type,
 			Counter:         account.Counter,
 			Tags:            tags,
 			Favorite:        account.Favorite,
 			Notes:           account.Notes,
+			RecoveryCodes:   len(account.RecoveryCodes),
+			RecoveryUnused:  UnusedRecoveryCodes(account),
 			SortOrder:       account.SortOrder,
 			Archived:        true,
+			CreatedAt:       account.CreatedAt,
+			UpdatedAt:       account.UpdatedAt,
+			LastUsedAt:      account.LastUsedAt,
+			Color:           account.Color,
+			BrandColor:      AccountBrand(account).Color,
+			CustomIcon:      account.Icon != nil,
 			StatusLabel:     "ARCHIVED",
 			Tone:            "accent",
 			ProgressPercent: 0,
@@ -187,18 +284,22 @@ func BuildAccountSnapshot(account Account) AccountSnapshot {
 		}
 	}
 
-	var otp string
-	var remaining int64
-	var err error
-
-	switch account.Type {
-	case TypeSteam:
-		otp, remaining, err = GenerateSteamCode(account.Secret, account.Interval)
-	case TypeHOTP:
-		otp, err = GenerateHOTP(account.Secret, account.Counter, account.Digits, account.Algorithm)
+	otp, remaining, err := GenerateAccountCode(account)
+	if err == nil && account.Type == TypeHOTP {
 		remaining = -1 // HOTP doesn't have a countdown
-	default:
-		otp, remaining, err = GenerateTOTPWithAlgorithm(account.Secret, account.Interval, account.Digits, account.Algorithm)
+	}
+
+	// The next window starts where this one's countdown ends, so the
+	// preview always follows the code above even across a step boundary.
+	var next CodeWindow
+	if err == nil && account.Type != TypeHOTP {
+		if windows, windowErr := AccountCodeWindows(account, time.Unix(getCurrentTime()+remaining, 0), 0, 0); windowErr == nil {
+			next = windows[0]
+		}
+	}
+	nextFormatted := FormatOTP(next.Code)
+	if account.Type == TypeSteam || next.Code == "" {
+		nextFormatted = next.Code
 	}
 
 	progress := computeProgressPercent(remaining, account.Interval)
@@ -210,7 +311,7 @@ func BuildAccountSnapshot(account Account) AccountSnapshot {
 		formattedOTP = otp // Steam codes are already formatted as letters
 	}
 	if err != nil {
-		errorText = "Secret is not valid base32/base64. Re-import or edit this entry."
+		errorText = "Secret is not valid " + describeEncoding(account.SecretEncoding) + ". Re-import or edit this entry."
 		formattedOTP = "-- --"
 		progress = 0
 	}
@@ -226,53 +327,69 @@ func BuildAccountSnapshot(account Account) AccountSnapshot {
 	}
 
 	return AccountSnapshot{
-		Account:         account,
-		Name:            account.Name,
-		DisplayName:     label,
-		Issuer:          issuer,
-		Label:           label,
-		OTP:             otp,
-		FormattedOTP:    formattedOTP,
-		TimeRemaining:   remaining,
-		Interval:        account.Interval,
-		Digits:          account.Digits,
-		Algorithm:       account.Algorithm,
-		Type:            account.Type,
-		Counter:         account.Counter,
-		Tags:            tags,
-		Favorite:        account.Favorite,
-		Notes:           account.Notes,
-		SortOrder:       account.SortOrder,
-		Archived:        account.Archived,
-		StatusLabel:     status,
-		Tone:            tone,
-		ProgressPercent: progress,
-		PolicyLabel:     policyLabel,
-		SecretPreview:   PreviewSecret(account.Secret),
-		ErrorText:       errorText,
+		Account:          account,
+		ID:               account.ID,
+		Name:             account.Name,
+		DisplayName:      label,
+		Issuer:           issuer,
+		Label:            label,
+		OTP:              otp,
+		FormattedOTP:     formattedOTP,
+		TimeRemaining:    remaining,
+		NextOTP:          next.Code,
+		NextFormattedOTP: nextFormatted,
+		NextWindowStart:  next.Start,
+		Interval:         account.Interval,
+		Digits:           account.Digits,
+		Algorithm:        account.Algorithm,
+		Encoding:         account.SecretEncoding,
+		Type:             account.Type,
+		Counter:          account.Counter,
+		Tags:             tags,
+		Favorite:         account.Favorite,
+		Notes:            account.Notes,
+		RecoveryCodes:    len(account.RecoveryCodes),
+		RecoveryUnused:   UnusedRecoveryCodes(account),
+		SortOrder:        account.SortOrder,
+		Archived:         account.Archived,
+		CreatedAt:        account.CreatedAt,
+		UpdatedAt:        account.UpdatedAt,
+		LastUsedAt:       account.LastUsedAt,
+		Color:            account.Color,
+		BrandColor:       AccountBrand(account).Color,
+		CustomIcon:       account.Icon != nil,
+		StatusLabel:      status,
+		Tone:             tone,
+		ProgressPercent:  progress,
+		PolicyLabel:      policyLabel,
+		SecretPreview:    PreviewSecret(account.Secret),
+		ErrorText:        errorText,
 	}
 }
 
 func BuildOTPAuthURI(account Account) string {
 	account = sanitizeAccount(account)
-	issuer, label, hasIssuer := SplitAccountName(account.Name)
-	if !hasIssuer {
-		label = account.Name
-	}
+	issuer, label, hasIssuer := AccountIssuer(account)
 
 	otpType := "totp"
 	if account.Type == TypeHOTP {
 		otpType = "hotp"
 	}
 
-	path := label
+	path := escapeLabelPart(label)
 	if hasIssuer {
-		path = issuer + ":" + label
+		path = escapeLabelPart(issuer) + ":" + path
+	}
+
+	// otpauth URIs always carry unpadded base32, whatever the stored encoding.
+	secret := normalizeSecret(account.Secret)
+	if key, err := DecodeSecret(account.Secret, account.SecretEncoding); err == nil {
+		secret = base32NoPadding.EncodeToString(key)
 	}
 
-	uri := fmt.Sprintf("otpauth://%s/%s?secret=%s", otpType, path, normalizeSecret(account.Secret))
+	uri := fmt.Sprintf("otpauth://%s/%s?secret=%s", otpType, path, url.QueryEscape(secret))
 	if hasIssuer {
-		uri += "&issuer=" + issuer
+		uri += "&issuer=" + url.QueryEscape(issuer)
 	}
 	if account.Algorithm != AlgorithmSHA1 {
 		uri += "&algorithm=" + account.Algorithm
@@ -286,6 +403,39 @@ func BuildOTPAuthURI(account Account) string {
 	return uri
 }
 
+// escapeLabelPart escapes an issuer or label for the otpauth path. Colons are
+// escaped too so only the issuer separator stays literal.
+func escapeLabelPart(value string) string {
+	return strings.ReplaceAll(url.PathEscape(value), ":", "%3A")
+}
+
+// AccountName composes the display name for an issuer and label.
+func AccountName(issuer, label string) string {
+	issuer, label = strings.TrimSpace(issuer), strings.TrimSpace(label)
+	switch {
+	case issuer == "":
+		return label
+	case label == "":
+		return issuer
+	default:
+		return issuer + ":" + label
+	}
+}
+
+// AccountIssuer returns an account's issuer and label, falling back to the
+// Issuer:Label name convention for accounts that predate the explicit fields.
+func AccountIssuer(account Account) (issuer, label string, hasIssuer bool) {
+	if account.Issuer == "" && account.Label == "" {
+		return SplitAccountName(account.Name)
+	}
+	label = account.Label
+	if label == "" {
+		label = account.Name
+	}
+	return account.Issuer, label, account.Issuer != ""
+}
+
+// SplitAccountName splits a name on its first colon into issuer and label.
 func SplitAccountName(name string) (issuer, label string, hasIssuer bool) {
 	name = strings.TrimSpace(name)
 	if name == "" {
@@ -307,7 +457,7 @@ func SplitAccountName(name string) (issuer, label string, hasIssuer bool) {
 }
 
 func PreviewSecret(secret string) string {
-	secret = normalizeSecret(secret)
+	secret = strings.Join(strings.Fields(secret), "")
 	if len(secret) <= 8 {
 		return secret
 	}
@@ -341,25 +491,15 @@ func getCurrentTime() int64 {
 	return time.Now().Unix()
 }
 
-func decodeSecret(secret string) ([]byte, error) {
-	if isBase32(secret) {
-		return base32.StdEncoding.DecodeString(secret)
-	}
-	if isBase64(secret) {
-		return base64.StdEncoding.DecodeString(secret)
+func describeEncoding(encoding string) string {
+	switch encoding {
+	case "":
+		return "base32, base64, or hex"
+	case EncodingBase32NoPad:
+		return "unpadded base32"
+	default:
+		return encoding
 	}
-
-	return nil, fmt.Errorf("secret is not valid base32 or base64")
-}
-
-func isBase32(secret string) bool {
-	_, err := base32.StdEncoding.DecodeString(secret)
-	return err == nil
-}
-
-func isBase64(secret string) bool {
-	_, err := base64.StdEncoding.DecodeString(secret)
-	return err == nil
 }
 
 func classifyAccountState(account Account, remaining int64, err error) (string, string) {
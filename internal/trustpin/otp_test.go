@@ -0,0 +1,52 @@
+package trustpin
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAccountCodeWindows(t *testing.T) {
+	// RFC 6238 test secret "12345678901234567890"; T=59 falls in step 1.
+	account := Account{Name: "RFC", Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Digits: 8, Interval: 30}
+	windows, err := AccountCodeWindows(account, time.Unix(59, 0), 1, 1)
+	if err != nil {
+		t.Fatalf("code windows: %v", err)
+	}
+	if len(windows) != 3 {
+		t.Fatalf("expected three windows, got %+v", windows)
+	}
+	if windows[1].Code != "94287082" || windows[1].Start.Unix() != 30 || windows[1].End.Unix() != 60 {
+		t.Fatalf("expected the RFC 6238 code for step 1, got %+v", windows[1])
+	}
+	if windows[0].Start.Unix() != 0 || windows[2].Start.Unix() != 60 || windows[0].Code == windows[2].Code {
+		t.Fatalf("expected the neighbouring steps, got %+v", windows)
+	}
+
+	if windows, _ := AccountCodeWindows(account, time.Unix(10, 0), 1, 0); len(windows) != 1 {
+		t.Fatalf("expected no window before the epoch, got %+v", windows)
+	}
+	if _, err := AccountCodeWindows(Account{Name: "HOTP", Secret: account.Secret, Type: TypeHOTP}, time.Unix(59, 0), 0, 1); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected HOTP accounts to have no windows, got %v", err)
+	}
+}
+
+func TestSnapshotPreviewsNextCode(t *testing.T) {
+	account := Account{Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP"}
+	snapshot := BuildAccountSnapshot(account)
+	windows, err := AccountCodeWindows(account, snapshot.NextWindowStart, 1, 0)
+	if err != nil || len(windows) != 2 {
+		t.Fatalf("code windows: %+v, %v", windows, err)
+	}
+	if snapshot.NextOTP != windows[1].Code || snapshot.NextFormattedOTP != FormatOTP(windows[1].Code) {
+		t.Fatalf("expected the next window's code, got %q", snapshot.NextOTP)
+	}
+	if wait := time.Until(snapshot.NextWindowStart); wait <= -time.Second || wait > 30*time.Second {
+		t.Fatalf("expected the next window within one interval, got %s", wait)
+	}
+
+	hotp := BuildAccountSnapshot(Account{Name: "Bank", Secret: "JBSWY3DPEHPK3PXP", Type: TypeHOTP})
+	if hotp.NextOTP != "" || !hotp.NextWindowStart.IsZero() {
+		t.Fatalf("expected no preview for HOTP, got %+v", hotp)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package trustpin
+
+import "io/fs"
+
+// fileOwner reports no owner: outside Unix, access is governed by ACLs that
+// file modes do not describe.
+func fileOwner(fs.FileInfo) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,126 @@
+package trustpin
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// PermissionFix records a store file or directory that LoadAccounts made
+// private because other users could open it. Foreign marks a directory that
+// belongs to another user, Owner; TrustPIN leaves those as they are.
+type PermissionFix struct {
+	Path    string      `json:"path"`
+	From    fs.FileMode `json:"from"`
+	To      fs.FileMode `json:"to"`
+	Foreign bool        `json:"foreign,omitempty"`
+	Owner   int         `json:"owner,omitempty"`
+}
+
+// specialModeBits are kept as they are when a mode is tightened.
+const specialModeBits = os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+type privatePath struct {
+	path string
+	want fs.FileMode
+	dir  bool
+}
+
+// privatePaths lists what must stay private to the current user: the store,
+// the key, and the directories holding them. Only the TrustPIN app directory
+// is made private; any other directory was chosen by the user, may be shared,
+// and is created private when TrustPIN makes it, so its mode is left alone.
+func (s Service) privatePaths() []privatePath {
+	paths := []privatePath{{path: s.storePath(), want: 0o600}, {path: s.keyPath(), want: 0o600}}
+	seen := make(map[string]bool, 2)
+	for _, path := range []string{s.storePath(), s.keyPath()} {
+		dir := filepath.Dir(path)
+		if dir == "." || dir == "" || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		want := fs.ModePerm
+		if isAppDir(dir) {
+			want = 0o700
+		}
+		paths = append(paths, privatePath{path: dir, want: want, dir: true})
+	}
+	return paths
+}
+
+// isAppDir reports whether dir is the directory TrustPIN keeps its store in
+// by default.
+func isAppDir(dir string) bool {
+	return filepath.Clean(dir) == filepath.Clean(defaultAppDir())
+}
+
+// securePaths refuses a store or key owned by another user and strips group
+// and world bits from the ones this user owns and from the app directory. A
+// directory owned by another user is only reported. Paths that do not exist
+// yet are skipped; they are created private.
+func (s Service) securePaths() ([]PermissionFix, error) {
+	if runtime.GOOS == "windows" {
+		return nil, nil
+	}
+
+	fixes := make([]PermissionFix, 0)
+	report := func(fix PermissionFix) {
+		fixes = append(fixes, fix)
+		if s.OnPermissionFix != nil {
+			s.OnPermissionFix(fix)
+		}
+	}
+	for _, target := range s.privatePaths() {
+		info, err := os.Stat(target.path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fixes, deniedAsLocked(err)
+		}
+		mode := info.Mode().Perm()
+		if owner, ok := fileOwner(info); ok && owner != os.Geteuid() {
+			if target.dir {
+				report(PermissionFix{Path: target.path, From: mode, To: mode, Foreign: true, Owner: owner})
+				continue
+			}
+			return fixes, storeError(ErrInsecureStore, fmt.Sprintf("%s is owned by uid %d, not by the current user (uid %d)", target.path, owner, os.Geteuid()), nil)
+		}
+
+		if mode&^target.want == 0 {
+			continue
+		}
+		fix := PermissionFix{Path: target.path, From: mode, To: mode & target.want}
+		if err := os.Chmod(target.path, fix.To|info.Mode()&specialModeBits); err != nil {
+			return fixes, storeError(ErrInsecureStore, fmt.Sprintf("%s is %04o and could not be made private", target.path, mode), err)
+		}
+		report(fix)
+	}
+	return fixes, nil
+}
+
+// permissionFindings turns the fixes made while loading into health items, so
+// the report shows that something had loosened the modes.
+func permissionFindings(fixes []PermissionFix) []HealthItem {
+	items := make([]HealthItem, 0, len(fixes))
+	for _, fix := range fixes {
+		if fix.Foreign {
+			items = append(items, HealthItem{
+				Rule:   RuleFilePermissions,
+				Level:  HealthLevelWarning,
+				Title:  "Directory owned by another user",
+				Detail: fmt.Sprintf("%s belongs to uid %d; whoever owns it can replace the store or the key. TrustPIN left it unchanged.", fix.Path, fix.Owner),
+			})
+			continue
+		}
+		items = append(items, HealthItem{
+			Rule:   RuleFilePermissions,
+			Level:  HealthLevelWarning,
+			Title:  "Permissions tightened",
+			Detail: fmt.Sprintf("%s was %04o, readable by other users; TrustPIN changed it to %04o.", fix.Path, fix.From, fix.To),
+		})
+	}
+	return items
+}
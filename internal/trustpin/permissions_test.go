@@ -0,0 +1,145 @@
+package trustpin
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestLoadAccountsTightensOpenPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on Windows")
+	}
+	service := newAppDirService(t)
+	if err := service.SaveAccounts([]Account{{Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP", Interval: 30, Digits: 6}}); err != nil {
+		t.Fatalf("save accounts: %v", err)
+	}
+	dir := filepath.Dir(service.StorePath)
+	for path, mode := range map[string]fs.FileMode{service.KeyPath: 0o644, dir: 0o755 | fs.ModeSticky} {
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatalf("chmod %s: %v", path, err)
+		}
+	}
+
+	var warned []PermissionFix
+	service.OnPermissionFix = func(fix PermissionFix) { warned = append(warned, fix) }
+	report, err := service.HealthReportWithPolicy(DefaultHealthPolicy())
+	if err != nil {
+		t.Fatalf("health report: %v", err)
+	}
+	if len(warned) != 2 {
+		t.Fatalf("expected the key and directory to be tightened, got %+v", warned)
+	}
+
+	found := 0
+	for _, item := range report.Items {
+		if item.Rule == RuleFilePermissions {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Fatalf("expected two %s findings, got %+v", RuleFilePermissions, report.Items)
+	}
+
+	for path, want := range map[string]fs.FileMode{service.KeyPath: 0o600, dir: 0o700, service.StorePath: 0o600} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat %s: %v", path, err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Fatalf("%s: expected %04o, got %04o", path, want, got)
+		}
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode()&fs.ModeSticky == 0 {
+		t.Fatalf("expected the sticky bit to survive, got %v %v", info, err)
+	}
+
+	warned = nil
+	if _, err := service.LoadAccounts(); err != nil || len(warned) != 0 {
+		t.Fatalf("expected a clean second load, got %v %+v", err, warned)
+	}
+}
+
+func TestLoadAccountsRefusesForeignOwner(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() != 0 {
+		t.Skip("changing file ownership needs root")
+	}
+	service := newDoctorService(t)
+	if err := service.SaveAccounts([]Account{}); err != nil {
+		t.Fatalf("save accounts: %v", err)
+	}
+	if err := os.Chown(service.KeyPath, 65534, 65534); err != nil {
+		t.Fatalf("chown key: %v", err)
+	}
+
+	_, err := service.LoadAccounts()
+	if !errors.Is(err, ErrInsecureStore) || !errors.Is(err, ErrStoreLocked) {
+		t.Fatalf("expected an insecure store error, got %v", err)
+	}
+	if statuses := diagnosticStatuses(service.Diagnose()); statuses[CheckKeyPermissions] != DiagnosticError {
+		t.Fatalf("expected doctor to flag the key owner, got %v", statuses)
+	}
+}
+
+func TestLoadAccountsLeavesChosenDirectoryAlone(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on Windows")
+	}
+	service := newDoctorService(t)
+	if err := service.SaveAccounts([]Account{}); err != nil {
+		t.Fatalf("save accounts: %v", err)
+	}
+	dir := filepath.Dir(service.StorePath)
+	if err := os.Chmod(dir, 0o755|fs.ModeSetgid); err != nil {
+		t.Fatalf("chmod dir: %v", err)
+	}
+
+	var warned []PermissionFix
+	service.OnPermissionFix = func(fix PermissionFix) { warned = append(warned, fix) }
+	if _, err := service.LoadAccounts(); err != nil || len(warned) != 0 {
+		t.Fatalf("expected a user-chosen directory to be left alone, got %v %+v", err, warned)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0o755 || info.Mode()&fs.ModeSetgid == 0 {
+		t.Fatalf("expected the directory mode to be unchanged, got %v %v", info, err)
+	}
+}
+
+func TestLoadAccountsWarnsAboutForeignDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() != 0 {
+		t.Skip("changing file ownership needs root")
+	}
+	service := newDoctorService(t)
+	if err := service.SaveAccounts([]Account{}); err != nil {
+		t.Fatalf("save accounts: %v", err)
+	}
+	dir := filepath.Dir(service.StorePath)
+	if err := os.Chown(dir, 65534, 65534); err != nil {
+		t.Fatalf("chown dir: %v", err)
+	}
+
+	var warned []PermissionFix
+	service.OnPermissionFix = func(fix PermissionFix) { warned = append(warned, fix) }
+	if _, err := service.LoadAccounts(); err != nil {
+		t.Fatalf("expected a foreign directory to only warn, got %v", err)
+	}
+	if len(warned) != 1 || !warned[0].Foreign || warned[0].Owner != 65534 || warned[0].Path != dir {
+		t.Fatalf("expected a warning about the directory owner, got %+v", warned)
+	}
+	if statuses := diagnosticStatuses(service.Diagnose()); statuses[CheckDirPermissions] != DiagnosticWarning {
+		t.Fatalf("expected doctor to warn about the directory owner, got %v", statuses)
+	}
+}
+
+// newAppDirService keeps its store in the default app directory, under a
+// temporary home.
+func newAppDirService(t *testing.T) Service {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	storePath := filepath.Join(defaultAppDir(), DefaultStoreFileName)
+	return Service{StorePath: storePath, KeyPath: derivedKeyPath(storePath)}
+}
@@ -1,14 +1,12 @@
 package trustpin
 
 import (
-	"errors"
 	"image"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
 	"net/url"
 	"os"
-	"path"
 	"strconv"
 	"strings"
 
@@ -24,81 +22,121 @@ func ReadQRFromFile(fp string) (string, error) {
 
 	img, _, err := image.Decode(f)
 	if err != nil {
-		return "", err
+		return "", invalidInput("qr", "decode image", err)
 	}
 
 	symbols, err := goqr.Recognize(img)
 	if err != nil {
-		return "", err
+		return "", invalidInput("qr", "recognize QR code", err)
 	}
 
 	if len(symbols) == 0 {
-		return "", errors.New("no QR code found in image")
+		return "", invalidf("qr", "no QR code found in image")
 	}
 
 	return string(symbols[0].Payload), nil
 }
 
+// ParseOtpauthURI parses an otpauth URI into its individual fields. The
+// returned account name uses the Issuer:Label form.
 func ParseOtpauthURI(uri string) (account string, secret string, interval int, digits int, algorithm string, otpType string, counter int64, err error) {
-	u, err := url.Parse(uri)
+	parsed, err := ParseOtpauthAccount(uri)
 	if err != nil {
 		return "", "", 0, 0, "", "", 0, err
 	}
+	return parsed.Name, parsed.Secret, int(parsed.Interval), parsed.Digits, parsed.Algorithm, parsed.Type, parsed.Counter, nil
+}
+
+// ParseOtpauthAccount parses an otpauth URI into an Account. The issuer query
+// parameter takes precedence over the label prefix, and labels are unescaped
+// without any path cleaning so slashes and colons in them survive.
+func ParseOtpauthAccount(uri string) (Account, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Account{}, invalidInput("uri", "parse otpauth uri", err)
+	}
 	if u.Scheme != "otpauth" {
-		return "", "", 0, 0, "", "", 0, errors.New("uri is not otpauth scheme")
+		return Account{}, invalidf("uri", "uri is not otpauth scheme")
 	}
 
-	otpType = strings.ToLower(u.Host)
+	otpType := strings.ToLower(u.Host)
 	if otpType != "totp" && otpType != "hotp" {
-		return "", "", 0, 0, "", "", 0, errors.New("only totp and hotp types are supported")
+		return Account{}, invalidf("type", "only totp and hotp types are supported")
 	}
 
-	label := strings.TrimPrefix(u.Path, "/")
+	rawLabel := strings.TrimPrefix(u.EscapedPath(), "/")
 	q := u.Query()
 
-	secret = q.Get("secret")
+	secret := q.Get("secret")
 	if secret == "" {
-		return "", "", 0, 0, "", "", 0, errors.New("secret missing in otpauth uri")
+		return Account{}, invalidSecretf("secret missing in otpauth uri")
 	}
 
-	interval = DefaultInterval
-	digits = DefaultDigits
-	algorithm = AlgorithmSHA1
+	account := Account{
+		Secret:    secret,
+		Interval:  DefaultInterval,
+		Digits:    DefaultDigits,
+		Algorithm: AlgorithmSHA1,
+		Type:      otpType,
+	}
 
 	if p := q.Get("period"); p != "" {
 		if v, e := strconv.Atoi(p); e == nil && v > 0 {
-			interval = v
+			account.Interval = int64(v)
 		}
 	}
 
 	if d := q.Get("digits"); d != "" {
 		if v, e := strconv.Atoi(d); e == nil && v > 0 {
-			digits = v
+			account.Digits = v
 		}
 	}
 
 	if a := q.Get("algorithm"); a != "" {
-		algorithm = NormalizeAlgorithm(a)
+		account.Algorithm = NormalizeAlgorithm(a)
 	}
 
 	if c := q.Get("counter"); c != "" {
 		if v, e := strconv.ParseInt(c, 10, 64); e == nil {
-			counter = v
+			account.Counter = v
 		}
 	}
 
-	account = label
-	if account == "" {
-		if issuer := q.Get("issuer"); issuer != "" {
-			account = issuer
+	issuer, label, err := splitOtpauthLabel(rawLabel)
+	if err != nil {
+		return Account{}, invalidInput("uri", "decode otpauth label", err)
+	}
+	if param := strings.TrimSpace(q.Get("issuer")); param != "" {
+		if issuer != "" && !strings.EqualFold(issuer, param) {
+			label = issuer + ":" + label
 		}
+		issuer = param
 	}
-	if strings.Contains(label, ":") {
-		account = label
+	account.Name = AccountName(issuer, label)
+	if label == "" {
+		label = issuer
+	}
+	account.Issuer = issuer
+	account.Label = label
+	return account, nil
+}
+
+// splitOtpauthLabel splits an escaped otpauth label on its first literal
+// colon and unescapes both halves, so an escaped %3A stays inside the label.
+func splitOtpauthLabel(raw string) (issuer, label string, err error) {
+	prefix, rest, found := strings.Cut(raw, ":")
+	if !found {
+		label, err = url.PathUnescape(raw)
+		return "", strings.TrimSpace(label), err
 	}
-	account = path.Base(account)
 
-	return account, secret, interval, digits, algorithm, otpType, counter, nil
+	if issuer, err = url.PathUnescape(prefix); err != nil {
+		return "", "", err
+	}
+	if label, err = url.PathUnescape(rest); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(issuer), strings.TrimSpace(label), nil
 }
 
 func ParseQRPayload(payload string) ([]Account, error) {
@@ -111,12 +149,12 @@ func ParseQRPayload(payload string) ([]Account, error) {
 
 		u, err := url.Parse(trimmed)
 		if err != nil {
-			return nil, err
+			return nil, invalidInput("qr", "parse migration uri", err)
 		}
 
 		data := u.Query().Get("data")
 		if data == "" {
-			return nil, errors.New("migration payload missing data parameter")
+			return nil, invalidf("qr", "migration payload missing data parameter")
 		}
 
 		return parseMigrationData(data)
@@ -128,21 +166,13 @@ func ParseQRPayload(payload string) ([]Account, error) {
 		}
 	}
 	if !strings.HasPrefix(trimmed, "otpauth://") {
-		return nil, errors.New("no otpauth URI found in payload")
+		return nil, invalidf("qr", "no otpauth URI found in payload")
 	}
 
-	account, secret, interval, digits, algorithm, otpType, counter, err := ParseOtpauthURI(trimmed)
+	account, err := ParseOtpauthAccount(trimmed)
 	if err != nil {
 		return nil, err
 	}
 
-	return []Account{{
-		Name:      account,
-		Secret:    secret,
-		Interval:  int64(interval),
-		Digits:    digits,
-		Algorithm: algorithm,
-		Type:      otpType,
-		Counter:   counter,
-	}}, nil
+	return []Account{account}, nil
 }
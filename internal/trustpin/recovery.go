@@ -0,0 +1,159 @@
+package trustpin
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultMinRecoveryCodes is the number of unused recovery codes below which
+// the health audit warns, unless the policy sets minRecoveryCodes.
+const DefaultMinRecoveryCodes = 3
+
+// RecoveryCode is one provider-issued backup code. Codes live in the
+// encrypted store next to the account's secret.
+type RecoveryCode struct {
+	Code string `json:"Code"`
+	Used bool   `json:"Used,omitempty"`
+}
+
+// UnusedRecoveryCodes counts the account's codes that have not been used.
+func UnusedRecoveryCodes(account Account) int {
+	unused := 0
+	for _, code := range account.RecoveryCodes {
+		if !code.Used {
+			unused++
+		}
+	}
+	return unused
+}
+
+// ParseRecoveryCodes splits pasted backup codes on newlines, commas and
+// semicolons. Spaces inside a code are kept because many providers print codes
+// in groups.
+func ParseRecoveryCodes(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n' || r == '\r'
+	})
+	codes := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			codes = append(codes, field)
+		}
+	}
+	return codes
+}
+
+// AddRecoveryCodes appends codes to the account matching ref, skipping codes it
+// already holds. It returns the updated account and how many codes were added.
+func (s Service) AddRecoveryCodes(ref string, codes []string) (Account, int, error) {
+	accounts, err := s.LoadAccounts()
+	if err != nil {
+		return Account{}, 0, fmt.Errorf("load accounts: %w", err)
+	}
+
+	idx := FindAccountIndex(accounts, ref)
+	if idx == -1 {
+		return Account{}, 0, notFoundf("no account found matching %q", ref)
+	}
+
+	if len(codes) == 0 {
+		return Account{}, 0, invalidf("codes", "no recovery codes given")
+	}
+
+	account := accounts[idx]
+	merged := append([]RecoveryCode(nil), account.RecoveryCodes...)
+	for _, code := range codes {
+		code = strings.TrimSpace(code)
+		if code == "" || findRecoveryCode(merged, code) >= 0 {
+			continue
+		}
+		merged = append(merged, RecoveryCode{Code: code})
+	}
+	added := len(merged) - len(account.RecoveryCodes)
+	if added == 0 {
+		return account, 0, nil
+	}
+
+	account.RecoveryCodes = merged
+	accounts[idx] = account
+	if err := s.SaveAccounts(accounts); err != nil {
+		return Account{}, 0, fmt.Errorf("save accounts: %w", err)
+	}
+	return account, added, nil
+}
+
+// UseRecoveryCode marks a code as used on the account matching ref. An empty
+// code takes the first unused one. It returns the code and how many remain.
+func (s Service) UseRecoveryCode(ref, code string) (RecoveryCode, int, error) {
+	accounts, err := s.LoadAccounts()
+	if err != nil {
+		return RecoveryCode{}, 0, fmt.Errorf("load accounts: %w", err)
+	}
+
+	idx := FindAccountIndex(accounts, ref)
+	if idx == -1 {
+		return RecoveryCode{}, 0, notFoundf("no account found matching %q", ref)
+	}
+	account := accounts[idx]
+
+	codeIdx := -1
+	if strings.TrimSpace(code) == "" {
+		for i, candidate := range account.RecoveryCodes {
+			if !candidate.Used {
+				codeIdx = i
+				break
+			}
+		}
+		if codeIdx == -1 {
+			return RecoveryCode{}, 0, notFoundf("%s has no unused recovery codes", account.Name)
+		}
+	} else {
+		codeIdx = findRecoveryCode(account.RecoveryCodes, code)
+		if codeIdx == -1 {
+			return RecoveryCode{}, 0, notFoundf("%s has no recovery code %q", account.Name, strings.TrimSpace(code))
+		}
+		if account.RecoveryCodes[codeIdx].Used {
+			return RecoveryCode{}, 0, conflictf("code", "recovery code %q was already used", account.RecoveryCodes[codeIdx].Code)
+		}
+	}
+
+	codes := append([]RecoveryCode(nil), account.RecoveryCodes...)
+	codes[codeIdx].Used = true
+	account.RecoveryCodes = codes
+	accounts[idx] = account
+	if err := s.SaveAccounts(accounts); err != nil {
+		return RecoveryCode{}, 0, fmt.Errorf("save accounts: %w", err)
+	}
+	return codes[codeIdx], UnusedRecoveryCodes(account), nil
+}
+
+// findRecoveryCode matches codes ignoring case, spaces and dashes, since
+// providers print them grouped in different ways.
+func findRecoveryCode(codes []RecoveryCode, code string) int {
+	key := recoveryCodeKey(code)
+	for i, candidate := range codes {
+		if recoveryCodeKey(candidate.Code) == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func recoveryCodeKey(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+func sanitizeRecoveryCodes(codes []RecoveryCode) []RecoveryCode {
+	if len(codes) == 0 {
+		return nil
+	}
+	out := make([]RecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		code.Code = strings.TrimSpace(code.Code)
+		if code.Code == "" || findRecoveryCode(out, code.Code) >= 0 {
+			continue
+		}
+		out = append(out, code)
+	}
+	return out
+}
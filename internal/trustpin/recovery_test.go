@@ -0,0 +1,83 @@
+package trustpin
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseRecoveryCodesKeepsGroupedCodes(t *testing.T) {
+	got := ParseRecoveryCodes("abcd efgh\n1234-5678, 9999 0000;\n\n")
+	want := []string{"abcd efgh", "1234-5678", "9999 0000"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestServiceRecoveryCodeLifecycle(t *testing.T) {
+	tmpDir := t.TempDir()
+	service := Service{
+		StorePath: filepath.Join(tmpDir, "accounts.enc"),
+		KeyPath:   filepath.Join(tmpDir, "accounts.key"),
+	}
+	if _, err := service.UpsertAccounts([]Account{{Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP"}}); err != nil {
+		t.Fatalf("upsert accounts: %v", err)
+	}
+
+	account, added, err := service.AddRecoveryCodes("github:work", []string{"1111-2222", "3333-4444", "11112222"})
+	if err != nil {
+		t.Fatalf("add recovery codes: %v", err)
+	}
+	if added != 2 || len(account.RecoveryCodes) != 2 {
+		t.Fatalf("expected duplicate codes to be skipped, added=%d codes=%+v", added, account.RecoveryCodes)
+	}
+
+	used, remaining, err := service.UseRecoveryCode("GitHub:work", "")
+	if err != nil {
+		t.Fatalf("use next code: %v", err)
+	}
+	if used.Code != "1111-2222" || remaining != 1 {
+		t.Fatalf("expected the first code to be used, got %+v remaining=%d", used, remaining)
+	}
+	if _, _, err := service.UseRecoveryCode("GitHub:work", "1111 2222"); err == nil {
+		t.Fatalf("expected a used code to be rejected")
+	}
+
+	if err := service.UpdateAccount("GitHub:work", Account{Name: "GitHub:work", Notes: "edited"}); err != nil {
+		t.Fatalf("update account: %v", err)
+	}
+	account, err = service.GetAccount("GitHub:work")
+	if err != nil {
+		t.Fatalf("get account: %v", err)
+	}
+	if len(account.RecoveryCodes) != 2 || UnusedRecoveryCodes(account) != 1 {
+		t.Fatalf("expected an edit to keep recovery codes, got %+v", account.RecoveryCodes)
+	}
+}
+
+func TestAnalyzeAccountsWarnsOnLowRecoveryCodes(t *testing.T) {
+	accounts := []Account{{
+		Name:          "GitHub:work",
+		Secret:        "JBSWY3DPEHPK3PXP",
+		RecoveryCodes: []RecoveryCode{{Code: "a", Used: true}, {Code: "b"}, {Code: "c"}},
+	}}
+
+	items := AnalyzeAccounts(accounts)
+	if !hasHealthRule(items, RuleLowRecoveryCodes) {
+		t.Fatalf("expected %s with 2 unused codes, got %+v", RuleLowRecoveryCodes, items)
+	}
+	if hasHealthRule(items, RuleMissingRecovery) {
+		t.Fatalf("did not expect %s when recovery codes are stored", RuleMissingRecovery)
+	}
+
+	policy := DefaultHealthPolicy()
+	policy.Thresholds.MinRecoveryCodes = 2
+	items, _ = AnalyzeAccountsWithPolicy(accounts, policy)
+	if hasHealthRule(items, RuleLowRecoveryCodes) {
+		t.Fatalf("expected the policy minimum to be respected, got %+v", items)
+	}
+}
+
+func hasHealthRule(items []HealthItem, rule string) bool {
+	return slices.ContainsFunc(items, func(item HealthItem) bool { return item.Rule == rule })
+}
@@ -0,0 +1,261 @@
+package trustpin
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Search scores. A query that spells out an account's ID, name, issuer or
+// label outranks anything matched term by term; each term then scores by
+// how well it matches its best field, weighted by the field.
+const (
+	scoreExactID       = 10000
+	scoreExactName     = 1200
+	scoreExactPart     = 1000
+	scoreFavoriteBoost = 15
+	scoreRecentBoost   = 10
+	scoreWarmBoost     = 5
+)
+
+// SearchMatch is an account that matched a search and how well it did.
+type SearchMatch struct {
+	Account Account
+	Score   int
+}
+
+type searchField struct {
+	text   string
+	weight int
+	// fuzzy allows subsequence matches, which are only useful in short
+	// fields; in notes nearly every short term is a subsequence.
+	fuzzy bool
+}
+
+// SearchAccounts returns the accounts that match query, best first. Every
+// term of the query has to match the name, issuer, label, tags or notes,
+// allowing for a typo or skipped letters in longer terms. Ties keep the
+// order of accounts.
+func SearchAccounts(accounts []Account, query string) []SearchMatch {
+	matches := make([]SearchMatch, 0)
+	for _, account := range accounts {
+		if score := ScoreAccount(account, query); score > 0 {
+			matches = append(matches, SearchMatch{Account: account, Score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	return matches
+}
+
+// ScoreAccount rates how well account matches query; zero means it does
+// not match. Favorites and recently used accounts get a small boost, enough
+// to order close matches but not to turn a tie into a clear winner.
+func ScoreAccount(account Account, query string) int {
+	query = normalizeAccountName(query)
+	if query == "" {
+		return 0
+	}
+	if account.ID != "" && strings.EqualFold(account.ID, query) {
+		return scoreExactID
+	}
+
+	issuer, label, _ := AccountIssuer(account)
+	name, issuer, label := normalizeAccountName(account.Name), normalizeAccountName(issuer), normalizeAccountName(label)
+	score := 0
+	switch query {
+	case name:
+		score = scoreExactName
+	case issuer, label:
+		score = scoreExactPart
+	}
+
+	fields := []searchField{
+		{text: name, weight: 3, fuzzy: true},
+		{text: issuer, weight: 3, fuzzy: true},
+		{text: label, weight: 3, fuzzy: true},
+		{text: strings.ToLower(strings.Join(account.Tags, " ")), weight: 2, fuzzy: true},
+		{text: strings.ToLower(account.Notes), weight: 1},
+	}
+	for _, term := range strings.Fields(query) {
+		best := 0
+		for _, field := range fields {
+			best = max(best, field.weight*termScore(term, field.text, field.fuzzy))
+		}
+		if best == 0 {
+			return 0
+		}
+		score += best
+	}
+
+	if account.Favorite {
+		score += scoreFavoriteBoost
+	}
+	if !account.LastUsedAt.IsZero() {
+		switch since := time.Since(account.LastUsedAt); {
+		case since < 7*24*time.Hour:
+			score += scoreRecentBoost
+		case since < 30*24*time.Hour:
+			score += scoreWarmBoost
+		}
+	}
+	return score
+}
+
+// ClearWinner returns the top match when it stands out: it is the only
+// match, or it scores at least half again as much as the runner-up.
+func ClearWinner(matches []SearchMatch) (SearchMatch, bool) {
+	switch {
+	case len(matches) == 0:
+		return SearchMatch{}, false
+	case len(matches) == 1:
+		return matches[0], true
+	case 2*matches[0].Score >= 3*matches[1].Score:
+		return matches[0], true
+	default:
+		return SearchMatch{}, false
+	}
+}
+
+// termScore rates one query term against a lowercase field: whole field,
+// field prefix, word, word prefix, substring, a word within a typo or two,
+// and finally the term's letters in order.
+func termScore(term, text string, fuzzy bool) int {
+	switch {
+	case text == "":
+		return 0
+	case text == term:
+		return 100
+	case strings.HasPrefix(text, term):
+		return 80
+	}
+
+	words := searchWords(text)
+	best := 0
+	for _, word := range words {
+		switch {
+		case word == term:
+			best = max(best, 75)
+		case strings.HasPrefix(word, term):
+			best = max(best, 65)
+		}
+	}
+	if best > 0 {
+		return best
+	}
+	if strings.Contains(text, term) {
+		return 50
+	}
+
+	if tolerance := typoTolerance(term); tolerance > 0 {
+		for _, word := range words {
+			if distance := editDistance(term, word, tolerance); distance <= tolerance {
+				best = max(best, 40-10*distance)
+			}
+		}
+		if best > 0 {
+			return best
+		}
+	}
+
+	if fuzzy {
+		return subsequenceScore(term, text)
+	}
+	return 0
+}
+
+// searchWords splits text on anything that is not a letter or digit, so
+// "AWS SSO:prod-eu" yields aws, sso, prod and eu.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
+
+// typoTolerance is how many edits a term may be off by. Short terms must
+// be spelled right, or nearly every word would match them.
+func typoTolerance(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// insertions, deletions, substitutions and swaps of adjacent letters. It
+// gives up once the distance is over limit.
+func editDistance(a, b string, limit int) int {
+	ar, br := []rune(a), []rune(b)
+	if abs(len(ar)-len(br)) > limit {
+		return limit + 1
+	}
+
+	prev2 := make([]int, len(br)+1)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ar[i-1] == br[j-2] && ar[i-2] == br[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, curr[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(br)]
+}
+
+// subsequenceScore matches the letters of term in order anywhere in text,
+// scoring tighter matches higher. Terms of one letter never match this way,
+// and neither do letters spread over more than three times the term.
+func subsequenceScore(term, text string) int {
+	termRunes := []rune(term)
+	if len(termRunes) < 2 {
+		return 0
+	}
+
+	best := 0
+	textRunes := []rune(text)
+	for start := range textRunes {
+		if textRunes[start] != termRunes[0] {
+			continue
+		}
+		matched, end := 1, start
+		for i := start + 1; i < len(textRunes) && matched < len(termRunes); i++ {
+			if textRunes[i] == termRunes[matched] {
+				matched++
+				end = i
+			}
+		}
+		if matched < len(termRunes) {
+			break
+		}
+		if span := end - start + 1; span <= 3*len(termRunes) {
+			best = max(best, 10+20*len(termRunes)/span)
+		}
+	}
+	return best
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,79 @@
+package trustpin
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSearchAccountsRanksMatches(t *testing.T) {
+	accounts := []Account{
+		{Name: "GitHub:work", Tags: []string{"dev"}},
+		{Name: "GitLab:ci"},
+		{Name: "AWS SSO:prod", Notes: "root login for the billing account"},
+		{Name: "AWS SSO:production"},
+		{Name: "Bank", Tags: []string{"finance"}},
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"github", "GitHub:work"},
+		{"gihtub", "GitHub:work"},
+		{"ghwrk", "GitHub:work"},
+		{"prod", "AWS SSO:prod"},
+		{"billing", "AWS SSO:prod"},
+		{"fin", "Bank"},
+		{"AWS SSO:production", "AWS SSO:production"},
+	}
+	for _, tt := range tests {
+		winner, ok := ClearWinner(SearchAccounts(accounts, tt.query))
+		if !ok || winner.Account.Name != tt.want {
+			t.Errorf("%q: got %q (clear %v), want %q", tt.query, winner.Account.Name, ok, tt.want)
+		}
+	}
+
+	if matches := SearchAccounts(accounts, "git"); len(matches) != 2 {
+		t.Fatalf("expected both Git accounts, got %+v", matches)
+	} else if _, ok := ClearWinner(matches); ok {
+		t.Fatalf("expected an ambiguous prefix to have no clear winner")
+	}
+	if matches := SearchAccounts(accounts, "aws nothing"); len(matches) != 0 {
+		t.Fatalf("expected every term to have to match, got %+v", matches)
+	}
+	if matches := SearchAccounts(accounts, "xq"); len(matches) != 0 {
+		t.Fatalf("expected no match, got %+v", matches)
+	}
+}
+
+func TestSearchBoostsFavoritesAndRecentUse(t *testing.T) {
+	accounts := []Account{
+		{Name: "GitHub:work"},
+		{Name: "GitHub:home", Favorite: true},
+		{Name: "GitHub:old", LastUsedAt: time.Now().Add(-time.Hour)},
+	}
+	matches := SearchAccounts(accounts, "github")
+	if len(matches) != 3 || matches[0].Account.Name != "GitHub:home" || matches[1].Account.Name != "GitHub:old" {
+		t.Fatalf("expected the favorite, then the recently used account first, got %+v", matches)
+	}
+	if _, ok := ClearWinner(matches); ok {
+		t.Fatalf("expected boosts not to break a tie into a clear winner")
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"github", "github", 0},
+		{"gihtub", "github", 1},
+		{"githb", "github", 1},
+		{"gitlub", "github", 1},
+		{"amazon", "github", 3},
+	} {
+		if got := editDistance(tt.a, tt.b, 2); min(got, 3) != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package trustpin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// StoreSchemaVersion is the payload schema this build reads and writes.
+// Schema 1 was a bare JSON array of accounts; schema 2 wraps the accounts in
+// an envelope with vault metadata; schema 3 adds account timestamps; schema
+// 4 adds custom account colors and icons.
+const StoreSchemaVersion = 4
+
+// StoreMetadata describes the vault as a whole rather than any one account.
+type StoreMetadata struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// storePayload is the decrypted contents of the store.
+type storePayload struct {
+	SchemaVersion int           `json:"schemaVersion"`
+	Vault         StoreMetadata `json:"vault"`
+	Accounts      []Account     `json:"accounts"`
+}
+
+// storeMigrations[i] upgrades a payload from schema i+1 to i+2. Append a step
+// and bump StoreSchemaVersion whenever the stored shape changes.
+var storeMigrations = []func(*storePayload){
+	migrateStoreV1,
+	migrateStoreV2,
+	migrateStoreV3,
+}
+
+// decodeStorePayload parses decrypted store contents of any known schema.
+func decodeStorePayload(plaintext []byte) (storePayload, error) {
+	trimmed := bytes.TrimSpace(plaintext)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var accounts []Account
+		if err := json.Unmarshal(trimmed, &accounts); err != nil {
+			return storePayload{}, storeError(ErrCorruptStore, "decode decrypted store", err)
+		}
+		return storePayload{SchemaVersion: 1, Accounts: accounts}, nil
+	}
+
+	var payload storePayload
+	if err := json.Unmarshal(trimmed, &payload); err != nil {
+		return storePayload{}, storeError(ErrCorruptStore, "decode decrypted store", err)
+	}
+	if payload.SchemaVersion < 2 {
+		return storePayload{}, storeError(ErrCorruptStore, fmt.Sprintf("store envelope has invalid schema version %d", payload.SchemaVersion), nil)
+	}
+	if payload.Accounts == nil {
+		payload.Accounts = []Account{}
+	}
+	return payload, nil
+}
+
+// migrate runs the migration chain up to StoreSchemaVersion and reports
+// whether anything ran. A payload from a newer build is left as it is; it can
+// be read, but writeStore refuses to overwrite it.
+func (p *storePayload) migrate() bool {
+	if p.SchemaVersion >= StoreSchemaVersion {
+		return false
+	}
+	for p.SchemaVersion < StoreSchemaVersion {
+		storeMigrations[p.SchemaVersion-1](p)
+		p.SchemaVersion++
+	}
+	return true
+}
+
+// migrateStoreV1 brings accounts saved as a bare array up to date. The vault
+// metadata is filled in when the store is next written.
+func migrateStoreV1(p *storePayload) {
+	if p.Accounts == nil {
+		p.Accounts = []Account{}
+	}
+	migrateSecretEncodings(p.Accounts)
+	assignAccountIDs(p.Accounts)
+	migrateIssuerLabels(p.Accounts)
+}
+
+// migrateStoreV2 adds nothing to existing accounts: when they were added or
+// last used is unknown, so their timestamps stay empty until they change.
+func migrateStoreV2(*storePayload) {}
+
+// migrateStoreV3 adds nothing either: existing accounts keep their issuer's
+// brand until a custom color or icon is set.
+func migrateStoreV3(*storePayload) {}
+
+// newerStoreError refuses to overwrite a store whose schema this build does
+// not know, since saving would drop whatever the newer build added.
+func newerStoreError(version int) error {
+	return storeError(ErrNewerStore, fmt.Sprintf("store uses schema %d but this TrustPIN only knows schema %d; upgrade TrustPIN before changing it", version, StoreSchemaVersion), nil)
+}
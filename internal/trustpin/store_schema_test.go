@@ -0,0 +1,101 @@
+package trustpin
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+// writeStorePayload encrypts a raw payload into the store, bypassing
+// SaveAccounts so tests can seed stores of any schema.
+func writeStorePayload(t *testing.T, service Service, payload interface{}) {
+	t.Helper()
+	key, err := service.loadOrCreateKey()
+	if err != nil {
+		t.Fatalf("create key: %v", err)
+	}
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+	encrypted, err := encryptPayload(plaintext, key)
+	if err != nil {
+		t.Fatalf("encrypt payload: %v", err)
+	}
+	if err := os.WriteFile(service.StorePath, encrypted, 0o600); err != nil {
+		t.Fatalf("write store: %v", err)
+	}
+}
+
+func readStorePayload(t *testing.T, service Service) storePayload {
+	t.Helper()
+	key, err := service.loadKey()
+	if err != nil {
+		t.Fatalf("load key: %v", err)
+	}
+	data, err := os.ReadFile(service.StorePath)
+	if err != nil {
+		t.Fatalf("read store: %v", err)
+	}
+	payload, err := decryptStore(data, key)
+	if err != nil {
+		t.Fatalf("decrypt store: %v", err)
+	}
+	return payload
+}
+
+func TestLoadAccountsMigratesSchemaV1Store(t *testing.T) {
+	service := newDoctorService(t)
+	writeStorePayload(t, service, []Account{{Name: "GitHub:work", Secret: "jbswy3dpehpk3pxp"}})
+
+	accounts, err := service.LoadAccounts()
+	if err != nil {
+		t.Fatalf("load accounts: %v", err)
+	}
+	if len(accounts) != 1 || accounts[0].ID == "" || accounts[0].Issuer != "GitHub" || accounts[0].SecretEncoding == "" {
+		t.Fatalf("expected the v1 migration to run, got %+v", accounts)
+	}
+
+	first := readStorePayload(t, service)
+	if first.SchemaVersion != StoreSchemaVersion || first.Vault.ID == "" || first.Vault.CreatedAt.IsZero() {
+		t.Fatalf("expected a current envelope with vault metadata, got %+v", first)
+	}
+	if len(service.StoreBackups()) != 1 {
+		t.Fatalf("expected the v1 store to be kept as a backup")
+	}
+
+	if err := service.SaveAccounts(accounts); err != nil {
+		t.Fatalf("save accounts: %v", err)
+	}
+	second := readStorePayload(t, service)
+	if second.Vault.ID != first.Vault.ID || !second.Vault.CreatedAt.Equal(first.Vault.CreatedAt) || second.Vault.UpdatedAt.Before(first.Vault.UpdatedAt) {
+		t.Fatalf("expected the vault metadata to carry over, got %+v then %+v", first.Vault, second.Vault)
+	}
+}
+
+func TestNewerSchemaStoreIsReadOnly(t *testing.T) {
+	service := newDoctorService(t)
+	writeStorePayload(t, service, map[string]interface{}{
+		"schemaVersion": StoreSchemaVersion + 1,
+		"vault":         map[string]interface{}{"id": "future"},
+		"accounts":      []map[string]interface{}{{"ID": "a1", "Name": "GitHub:work", "Secret": "JBSWY3DPEHPK3PXP", "SecretEncoding": EncodingBase32, "Issuer": "GitHub", "Label": "work", "Shiny": true}},
+	})
+	before, _ := os.ReadFile(service.StorePath)
+
+	accounts, err := service.LoadAccounts()
+	if err != nil || len(accounts) != 1 || accounts[0].Name != "GitHub:work" {
+		t.Fatalf("expected a newer store to stay readable, got %v %+v", err, accounts)
+	}
+
+	err = service.SaveAccounts(accounts)
+	if !errors.Is(err, ErrNewerStore) || !errors.Is(err, ErrStoreLocked) {
+		t.Fatalf("expected saving to be refused, got %v", err)
+	}
+	if after, _ := os.ReadFile(service.StorePath); string(after) != string(before) {
+		t.Fatalf("expected the newer store to be left untouched")
+	}
+	if statuses := diagnosticStatuses(service.Diagnose()); statuses[CheckSchemaVersion] != DiagnosticWarning {
+		t.Fatalf("expected doctor to flag the schema, got %v", statuses)
+	}
+}
@@ -0,0 +1,117 @@
+package trustpin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// stampAccounts fills in timestamps against the accounts currently on disk.
+// New accounts get CreatedAt; accounts whose content differs from their
+// stored copy get UpdatedAt. A replacement built from user input carries the
+// stored CreatedAt and LastUsedAt over. Use, ordering and timestamps alone do
+// not count as an update. It reports whether any account was added, removed,
+// or changed.
+func stampAccounts(accounts, previous []Account, now time.Time) bool {
+	now = now.UTC()
+	byID := make(map[string]Account, len(previous))
+	byName := make(map[string]Account)
+	for _, account := range previous {
+		if account.ID != "" {
+			byID[account.ID] = account
+		} else {
+			// Schema 1 stores had no IDs yet.
+			byName[normalizeAccountName(account.Name)] = account
+		}
+	}
+
+	changed := len(accounts) != len(previous)
+	for i := range accounts {
+		account := &accounts[i]
+		stored, ok := byID[account.ID]
+		if !ok {
+			stored, ok = byName[normalizeAccountName(account.Name)]
+		}
+		if !ok {
+			if account.CreatedAt.IsZero() {
+				account.CreatedAt = now
+			}
+			if account.UpdatedAt.IsZero() {
+				account.UpdatedAt = now
+			}
+			changed = true
+			continue
+		}
+
+		if account.CreatedAt.IsZero() {
+			account.CreatedAt = stored.CreatedAt
+		}
+		if account.LastUsedAt.Before(stored.LastUsedAt) {
+			account.LastUsedAt = stored.LastUsedAt
+		}
+		if accountContentChanged(stored, *account) {
+			account.UpdatedAt = now
+			changed = true
+		} else if account.UpdatedAt.IsZero() {
+			account.UpdatedAt = stored.UpdatedAt
+		}
+	}
+	return changed
+}
+
+// accountContentChanged compares two accounts as they would be stored,
+// ignoring the fields that change without the user editing the account.
+func accountContentChanged(before, after Account) bool {
+	strip := func(account Account) []byte {
+		account.SortOrder = 0
+		account.CreatedAt, account.UpdatedAt, account.LastUsedAt = time.Time{}, time.Time{}, time.Time{}
+		data, _ := json.Marshal(account)
+		return data
+	}
+	return !bytes.Equal(strip(before), strip(after))
+}
+
+// RecordUse marks the account matching ref as used at now, for example after
+// its code was copied or revealed.
+func (s Service) RecordUse(ref string, now time.Time) (Account, error) {
+	accounts, err := s.LoadAccounts()
+	if err != nil {
+		return Account{}, fmt.Errorf("load accounts: %w", err)
+	}
+
+	idx := FindAccountIndex(accounts, ref)
+	if idx == -1 {
+		return Account{}, notFoundf("no account found matching %q", ref)
+	}
+	accounts[idx].LastUsedAt = now.UTC()
+
+	if err := s.SaveAccounts(accounts); err != nil {
+		return Account{}, fmt.Errorf("save accounts: %w", err)
+	}
+	return accounts[idx], nil
+}
+
+// AdvanceHOTP moves a counter-based account to its next code and marks it as
+// used. The returned account holds the new counter.
+func (s Service) AdvanceHOTP(ref string, now time.Time) (Account, error) {
+	accounts, err := s.LoadAccounts()
+	if err != nil {
+		return Account{}, fmt.Errorf("load accounts: %w", err)
+	}
+
+	idx := FindAccountIndex(accounts, ref)
+	if idx == -1 {
+		return Account{}, notFoundf("no account found matching %q", ref)
+	}
+	if NormalizeType(accounts[idx].Type) != TypeHOTP {
+		return Account{}, invalidf("type", "%s is not a counter-based (HOTP) account", accounts[idx].Name)
+	}
+	accounts[idx].Counter++
+	accounts[idx].LastUsedAt = now.UTC()
+
+	if err := s.SaveAccounts(accounts); err != nil {
+		return Account{}, fmt.Errorf("save accounts: %w", err)
+	}
+	return accounts[idx], nil
+}
@@ -0,0 +1,98 @@
+package trustpin
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAccountTimestamps(t *testing.T) {
+	service := newDoctorService(t)
+	old := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	writeStorePayload(t, service, storePayload{
+		SchemaVersion: StoreSchemaVersion,
+		Vault:         StoreMetadata{ID: "vault", CreatedAt: old, UpdatedAt: old},
+		Accounts: []Account{
+			{ID: "gh", Name: "GitHub:work", Issuer: "GitHub", Label: "work", Secret: "GEZDGNBVGY3TQOJQ", SecretEncoding: EncodingBase32, Interval: 30, Digits: 6, Algorithm: AlgorithmSHA1, Type: TypeTOTP, CreatedAt: old, UpdatedAt: old},
+			{ID: "bank", Name: "Bank:me", Issuer: "Bank", Label: "me", Secret: "MFRGGZDFMZTWQ2LK", SecretEncoding: EncodingBase32, Interval: 30, Digits: 6, Algorithm: AlgorithmSHA1, Type: TypeHOTP, Counter: 4},
+		},
+	})
+
+	used := time.Date(2026, 5, 6, 7, 8, 9, 0, time.UTC)
+	account, err := service.RecordUse("gh", used)
+	if err != nil {
+		t.Fatalf("record use: %v", err)
+	}
+	if !account.LastUsedAt.Equal(used) || !account.UpdatedAt.Equal(old) || !account.CreatedAt.Equal(old) {
+		t.Fatalf("expected only LastUsedAt to move, got %+v", account)
+	}
+	if backups := service.StoreBackups(); len(backups) != 0 {
+		t.Fatalf("expected a recorded use not to rotate backups, got %+v", backups)
+	}
+
+	if err := service.UpdateAccount("gh", Account{Name: "GitHub:work", Notes: "SSO", Interval: 30, Digits: 6}); err != nil {
+		t.Fatalf("update account: %v", err)
+	}
+	account, _ = service.GetAccount("gh")
+	if !account.CreatedAt.Equal(old) || !account.LastUsedAt.Equal(used) || !account.UpdatedAt.After(old) {
+		t.Fatalf("expected the edit to move UpdatedAt and keep the rest, got %+v", account)
+	}
+
+	next, err := service.AdvanceHOTP("bank", used)
+	if err != nil {
+		t.Fatalf("advance HOTP: %v", err)
+	}
+	if next.Counter != 5 || !next.LastUsedAt.Equal(used) || !next.CreatedAt.IsZero() {
+		t.Fatalf("expected counter 5, a recorded use and an unknown creation time, got %+v", next)
+	}
+	if _, err := service.AdvanceHOTP("gh", used); !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a TOTP account to be rejected, got %v", err)
+	}
+
+	if err := service.SaveAccounts(append(mustLoadAccounts(t, service), Account{Name: "Slack:me", Secret: "JBSWY3DPEHPK3PXP", Interval: 30, Digits: 6})); err != nil {
+		t.Fatalf("save accounts: %v", err)
+	}
+	added, _ := service.GetAccount("Slack:me")
+	if added.CreatedAt.IsZero() || !added.UpdatedAt.Equal(added.CreatedAt) || !added.LastUsedAt.IsZero() {
+		t.Fatalf("expected a new account to be stamped on save, got %+v", added)
+	}
+}
+
+func TestUnusedAccountFinding(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name    string
+		account Account
+		want    bool
+	}{
+		{"recently used", Account{Name: "A", CreatedAt: now.AddDate(-1, 0, 0), LastUsedAt: now.AddDate(0, 0, -10)}, false},
+		{"used long ago", Account{Name: "B", CreatedAt: now.AddDate(-1, 0, 0), LastUsedAt: now.AddDate(0, 0, -91)}, true},
+		{"never used, old", Account{Name: "C", CreatedAt: now.AddDate(0, 0, -100)}, true},
+		{"never used, new", Account{Name: "D", CreatedAt: now.AddDate(0, 0, -5)}, false},
+		{"no timestamps", Account{Name: "E"}, false},
+	}
+	for _, c := range cases {
+		if _, got := unusedAccountFinding(c.account, 90, now); got != c.want {
+			t.Errorf("%s: flagged %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	policy := DefaultHealthPolicy()
+	account := Account{Name: "Old:one", Secret: "GEZDGNBVGY3TQOJQ", Interval: 30, Digits: 6, CreatedAt: time.Now().AddDate(-1, 0, 0)}
+	if findings := policy.thresholdFindings(account, "Old"); len(findings) != 0 {
+		t.Fatalf("expected the rule to stay silent without a threshold, got %+v", findings)
+	}
+	policy.Thresholds.MaxUnusedDays = 30
+	if findings := policy.thresholdFindings(account, "Old"); len(findings) != 1 || findings[0].Rule != RuleUnusedAccount {
+		t.Fatalf("expected an unused-account finding, got %+v", findings)
+	}
+}
+
+func mustLoadAccounts(t *testing.T, service Service) []Account {
+	t.Helper()
+	accounts, err := service.LoadAccounts()
+	if err != nil {
+		t.Fatalf("load accounts: %v", err)
+	}
+	return accounts
+}
@@ -0,0 +1,92 @@
+package webui
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+//go:embed index.html assets
+var webContent embed.FS
+
+// uiAssets is the embedded dashboard with content-hashed asset names, so
+// browsers can cache scripts and styles forever and still pick up a new build.
+type uiAssets struct {
+	index []byte
+	files map[string]staticAsset
+}
+
+type staticAsset struct {
+	contentType string
+	data        []byte
+}
+
+var loadUIAssets = sync.OnceValues(func() (uiAssets, error) {
+	index, err := webContent.ReadFile("index.html")
+	if err != nil {
+		return uiAssets{}, fmt.Errorf("read index.html: %w", err)
+	}
+
+	assets := uiAssets{files: map[string]staticAsset{}}
+	entries, err := fs.ReadDir(webContent, "assets")
+	if err != nil {
+		return uiAssets{}, fmt.Errorf("read assets: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		data, err := webContent.ReadFile(path.Join("assets", name))
+		if err != nil {
+			return uiAssets{}, fmt.Errorf("read asset %s: %w", name, err)
+		}
+
+		sum := sha256.Sum256(data)
+		ext := path.Ext(name)
+		hashed := strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:6]) + ext
+		assets.files[hashed] = staticAsset{contentType: mime.TypeByExtension(ext), data: data}
+		index = bytes.ReplaceAll(index, []byte(`"/assets/`+name+`"`), []byte(`"/assets/`+hashed+`"`))
+	}
+	assets.index = index
+	return assets, nil
+})
+
+func (s server) handleUI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	assets, err := loadUIAssets()
+	if err != nil {
+		http.Error(w, "UI not available", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(assets.index)
+}
+
+func (s server) handleAsset(w http.ResponseWriter, r *http.Request) {
+	assets, err := loadUIAssets()
+	if err != nil {
+		http.Error(w, "UI not available", http.StatusInternalServerError)
+		return
+	}
+	asset, ok := assets.files[r.PathValue("name")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", asset.contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	_, _ = w.Write(asset.data)
+}
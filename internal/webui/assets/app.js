@@ -0,0 +1,1668 @@
+/* ══════════════════ STATE ══════════════════ */
+let accounts = [];
+let searchTerm = '';
+let sortBy = 'expiry';
+let pendingDeleteId = null;
+let prevOTPs = {};
+let refreshTimer = null;
+let lastAccountKeys = '';
+let isFirstRender = true;
+let privacyMode = loadPrivacyMode();
+let accountModalMode = 'add';
+let editingAccountId = null;
+let darkMode = loadThemeMode();
+let clipboardTimer = null;
+let draggedCard = null;
+let showArchived = false;
+let selectionMode = false;
+let selectedIds = new Set();
+let pendingBulkDelete = null;
+let locked = false;
+let groupBy = loadGroupBy();
+let collapsedGroups = loadCollapsedGroups();
+let accountGroups = null;
+let searchTimer = null;
+let openDetails = new Set();
+let userActive = false;
+
+/* ══════════════════ ICONS (SVG) ══════════════════ */
+const ICONS = {
+  lock: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><rect x="3" y="11" width="18" height="11" rx="2"/><path d="M7 11V7a5 5 0 0 1 10 0v4"/></svg>',
+  copy: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><rect x="9" y="9" width="13" height="13" rx="2"/><path d="M5 15H4a2 2 0 0 1-2-2V4a2 2 0 0 1 2-2h9a2 2 0 0 1 2 2v1"/></svg>',
+  check: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="M20 6 9 17l-5-5"/></svg>',
+  edit: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="M12 20h9"/><path d="M16.5 3.5a2.12 2.12 0 1 1 3 3L7 19l-4 1 1-4Z"/></svg>',
+  trash: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="M3 6h18M19 6v14a2 2 0 0 1-2 2H7a2 2 0 0 1-2-2V6m3 0V4a2 2 0 0 1 2-2h4a2 2 0 0 1 2 2v2"/></svg>',
+  eye: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="M2 12s3.5-7 10-7 10 7 10 7-3.5 7-10 7-10-7-10-7Z"/><circle cx="12" cy="12" r="3"/></svg>',
+  eyeOff: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="m3 3 18 18"/><path d="M10.58 10.58A2 2 0 0 0 13.41 13.4"/><path d="M9.88 5.09A10.94 10.94 0 0 1 12 5c6.5 0 10 7 10 7a17.46 17.46 0 0 1-2.17 3.19"/><path d="M6.61 6.61C4.62 8 3.28 10.12 2 12c0 0 3.5 7 10 7 1.76 0 3.31-.39 4.66-1.02"/></svg>',
+  search: '<svg width="16" height="16" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><circle cx="11" cy="11" r="8"/><path d="m21 21-4.3-4.3"/></svg>',
+  plus: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="M12 5v14M5 12h14"/></svg>',
+  heart: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="M19 14c1.49-1.46 3-3.21 3-5.5A5.5 5.5 0 0 0 16.5 3c-1.76 0-3 .5-4.5 2-1.5-1.5-2.74-2-4.5-2A5.5 5.5 0 0 0 2 8.5c0 2.3 1.5 4.05 3 5.5l7 7Z"/></svg>',
+  shield: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="M12 22s8-4 8-10V5l-8-3-8 3v7c0 6 8 10 8 10z"/></svg>',
+  building: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><rect x="4" y="2" width="16" height="20" rx="2"/><path d="M9 22v-4h6v4M8 6h.01M16 6h.01M12 6h.01M12 10h.01M8 10h.01M16 10h.01M12 14h.01M8 14h.01M16 14h.01"/></svg>',
+  qr: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><rect x="3" y="3" width="7" height="7"/><rect x="14" y="3" width="7" height="7"/><rect x="3" y="14" width="7" height="7"/><path d="M14 14h3v3h-3zm4 0h3v3h-3zm-4 4h3v3h-3zm4 4h3"/></svg>',
+  star: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><polygon points="12 2 15.09 8.26 22 9.27 17 14.14 18.18 21.02 12 17.77 5.82 21.02 7 14.14 2 9.27 8.91 8.26 12 2"/></svg>',
+  starFill: '<svg viewBox="0 0 24 24" fill="currentColor" stroke="currentColor" stroke-width="2"><polygon points="12 2 15.09 8.26 22 9.27 17 14.14 18.18 21.02 12 17.77 5.82 21.02 7 14.14 2 9.27 8.91 8.26 12 2"/></svg>',
+  sun: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><circle cx="12" cy="12" r="4"/><path d="M12 2v2M12 20v2M4.93 4.93l1.41 1.41M17.66 17.66l1.41 1.41M2 12h2M20 12h2M6.34 17.66l-1.41 1.41M19.07 4.93l-1.41 1.41"/></svg>',
+  moon: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="M21 12.79A9 9 0 1 1 11.21 3 7 7 0 0 0 21 12.79z"/></svg>',
+  note: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="M14.5 2H6a2 2 0 0 0-2 2v16a2 2 0 0 0 2 2h12a2 2 0 0 0 2-2V7.5L14.5 2z"/><polyline points="14 2 14 8 20 8"/></svg>',
+  archive: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><rect x="2" y="3" width="20" height="5" rx="1"/><path d="M4 8v11a2 2 0 0 0 2 2h12a2 2 0 0 0 2-2V8"/><path d="M10 12h4"/></svg>',
+  restore: '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="M3 12a9 9 0 1 0 9-9 9.75 9.75 0 0 0-6.74 2.74L3 8"/><path d="M3 3v5h5"/></svg>',
+};
+
+/* ══════════════════ API ══════════════════ */
+/* apiFetch tells the server when the user has interacted since the last
+   request, so background polling alone lets the session go idle. */
+async function apiFetch(url, options = {}) {
+  const headers = new Headers(options.headers || {});
+  if (userActive) {
+    headers.set('X-TrustPIN-Activity', '1');
+    userActive = false;
+  }
+  const res = await fetch(url, { ...options, headers });
+  if (res.status === 401) {
+    showLockScreen('Locked after inactivity. Enter the access token to continue.');
+    throw new Error('Dashboard is locked');
+  }
+  return res;
+}
+
+// apiErrorMessage reads the message out of an API error envelope.
+function apiErrorMessage(body, fallback) {
+  return (body && body.error && body.error.message) || fallback;
+}
+
+// fetchAccounts returns the account list and, when grouping is on, the
+// groups the server sorted it into. A search is ranked by the server.
+async function fetchAccounts() {
+  const by = groupBy;
+  const q = searchTerm.trim();
+  const params = new URLSearchParams();
+  if (by) params.set('groupBy', by);
+  if (q) params.set('q', q);
+  try {
+    const query = params.toString();
+    const res = await apiFetch('/api/v1/accounts' + (query ? `?${query}` : ''));
+    if (!res.ok) throw new Error('Failed to fetch');
+    const data = await res.json();
+    if (!by) return { by, q, list: data, groups: null };
+    const seen = new Set();
+    const list = data.flatMap(g => g.accounts).filter(a => !seen.has(a.id) && seen.add(a.id));
+    return { by, q, list, groups: data };
+  } catch (e) {
+    console.error('Fetch accounts error:', e);
+    return null;
+  }
+}
+
+async function apiAddAccount(data) {
+  const res = await apiFetch('/api/v1/accounts', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(data),
+  });
+  const body = await res.json();
+  if (!res.ok) throw new Error(apiErrorMessage(body, 'Failed to add account'));
+  return body;
+}
+
+async function apiUpdateAccount(id, data) {
+  const res = await apiFetch(`/api/v1/accounts/${encodeURIComponent(id)}`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(data),
+  });
+  const body = await res.json();
+  if (!res.ok) throw new Error(apiErrorMessage(body, 'Failed to update account'));
+  return body;
+}
+
+async function apiDeleteAccount(id) {
+  const res = await apiFetch(`/api/v1/accounts/${encodeURIComponent(id)}`, { method: 'DELETE' });
+  const body = await res.json();
+  if (!res.ok) throw new Error(apiErrorMessage(body, 'Failed to delete'));
+  return body;
+}
+
+async function apiSetArchived(id, archived) {
+  const res = await apiFetch(`/api/v1/accounts/${encodeURIComponent(id)}/archive`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ archived }),
+  });
+  const body = await res.json();
+  if (!res.ok) throw new Error(apiErrorMessage(body, 'Failed to update archive status'));
+  return body;
+}
+
+async function apiSetIcon(id, file) {
+  const form = new FormData();
+  form.append('icon', file);
+  const res = await apiFetch(`/api/v1/accounts/${encodeURIComponent(id)}/icon`, { method: 'PUT', body: form });
+  const body = await res.json();
+  if (!res.ok) throw new Error(apiErrorMessage(body, 'Failed to upload icon'));
+  return body;
+}
+
+async function apiDeleteIcon(id) {
+  const res = await apiFetch(`/api/v1/accounts/${encodeURIComponent(id)}/icon`, { method: 'DELETE' });
+  const body = await res.json();
+  if (!res.ok) throw new Error(apiErrorMessage(body, 'Failed to remove icon'));
+  return body;
+}
+
+async function apiUsage(id, action) {
+  const res = await apiFetch(`/api/v1/accounts/${encodeURIComponent(id)}/${action}`, { method: 'POST' });
+  const body = await res.json();
+  if (!res.ok) throw new Error(apiErrorMessage(body, 'Failed to record use'));
+  return body;
+}
+
+async function apiBatch(operations) {
+  const res = await apiFetch('/api/v1/accounts/batch', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ operations }),
+  });
+  const body = await res.json();
+  if (!res.ok) {
+    const failed = (body.results || []).find(r => r.status === 'failed');
+    const message = apiErrorMessage(body, 'Batch update failed');
+    throw new Error(failed ? `${message}: ${failed.error}` : message);
+  }
+  return body;
+}
+
+async function fetchHealth() {
+  const res = await apiFetch('/api/v1/health');
+  if (!res.ok) throw new Error('Failed to fetch health');
+  return await res.json();
+}
+
+/* ══════════════════ RENDER ══════════════════ */
+function renderApp() {
+  const app = document.getElementById('app');
+  applyPrivacyMode();
+  applyThemeMode();
+  app.innerHTML = `
+    <header class="header">
+      <div class="header-brand">
+        <div class="header-logo">${ICONS.shield}</div>
+        <div>
+          <div class="header-title">TrustPIN</div>
+          <div class="header-subtitle">Secure TOTP Workspace</div>
+        </div>
+      </div>
+      <div class="search-wrap">
+        <span class="search-icon">${ICONS.search}</span>
+        <input class="search-input" id="search-input" placeholder="Search accounts..." value="${escapeHtml(searchTerm)}">
+        <span class="search-kbd">${navigator.platform.includes('Mac') ? '⌘' : 'Ctrl'}K</span>
+      </div>
+      <div class="header-actions">
+        <span class="live-dot"></span>
+        <button class="btn btn-primary" data-action="open-add-modal">
+          ${ICONS.plus}
+          <span class="desktop">Add Account</span>
+        </button>
+        <button class="btn btn-ghost" data-action="open-health-panel">
+          ${ICONS.heart}
+          <span class="desktop">Health</span>
+        </button>
+        <button class="btn btn-ghost ${showArchived ? 'active' : ''}" data-action="toggle-archived" title="${showArchived ? 'Viewing archived accounts' : 'View archived accounts'}">
+          ${ICONS.archive}
+          <span class="desktop">${showArchived ? 'Active' : 'Archive'}</span>
+        </button>
+        <button class="btn btn-ghost" data-action="lock" title="Lock the dashboard now">
+          ${ICONS.lock}
+          <span class="desktop">Lock</span>
+        </button>
+        <button class="btn btn-ghost theme-toggle" data-action="toggle-theme" title="${darkMode ? 'Switch to light theme' : 'Switch to dark theme'}">
+          ${darkMode ? ICONS.sun : ICONS.moon}
+        </button>
+      </div>
+    </header>
+    <div class="stats-bar" id="stats-bar"></div>
+    <div class="toolbar" id="toolbar"></div>
+    <div class="grid" id="grid"></div>
+  `;
+  document.getElementById('search-input').addEventListener('input', e => {
+    searchTerm = e.target.value;
+    clearTimeout(searchTimer);
+    searchTimer = setTimeout(() => { lastAccountKeys = ''; refresh(); }, 120);
+  });
+}
+
+function updateStats() {
+  const active = accounts.filter(a => !a.archived);
+  const archived = accounts.filter(a => a.archived);
+  const total = active.length;
+  const expiring = active.filter(a => !a.errorText && a.timeRemaining <= 5 && a.type !== 'hotp').length;
+  const issuers = new Set(active.map(a => a.issuer || 'Standalone')).size;
+  const danger = active.filter(a => a.errorText).length;
+  const favorites = active.filter(a => a.favorite).length;
+
+  document.getElementById('stats-bar').innerHTML = `
+    <div class="stat-chip stat-chip--accent"><span class="dot"></span>${total} account${total !== 1 ? 's' : ''}</div>
+    ${favorites > 0 ? `<div class="stat-chip stat-chip--warning"><span class="dot"></span>${favorites} favorite${favorites !== 1 ? 's' : ''}</div>` : ''}
+    <div class="stat-chip stat-chip--${expiring > 0 ? 'warning' : 'success'}"><span class="dot"></span>${expiring} expiring</div>
+    <div class="stat-chip stat-chip--accent"><span class="dot"></span>${issuers} group${issuers !== 1 ? 's' : ''}</div>
+    ${danger > 0 ? `<div class="stat-chip stat-chip--danger"><span class="dot"></span>${danger} issue${danger !== 1 ? 's' : ''}</div>` : ''}
+    ${archived.length > 0 ? `<div class="stat-chip stat-chip--accent"><span class="dot"></span>${archived.length} archived</div>` : ''}
+  `;
+}
+
+function updateToolbar() {
+  const filtered = filterAndSort(accounts);
+  const toolbar = document.getElementById('toolbar');
+
+  /* First render: build the full toolbar DOM */
+  if (!toolbar.querySelector('.toolbar-label')) {
+    toolbar.innerHTML = `
+      <div class="toolbar-label"></div>
+      <div class="toolbar-right">
+        <button class="btn btn-ghost" id="select-btn" data-action="toggle-selection"></button>
+        <button class="btn btn-ghost privacy-toggle" id="privacy-btn" data-action="toggle-privacy"></button>
+        <select class="sort-select" id="sort-select" data-change="sort">
+          <option value="expiry">Sort by Expiry</option>
+          <option value="name">Sort by Name</option>
+          <option value="issuer">Sort by Issuer</option>
+          <option value="digits">Sort by Digits</option>
+          <option value="recent">Recently Used</option>
+          <option value="added">Recently Added</option>
+          <option value="custom">Custom Order</option>
+        </select>
+        <select class="sort-select" id="group-select" data-change="group-by">
+          <option value="">No Grouping</option>
+          <option value="issuer">Group by Issuer</option>
+          <option value="tag">Group by Tag</option>
+          <option value="type">Group by Type</option>
+        </select>
+      </div>
+      <div class="bulk-bar" id="bulk-bar"></div>
+    `;
+  }
+
+  /* Update label text */
+  const viewLabel = showArchived ? 'archived' : 'accounts';
+  toolbar.querySelector('.toolbar-label').textContent = `Showing ${filtered.length} ${viewLabel}`;
+
+  /* Update privacy button without replacing the select */
+  const privBtn = document.getElementById('privacy-btn');
+  privBtn.className = `btn btn-ghost privacy-toggle ${privacyMode ? 'active' : ''}`;
+  privBtn.title = privacyMode ? 'Privacy mode is on. Hover a card to reveal its code.' : 'Privacy mode is off. Codes stay visible.';
+  privBtn.innerHTML = `${privacyMode ? ICONS.eyeOff : ICONS.eye}<span>${privacyMode ? 'Privacy On' : 'Privacy Off'}</span>`;
+
+  const selectBtn = document.getElementById('select-btn');
+  selectBtn.className = `btn btn-ghost ${selectionMode ? 'active' : ''}`;
+  selectBtn.textContent = selectionMode ? 'Done' : 'Select';
+  updateBulkBar();
+
+  /* Sync select value without rebuilding it */
+  const sel = document.getElementById('sort-select');
+  if (sel && sel.value !== sortBy) sel.value = sortBy;
+  const groupSel = document.getElementById('group-select');
+  if (groupSel && groupSel.value !== groupBy) groupSel.value = groupBy;
+}
+
+function updateGrid() {
+  const filtered = filterAndSort(accounts);
+  const grid = document.getElementById('grid');
+
+  const groups = visibleGroups();
+  grid.classList.remove('grid--single', 'grid--few', 'grid--grouped');
+  if (groups) grid.classList.add('grid--grouped');
+  else if (filtered.length === 1) grid.classList.add('grid--single');
+  else if (filtered.length <= 3) grid.classList.add('grid--few');
+
+  if (accounts.length === 0 && !searchTerm.trim()) {
+    grid.innerHTML = renderEmptyState();
+    updateToolbar();
+    return;
+  }
+
+  if (filtered.length === 0) {
+    if (showArchived) {
+      grid.innerHTML = `
+        <div class="empty-state">
+          <div class="empty-icon">${ICONS.archive}</div>
+          <div class="empty-title">No archived accounts</div>
+          <div class="empty-desc">Archived accounts will appear here. You can archive accounts from their card actions.</div>
+          <button class="btn btn-primary" data-action="toggle-archived">${ICONS.restore} Back to Active</button>
+        </div>`;
+    } else {
+      grid.innerHTML = `
+        <div class="empty-state">
+          <div class="empty-icon">${ICONS.search}</div>
+          <div class="empty-title">No matches</div>
+          <div class="empty-desc">No accounts match your current search. Try a different term.</div>
+        </div>`;
+    }
+    updateToolbar();
+    return;
+  }
+
+  grid.innerHTML = groups
+    ? groups.map(renderGroup).join('')
+    : filtered.map((a, i) => renderCard(a, i)).join('');
+  updateToolbar();
+
+  /* Entry animation on first render only */
+  if (isFirstRender) {
+    grid.querySelectorAll('.card').forEach(c => c.classList.add('card--enter'));
+    isFirstRender = false;
+  }
+
+  accounts.forEach(a => prevOTPs[a.name] = a.otp);
+}
+
+function updateCardsInPlace(filtered) {
+  /* With tag grouping an account can be drawn once per tag */
+  filtered.forEach(a => document.querySelectorAll(`[data-account="${CSS.escape(a.name)}"]`).forEach(card => {
+
+    /* OTP code */
+    const otpEl = card.querySelector('.otp-code');
+    if (otpEl) {
+      const prev = otpEl.textContent;
+      if (prev !== a.formattedOTP) {
+        otpEl.textContent = a.formattedOTP;
+        otpEl.classList.add('flash');
+        setTimeout(() => otpEl.classList.remove('flash'), 400);
+      }
+    }
+
+    /* Timer text */
+    const timerText = card.querySelector('.timer-text');
+    if (timerText) timerText.textContent = a.errorText ? '--' : a.timeRemaining + 's';
+
+    /* Timer ring angle */
+    const ring = card.querySelector('.timer-ring');
+    if (ring) ring.style.setProperty('--ring-angle', ((100 - a.progressPercent) * 3.6) + 'deg');
+
+    /* Progress bar */
+    const fill = card.querySelector('.progress-fill');
+    if (fill) fill.style.width = a.progressPercent + '%';
+
+    /* Status badge */
+    const statusBadge = card.querySelector('.status-badge');
+    if (statusBadge) statusBadge.className = `status-badge status--${a.tone}`;
+    const statusLabel = card.querySelector('.status-label');
+    if (statusLabel) statusLabel.textContent = a.statusLabel;
+
+    /* Usage dates */
+    const datesEl = card.querySelector('.card-dates');
+    if (datesEl) datesEl.outerHTML = renderCardDates(a);
+
+    /* Code timeline */
+    const timelineEl = card.querySelector('.code-timeline');
+    if (timelineEl) timelineEl.outerHTML = renderTimeline(a);
+
+    /* Card tone class */
+    card.className = card.className.replace(/card--\w+/, `card--${a.tone}`);
+  }));
+
+  accounts.forEach(a => prevOTPs[a.name] = a.otp);
+}
+
+// renderCardDates shows when an account was added and last used; the
+// title carries the full timestamps.
+function renderCardDates(a) {
+  const added = a.createdAt ? `Added ${relativeTime(a.createdAt)}` : '';
+  const used = a.lastUsedAt ? `Used ${relativeTime(a.lastUsedAt)}` : 'Never used';
+  const title = [
+    a.createdAt && `Added ${new Date(a.createdAt).toLocaleString()}`,
+    a.updatedAt && `Edited ${new Date(a.updatedAt).toLocaleString()}`,
+    a.lastUsedAt && `Used ${new Date(a.lastUsedAt).toLocaleString()}`,
+  ].filter(Boolean).join('\n');
+  return `<div class="card-dates" title="${escapeHtml(title)}">${[added, used].filter(Boolean).map(escapeHtml).join(' &middot; ')}</div>`;
+}
+
+// renderTimeline shows the current and next codes with the windows they
+// are valid in, so the next code can be typed ahead as the current one
+// runs out. The next code is highlighted for the last five seconds.
+function renderTimeline(a) {
+  if (a.archived || a.errorText || !a.nextOTP) return '';
+  const nextStart = new Date(a.nextWindowStart);
+  const currentStart = new Date(nextStart.getTime() - a.interval * 1000);
+  const nextEnd = new Date(nextStart.getTime() + a.interval * 1000);
+  return `
+    <div class="code-timeline">
+      <div class="timeline-row">
+        <span class="timeline-label">Now</span>
+        <span class="timeline-code">${escapeHtml(a.formattedOTP)}</span>
+        <span class="timeline-span">${clockTime(currentStart)} – ${clockTime(nextStart)}</span>
+      </div>
+      <div class="timeline-row timeline-row--next${a.timeRemaining <= 5 ? ' timeline-row--soon' : ''}">
+        <span class="timeline-label">Next</span>
+        <span class="timeline-code" data-action="copy-next-otp" title="Click to copy the next code">${escapeHtml(a.nextFormattedOTP)}</span>
+        <span class="timeline-span">${clockTime(nextStart)} – ${clockTime(nextEnd)} &middot; in ${a.timeRemaining}s</span>
+      </div>
+    </div>`;
+}
+
+function clockTime(date) {
+  return date.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit', second: '2-digit' });
+}
+
+function relativeTime(value) {
+  const seconds = Math.max(0, (Date.now() - new Date(value).getTime()) / 1000);
+  if (seconds < 60) return 'just now';
+  const units = [['year', 31536000], ['month', 2592000], ['day', 86400], ['hour', 3600], ['minute', 60]];
+  for (const [unit, size] of units) {
+    const n = Math.floor(seconds / size);
+    if (n >= 1) return `${n} ${unit}${n !== 1 ? 's' : ''} ago`;
+  }
+  return 'just now';
+}
+
+function renderCard(a, index) {
+  const toneClass = a.tone || 'accent';
+  const remainPct = 100 - a.progressPercent;
+  const angle = remainPct * 3.6;
+  const iconHtml = issuerIconHtml(a);
+  const tagsHtml = (a.tags && a.tags.length > 0)
+    ? `<div class="card-tags">${a.tags.map(t => `<span class="tag-badge">${escapeHtml(t)}</span>`).join('')}</div>`
+    : '';
+  const notesHtml = a.notes
+    ? `<div class="card-notes" title="${escapeHtml(a.notes)}">${ICONS.note} ${escapeHtml(a.notes)}</div>`
+    : '';
+  const recoveryHtml = a.recoveryCodes
+    ? `<div class="card-notes" title="Manage with trustpin recovery">${ICONS.shield} ${a.recoveryUnused || 0} of ${a.recoveryCodes} recovery codes unused</div>`
+    : '';
+  const selectHtml = selectionMode
+    ? `<input type="checkbox" class="card-select" ${selectedIds.has(a.id) ? 'checked' : ''} data-action="toggle-selected" title="Select account">`
+    : '';
+  const selectedClass = selectionMode && selectedIds.has(a.id) ? ' card--selected' : '';
+  const timelineHtml = renderTimeline(a);
+  const detailsHtml = timelineHtml
+    ? `<details class="card-details"${openDetails.has(a.id) ? ' open' : ''}><summary>Code timeline</summary>${timelineHtml}</details>`
+    : '';
+
+  /* Archived cards: no live OTP, no timer, no progress */
+  if (a.archived) {
+    return `
+      <article class="card card--${toneClass} card--archived${selectedClass}" data-account="${escapeHtml(a.name)}" data-id="${escapeHtml(a.id)}" style="animation-delay: ${index * 0.04}s">
+        <div class="card-header">
+          <span class="issuer-badge"${brandStyle(a)}>${selectHtml}${iconHtml} ${escapeHtml(a.issuer || 'Standalone')}</span>
+          <span class="status-badge status--accent">
+            <span class="status-dot"></span>
+            <span class="status-label">${ICONS.archive} Archived</span>
+          </span>
+        </div>
+        ${tagsHtml}
+        <div class="card-name" title="${escapeHtml(a.label || a.name)}">${escapeHtml(a.label || a.name)}</div>
+        <div class="card-body" style="justify-content:center">
+          <span style="color:var(--text-muted);font-size:13px">OTP paused while archived</span>
+        </div>
+        ${notesHtml}
+        ${recoveryHtml}
+        ${renderCardDates(a)}
+        <div class="card-footer">
+          <span class="card-policy">${escapeHtml(a.policyLabel)} &middot; ${escapeHtml(a.secretPreview)}</span>
+          <div class="card-actions">
+            <button class="action-btn action-btn--edit" data-action="restore" title="Restore">${ICONS.restore}</button>
+            <button class="action-btn action-btn--danger" data-action="delete" title="Delete">${ICONS.trash}</button>
+          </div>
+        </div>
+      </article>`;
+  }
+
+  const timerHtml = a.type === 'hotp'
+    ? `<button class="timer-text timer-next" data-action="next-hotp" title="Generate the next code">C:${a.counter}</button>`
+    : `<span class="timer-text">${a.errorText ? '--' : a.timeRemaining + 's'}</span>`;
+  const progressHtml = a.type !== 'hotp'
+    ? `<div class="progress-track"><div class="progress-fill" style="width: ${a.progressPercent}%"></div></div>`
+    : '';
+
+  return `
+    <article class="card card--${toneClass}${a.archived ? ' card--archived' : ''}${selectedClass}" data-account="${escapeHtml(a.name)}" data-id="${escapeHtml(a.id)}"${groupBy ? '' : ' draggable="true"'} style="animation-delay: ${index * 0.04}s">
+      <div class="card-header">
+        <span class="issuer-badge"${brandStyle(a)}>${selectHtml}${iconHtml} ${escapeHtml(a.issuer || 'Standalone')}</span>
+        <div style="display:flex;align-items:center;gap:4px">
+          <button class="fav-btn ${a.favorite ? 'active' : ''}" data-action="toggle-favorite" title="${a.favorite ? 'Remove from favorites' : 'Add to favorites'}">
+            ${a.favorite ? ICONS.starFill : ICONS.star}
+          </button>
+          <span class="status-badge status--${toneClass}">
+            <span class="status-dot"></span>
+            <span class="status-label">${escapeHtml(a.statusLabel)}</span>
+          </span>
+        </div>
+      </div>
+      ${tagsHtml}
+      <div class="card-name" title="${escapeHtml(a.label || a.name)}">${escapeHtml(a.label || a.name)}</div>
+      <div class="card-body">
+        <div class="otp-wrap">
+          <span class="otp-code" data-action="copy-otp" title="Click to copy">${escapeHtml(a.formattedOTP)}</span>
+          <button class="copy-btn" data-action="copy-otp" title="Copy to clipboard">${ICONS.copy}</button>
+        </div>
+        <div class="timer-ring" style="--ring-angle: ${angle}deg">
+          <div class="timer-ring-inner">
+            ${timerHtml}
+          </div>
+        </div>
+      </div>
+      ${progressHtml}
+      ${notesHtml}
+      ${recoveryHtml}
+      ${renderCardDates(a)}
+      ${detailsHtml}
+      <div class="card-footer">
+        <span class="card-policy">${escapeHtml(a.policyLabel)} &middot; ${escapeHtml(a.secretPreview)}</span>
+        <div class="card-actions">
+          <button class="action-btn action-btn--edit" data-action="show-qr" title="Show QR">${ICONS.qr}</button>
+          <button class="action-btn action-btn--edit" data-action="edit" title="Edit">${ICONS.edit}</button>
+          <button class="action-btn action-btn--copy" data-action="copy-otp" title="Copy">${ICONS.copy}</button>
+          ${a.archived
+            ? `<button class="action-btn action-btn--edit" data-action="restore" title="Restore">${ICONS.restore}</button>`
+            : `<button class="action-btn action-btn--edit" data-action="archive" title="Archive">${ICONS.archive}</button>`
+          }
+          <button class="action-btn action-btn--danger" data-action="delete" title="Delete">${ICONS.trash}</button>
+        </div>
+      </div>
+      ${a.errorText ? `<div style="margin-top:8px;font-size:11px;color:var(--danger);font-weight:500">${escapeHtml(a.errorText)}</div>` : ''}
+    </article>`;
+}
+
+function renderEmptyState() {
+  return `
+    <div class="empty-state">
+      <div class="empty-icon">${ICONS.shield}</div>
+      <div class="empty-title">No accounts yet</div>
+      <div class="empty-desc">
+        Get started by adding your first TOTP account. You can add accounts manually or import them using the CLI with <code style="background:rgba(255,255,255,0.06);padding:2px 6px;border-radius:4px;font-size:12px">trustPIN add --qr-file image.png</code>
+      </div>
+      <button class="btn btn-primary" data-action="open-add-modal">${ICONS.plus} Add First Account</button>
+    </div>`;
+}
+
+/* ══════════════════ HEALTH PANEL ══════════════════ */
+async function openHealthPanel() {
+  document.getElementById('health-panel').classList.add('open');
+  document.getElementById('health-body').innerHTML = '<div style="color:var(--text-muted);text-align:center;padding:40px 0">Loading...</div>';
+  try {
+    const data = await fetchHealth();
+    renderHealthBody(data);
+  } catch (e) {
+    document.getElementById('health-body').innerHTML = `<div style="color:var(--danger);text-align:center;padding:40px 0">Failed to load health data.</div>`;
+  }
+}
+
+function renderHealthBody(data) {
+  const body = document.getElementById('health-body');
+  let html = `
+    <div class="health-summary">
+      <div class="stat-chip stat-chip--danger"><span class="dot"></span>${data.summary.critical} critical</div>
+      <div class="stat-chip stat-chip--warning"><span class="dot"></span>${data.summary.warning} warnings</div>
+      <div class="stat-chip stat-chip--accent"><span class="dot"></span>${data.summary.info} info</div>
+      <div class="stat-chip stat-chip--accent"><span class="dot"></span>${data.total} accounts</div>
+    </div>`;
+
+  if (!data.items || data.items.length === 0) {
+    html += `
+      <div class="health-clean">
+        <div class="health-clean-icon">&#10003;</div>
+        <div class="health-clean-title">All clear</div>
+        <div class="health-clean-desc">No issues detected. Your workspace is healthy.</div>
+      </div>`;
+  } else {
+    data.items.forEach(item => {
+      html += `
+        <div class="health-item">
+          <div class="health-item-header">
+            <span class="health-level health-level--${item.level}">${item.level}</span>
+            <span class="health-item-title">${escapeHtml(item.title)}</span>
+          </div>
+          <div class="health-item-detail">${escapeHtml(item.detail)}</div>
+          ${item.remediation ? `<div class="health-item-fix">${escapeHtml(item.remediation)}</div>` : ''}
+        </div>`;
+    });
+  }
+  body.innerHTML = html;
+}
+
+function closeHealthPanel() { document.getElementById('health-panel').classList.remove('open'); }
+
+/* ══════════════════ ADD MODAL ══════════════════ */
+let selectedQRFile = null;
+
+function openAddModal() {
+  prepareAccountModalForAdd();
+  document.getElementById('add-modal').classList.add('open');
+  setTimeout(() => document.getElementById('add-name').focus(), 200);
+}
+
+function closeAddModal() { document.getElementById('add-modal').classList.remove('open'); }
+
+function findAccountById(id) {
+  return accounts.find(a => a.id === id);
+}
+
+function openEditModal(id) {
+  const account = findAccountById(id);
+  if (!account) {
+    showToast('Account not found', 'error');
+    return;
+  }
+
+  prepareAccountModalForEdit(account);
+  document.getElementById('add-modal').classList.add('open');
+  setTimeout(() => document.getElementById('add-name').focus(), 200);
+}
+
+function prepareAccountModalForAdd() {
+  accountModalMode = 'add';
+  editingAccountId = null;
+  resetAccountForm();
+  setAdvancedOpen(false);
+  document.getElementById('account-modal-title').textContent = 'Add Account';
+  document.getElementById('account-modal-meta').textContent = 'Manual entry or QR import for a new TOTP account.';
+  document.getElementById('account-name-hint').textContent = 'Use Issuer:Label format for grouping (e.g. AWS:staging)';
+  document.getElementById('account-secret-hint').textContent = 'Base32 or Base64 encoded secret from your provider';
+  document.getElementById('account-modal-tabs').classList.remove('hidden');
+  document.getElementById('add-secret').required = true;
+  document.getElementById('add-secret').placeholder = 'JBSWY3DPEHPK3PXP';
+  document.getElementById('add-submit-btn').innerHTML = renderAccountSubmitButton();
+}
+
+function prepareAccountModalForEdit(account) {
+  accountModalMode = 'edit';
+  editingAccountId = account.id;
+  resetAccountForm();
+  document.getElementById('account-modal-title').textContent = 'Edit Account';
+  document.getElementById('account-modal-meta').textContent = 'Update the account label, OTP policy, or replace the secret if it has changed.';
+  document.getElementById('account-name-hint').textContent = 'Rename the full account path if needed. Issuer:Label still gives the best grouping.';
+  document.getElementById('account-secret-hint').textContent = `Leave blank to keep the current secret (${account.secretPreview}). Enter a new secret only if you want to replace it.`;
+  document.getElementById('account-modal-tabs').classList.add('hidden');
+  document.getElementById('add-name').value = account.name;
+  document.getElementById('add-secret').required = false;
+  document.getElementById('add-secret').placeholder = 'Leave blank to keep current secret';
+  document.getElementById('add-interval').value = account.interval || 30;
+  document.getElementById('add-digits').value = String(account.digits || 6);
+  document.getElementById('add-algorithm').value = account.algorithm || 'SHA1';
+  document.getElementById('add-encoding').value = account.encoding || 'auto';
+  document.getElementById('add-type').value = account.type || 'totp';
+  document.getElementById('add-counter').value = String(account.counter || 0);
+  document.getElementById('add-tags').value = (account.tags || []).join(', ');
+  document.getElementById('add-notes').value = account.notes || '';
+  document.getElementById('add-color').value = account.brandColor || '#64748b';
+  document.getElementById('add-color-custom').checked = Boolean(account.color);
+  document.getElementById('icon-group').style.display = '';
+  document.getElementById('add-icon-clear').checked = false;
+  document.getElementById('add-icon-clear').disabled = !account.customIcon;
+  handleTypeChange(account.type || 'totp');
+  document.getElementById('add-submit-btn').innerHTML = renderAccountSubmitButton();
+  switchTab('manual');
+  setAdvancedOpen(true);
+}
+
+function resetAccountForm() {
+  document.getElementById('add-form').reset();
+  document.getElementById('add-name').value = '';
+  document.getElementById('add-secret').value = '';
+  document.getElementById('add-interval').value = '30';
+  document.getElementById('add-digits').value = '6';
+  document.getElementById('add-algorithm').value = 'SHA1';
+  document.getElementById('add-encoding').value = 'auto';
+  document.getElementById('add-type').value = 'totp';
+  document.getElementById('add-counter').value = '0';
+  document.getElementById('add-tags').value = '';
+  document.getElementById('add-notes').value = '';
+  document.getElementById('add-color').value = '#64748b';
+  document.getElementById('add-color-custom').checked = false;
+  document.getElementById('add-icon').value = '';
+  document.getElementById('icon-group').style.display = 'none';
+  document.getElementById('add-error').classList.remove('show');
+  document.getElementById('add-error').textContent = '';
+  document.getElementById('qr-error').classList.remove('show');
+  document.getElementById('qr-error').textContent = '';
+  document.getElementById('counter-group').style.display = 'none';
+  clearQRFile();
+  switchTab('manual');
+}
+
+function switchTab(tab) {
+  document.querySelectorAll('.modal-tab').forEach(t => t.classList.remove('active'));
+  document.querySelectorAll('.tab-content').forEach(t => t.classList.remove('active'));
+  document.querySelector(`.modal-tab[data-tab="${tab}"]`).classList.add('active');
+  document.querySelector(`.tab-content[data-tab="${tab}"]`).classList.add('active');
+}
+
+function setAdvancedOpen(open) {
+  const toggle = document.getElementById('adv-toggle');
+  const content = document.getElementById('adv-content');
+  toggle.classList.toggle('open', open);
+  content.classList.toggle('open', open);
+}
+
+function toggleAdvanced() {
+  const toggle = document.getElementById('adv-toggle');
+  setAdvancedOpen(!toggle.classList.contains('open'));
+}
+
+async function handleAddSubmit(e) {
+  e.preventDefault();
+  const name = document.getElementById('add-name').value.trim();
+  const secret = document.getElementById('add-secret').value.trim();
+  const interval = parseInt(document.getElementById('add-interval').value) || 30;
+  const digits = parseInt(document.getElementById('add-digits').value) || 6;
+  const algorithm = document.getElementById('add-algorithm').value || 'SHA1';
+  const encoding = document.getElementById('add-encoding').value || 'auto';
+  const otpType = document.getElementById('add-type').value || 'totp';
+  const counter = parseInt(document.getElementById('add-counter').value) || 0;
+  const tagsRaw = document.getElementById('add-tags').value.trim();
+  const tags = tagsRaw ? tagsRaw.split(',').map(t => t.trim()).filter(Boolean) : [];
+  const notes = document.getElementById('add-notes').value.trim();
+  const color = document.getElementById('add-color-custom').checked ? document.getElementById('add-color').value : '';
+  const iconFile = document.getElementById('add-icon').files[0];
+  const clearIcon = document.getElementById('add-icon-clear').checked;
+  const errEl = document.getElementById('add-error');
+  const btn = document.getElementById('add-submit-btn');
+  errEl.classList.remove('show');
+  errEl.textContent = '';
+
+  if (!name || (accountModalMode === 'add' && !secret)) {
+    errEl.textContent = accountModalMode === 'edit'
+      ? 'Account name is required. Leave the secret blank to keep the current value.'
+      : 'Name and secret are required.';
+    errEl.classList.add('show');
+    return;
+  }
+
+  btn.disabled = true;
+  btn.textContent = accountModalMode === 'edit' ? 'Saving...' : 'Adding...';
+  try {
+    const payload = { name, secret, interval, digits, algorithm, encoding, type: otpType, counter, tags, notes, color };
+    if (accountModalMode === 'edit') {
+      const existing = findAccountById(editingAccountId);
+      if (existing) {
+        payload.favorite = existing.favorite || false;
+        payload.sortOrder = existing.sortOrder || 0;
+        payload.archived = existing.archived || false;
+      }
+      await apiUpdateAccount(editingAccountId, payload);
+      if (iconFile) await apiSetIcon(editingAccountId, iconFile);
+      else if (clearIcon) await apiDeleteIcon(editingAccountId);
+    } else {
+      await apiAddAccount(payload);
+    }
+    closeAddModal();
+    showToast(accountModalMode === 'edit' ? 'Account updated successfully' : 'Account added successfully', 'success');
+    lastAccountKeys = '';
+    await refresh();
+  } catch (err) {
+    errEl.textContent = err.message;
+    errEl.classList.add('show');
+  } finally {
+    btn.disabled = false;
+    btn.innerHTML = renderAccountSubmitButton();
+  }
+}
+
+/* ══════════════════ QR IMPORT ══════════════════ */
+function setupDropzone() {
+  const zone = document.getElementById('qr-dropzone');
+  const input = document.getElementById('qr-file-input');
+
+  zone.addEventListener('dragover', e => { e.preventDefault(); zone.classList.add('dragover'); });
+  zone.addEventListener('dragleave', () => zone.classList.remove('dragover'));
+  zone.addEventListener('drop', e => {
+    e.preventDefault();
+    zone.classList.remove('dragover');
+    if (e.dataTransfer.files.length > 0) selectQRFile(e.dataTransfer.files[0]);
+  });
+  input.addEventListener('change', () => {
+    if (input.files.length > 0) selectQRFile(input.files[0]);
+  });
+}
+
+function selectQRFile(file) {
+  if (!file.type.startsWith('image/')) {
+    showToast('Please select an image file', 'error');
+    return;
+  }
+  selectedQRFile = file;
+  resetImportPlan();
+  document.getElementById('qr-filename').textContent = file.name;
+  document.getElementById('qr-preview').classList.add('show');
+  document.getElementById('qr-error').classList.remove('show');
+}
+
+function clearQRFile() {
+  selectedQRFile = null;
+  resetImportPlan();
+  document.getElementById('qr-file-input').value = '';
+  document.getElementById('qr-preview').classList.remove('show');
+  document.getElementById('qr-filename').textContent = '';
+}
+
+let importPlanReady = false;
+const IMPORT_BUTTON_HTML = `<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2"><path d="M21 15v4a2 2 0 0 1-2 2H5a2 2 0 0 1-2-2v-4M17 8l-5-5-5 5M12 3v12"/></svg> Import from QR`;
+
+function resetImportPlan() {
+  importPlanReady = false;
+  const plan = document.getElementById('qr-plan');
+  plan.classList.remove('show');
+  plan.innerHTML = '';
+  document.getElementById('qr-import-btn').innerHTML = IMPORT_BUTTON_HTML;
+}
+
+function renderImportPlan(data) {
+  const counts = [`${data.added} added`, `${data.replaced} replaced`];
+  if (data.merged > 0) counts.push(`${data.merged} merged`);
+  if (data.kept > 0) counts.push(`${data.kept} kept`);
+  if (data.skipped > 0) counts.push(`${data.skipped} skipped`);
+  const changes = (data.changes || []).map(change => {
+    const note = change.previous ? ` <span class="import-plan-field">(was ${escapeHtml(change.previous)})</span>` : '';
+    const fields = (change.fields || []).map(f =>
+      `<div class="import-plan-field">${escapeHtml(f.field)}: ${escapeHtml(f.old || '—')} → ${escapeHtml(f.new || '—')}</div>`
+    ).join('');
+    return `<div class="import-plan-change"><span class="import-plan-action">${escapeHtml(change.action)}</span>${escapeHtml(change.name)}${note}${fields}</div>`;
+  }).join('');
+  const plan = document.getElementById('qr-plan');
+  plan.innerHTML = `<div class="import-plan-summary">Planned changes: ${counts.join(', ')}</div>${changes}`;
+  plan.classList.add('show');
+}
+
+async function handleQRImport() {
+  const errEl = document.getElementById('qr-error');
+  const btn = document.getElementById('qr-import-btn');
+
+  if (!selectedQRFile) {
+    errEl.textContent = 'Please select a QR code image first.';
+    errEl.classList.add('show');
+    return;
+  }
+
+  const dryRun = !importPlanReady;
+  btn.disabled = true;
+  btn.textContent = dryRun ? 'Checking...' : 'Importing...';
+  errEl.classList.remove('show');
+
+  try {
+    const form = new FormData();
+    form.append('qr', selectedQRFile);
+    form.append('strategy', document.getElementById('qr-strategy').value);
+    form.append('dryRun', String(dryRun));
+    const res = await apiFetch('/api/v1/accounts/import', { method: 'POST', body: form });
+    const data = await res.json();
+    if (!res.ok) throw new Error(apiErrorMessage(data, 'Import failed'));
+
+    if (dryRun) {
+      renderImportPlan(data);
+      importPlanReady = true;
+      btn.disabled = false;
+      btn.textContent = 'Confirm import';
+      return;
+    }
+
+    closeAddModal();
+    const parts = [];
+    if (data.added > 0) parts.push(`${data.added} added`);
+    if (data.replaced > 0) parts.push(`${data.replaced} replaced`);
+    if (data.merged > 0) parts.push(`${data.merged} merged`);
+    if (data.kept > 0) parts.push(`${data.kept} kept`);
+    if (data.skipped > 0) parts.push(`${data.skipped} skipped`);
+    showToast(`QR import: ${parts.join(', ')}`, 'success');
+    resetImportPlan();
+    lastAccountKeys = '';
+    await refresh();
+  } catch (err) {
+    errEl.textContent = err.message;
+    errEl.classList.add('show');
+    resetImportPlan();
+  } finally {
+    btn.disabled = false;
+  }
+}
+
+/* ══════════════════ DELETE ══════════════════ */
+function promptDelete(id) {
+  const account = findAccountById(id);
+  if (!account) return;
+  pendingDeleteId = id;
+  document.getElementById('confirm-desc').textContent = `Are you sure you want to delete "${account.name}"? This action cannot be undone.`;
+  document.getElementById('confirm-overlay').classList.add('open');
+}
+
+function closeConfirm() {
+  pendingDeleteId = null;
+  pendingBulkDelete = null;
+  document.getElementById('confirm-overlay').classList.remove('open');
+}
+
+async function confirmDelete() {
+  if (pendingBulkDelete) {
+    const ids = pendingBulkDelete;
+    closeConfirm();
+    await runBulk(ids.map(id => ({ op: 'delete', id })), `${ids.length} account${ids.length !== 1 ? 's' : ''} deleted`);
+    return;
+  }
+  if (!pendingDeleteId) return;
+  const account = findAccountById(pendingDeleteId);
+  const name = account ? account.name : 'Account';
+  const id = pendingDeleteId;
+  closeConfirm();
+  try {
+    await apiDeleteAccount(id);
+    showToast(`"${name}" deleted`, 'success');
+    await refresh();
+  } catch (err) {
+    showToast(err.message, 'error');
+  }
+}
+
+async function archiveAccount(id) {
+  try {
+    const result = await apiSetArchived(id, true);
+    showToast(`"${result.name}" archived`, 'success');
+    lastAccountKeys = '';
+    await refresh();
+  } catch (err) {
+    showToast(err.message, 'error');
+  }
+}
+
+async function restoreAccount(id) {
+  try {
+    const result = await apiSetArchived(id, false);
+    showToast(`"${result.name}" restored`, 'success');
+    lastAccountKeys = '';
+    await refresh();
+  } catch (err) {
+    showToast(err.message, 'error');
+  }
+}
+
+function toggleArchivedView() {
+  showArchived = !showArchived;
+  selectedIds.clear();
+  lastAccountKeys = '';
+  isFirstRender = true;
+  renderApp();
+  updateGrid();
+  updateStats();
+  updateToolbar();
+}
+
+/* ══════════════════ BULK ACTIONS ══════════════════ */
+function toggleSelectionMode() {
+  selectionMode = !selectionMode;
+  selectedIds.clear();
+  lastAccountKeys = '';
+  updateGrid();
+}
+
+function toggleSelected(id) {
+  if (selectedIds.has(id)) selectedIds.delete(id);
+  else selectedIds.add(id);
+  const card = document.querySelector(`[data-id="${CSS.escape(id)}"]`);
+  if (card) card.classList.toggle('card--selected', selectedIds.has(id));
+  updateBulkBar();
+}
+
+function selectAllVisible() {
+  const visible = filterAndSort(accounts);
+  const allSelected = visible.every(a => selectedIds.has(a.id));
+  visible.forEach(a => allSelected ? selectedIds.delete(a.id) : selectedIds.add(a.id));
+  lastAccountKeys = '';
+  updateGrid();
+}
+
+function updateBulkBar() {
+  const bar = document.getElementById('bulk-bar');
+  if (!bar) return;
+  if (!selectionMode) {
+    bar.classList.remove('show');
+    bar.innerHTML = '';
+    return;
+  }
+
+  const known = new Set(accounts.map(a => a.id));
+  selectedIds.forEach(id => { if (!known.has(id)) selectedIds.delete(id); });
+  const count = selectedIds.size;
+  const disabled = count === 0 ? 'disabled' : '';
+  bar.innerHTML = `
+    <span class="bulk-count">${count} selected</span>
+    <button class="btn btn-ghost" data-action="select-all">Select all</button>
+    <button class="btn btn-ghost" data-action="bulk-favorite" data-favorite="true" ${disabled}>${ICONS.star} Favorite</button>
+    <button class="btn btn-ghost" data-action="bulk-favorite" data-favorite="false" ${disabled}>Unfavorite</button>
+    <button class="btn btn-ghost" data-action="bulk-tag" ${disabled}>Add tag</button>
+    ${showArchived
+      ? `<button class="btn btn-ghost" data-action="bulk-archive" data-archived="false" ${disabled}>${ICONS.restore} Restore</button>`
+      : `<button class="btn btn-ghost" data-action="bulk-archive" data-archived="true" ${disabled}>${ICONS.archive} Archive</button>`}
+    <button class="btn btn-danger" data-action="bulk-delete" ${disabled}>${ICONS.trash} Delete</button>
+  `;
+  bar.classList.add('show');
+}
+
+async function runBulk(operations, message) {
+  try {
+    await apiBatch(operations);
+    selectedIds.clear();
+    showToast(message, 'success');
+    lastAccountKeys = '';
+    await refresh();
+  } catch (err) {
+    showToast(err.message, 'error');
+  }
+}
+
+function bulkFavorite(favorite) {
+  const ids = [...selectedIds];
+  runBulk(ids.map(id => ({ op: 'set-favorite', id, favorite })),
+    `${ids.length} account${ids.length !== 1 ? 's' : ''} ${favorite ? 'added to' : 'removed from'} favorites`);
+}
+
+function bulkArchive(archived) {
+  const ids = [...selectedIds];
+  runBulk(ids.map(id => ({ op: 'archive', id, archived })),
+    `${ids.length} account${ids.length !== 1 ? 's' : ''} ${archived ? 'archived' : 'restored'}`);
+}
+
+function bulkAddTag() {
+  const tag = (window.prompt('Tag to add to the selected accounts') || '').trim();
+  if (!tag) return;
+  const operations = [...selectedIds].map(id => {
+    const tags = (findAccountById(id) || {}).tags || [];
+    return { op: 'set-tags', id, tags: [...tags, tag] };
+  });
+  runBulk(operations, `Tagged ${operations.length} account${operations.length !== 1 ? 's' : ''} "${tag}"`);
+}
+
+function bulkDelete() {
+  const ids = [...selectedIds];
+  if (ids.length === 0) return;
+  pendingBulkDelete = ids;
+  document.getElementById('confirm-desc').textContent = `Are you sure you want to delete ${ids.length} account${ids.length !== 1 ? 's' : ''}? This action cannot be undone.`;
+  document.getElementById('confirm-overlay').classList.add('open');
+}
+
+/* ══════════════════ CLIPBOARD (auto-clear after 30s) ══════════════════ */
+async function copyOTP(otp, el) {
+  if (!otp || otp === '--- ---') return false;
+  try {
+    await navigator.clipboard.writeText(otp.replace(/\s/g, ''));
+    showToast('OTP copied — clipboard auto-clears in 30s', 'success');
+    if (el) {
+      const btn = el.closest('.copy-btn') || el.parentElement.querySelector('.copy-btn');
+      if (btn) {
+        btn.innerHTML = ICONS.check;
+        btn.classList.add('copied');
+        setTimeout(() => { btn.innerHTML = ICONS.copy; btn.classList.remove('copied'); }, 1200);
+      }
+    }
+    // Auto-clear clipboard after 30 seconds
+    if (clipboardTimer) clearTimeout(clipboardTimer);
+    clipboardTimer = setTimeout(async () => {
+      try {
+        await navigator.clipboard.writeText('');
+        showToast('Clipboard cleared', 'info');
+      } catch (e) { /* ignore */ }
+      clipboardTimer = null;
+    }, 30000);
+    return true;
+  } catch (e) {
+    showToast('Failed to copy', 'error');
+    return false;
+  }
+}
+
+/* ══════════════════ USAGE ══════════════════ */
+/* Copies always count as a use. Reveals in privacy mode count at most once
+   a minute per account so hovering across the grid does not flood the store. */
+const REVEAL_THROTTLE_MS = 60000;
+const lastReveal = new Map();
+
+async function recordUse(id) {
+  try {
+    const updated = await apiUsage(id, 'use');
+    const account = findAccountById(id);
+    if (account) Object.assign(account, { lastUsedAt: updated.lastUsedAt, updatedAt: updated.updatedAt });
+  } catch (err) {
+    console.error('Record use error:', err);
+  }
+}
+
+function recordReveal(card) {
+  if (!privacyMode || card.classList.contains('card--archived')) return;
+  const id = card.dataset.id;
+  const now = Date.now();
+  if (now - (lastReveal.get(id) || 0) < REVEAL_THROTTLE_MS) return;
+  lastReveal.set(id, now);
+  recordUse(id);
+}
+
+async function nextHOTP(id) {
+  try {
+    const result = await apiUsage(id, 'next');
+    showToast(`"${result.name}" advanced to counter ${result.counter}`, 'success');
+    await refresh();
+  } catch (err) {
+    showToast(err.message, 'error');
+  }
+}
+
+/* ══════════════════ TOASTS ══════════════════ */
+function showToast(message, type = 'info') {
+  const container = document.getElementById('toasts');
+  const icons = { success: '&#10003;', error: '&#10007;', info: '&#8505;' };
+  const toast = document.createElement('div');
+  toast.className = `toast toast--${type}`;
+  toast.innerHTML = `<span class="toast-icon">${icons[type] || icons.info}</span><span>${escapeHtml(message)}</span>`;
+  container.appendChild(toast);
+  setTimeout(() => {
+    toast.classList.add('removing');
+    setTimeout(() => toast.remove(), 300);
+  }, 3000);
+}
+
+/* ══════════════════ FILTER / SORT ══════════════════ */
+function filterAndSort(list) {
+  let result = [...list];
+  // Filter by archive state
+  result = result.filter(a => showArchived ? a.archived : !a.archived);
+  result.sort((a, b) => {
+    // Search results are ranked by the server; its scores include a favorite boost
+    if (searchTerm.trim() && a.score !== b.score) return (b.score || 0) - (a.score || 0);
+    // Favorites first, and always in stable name order among themselves
+    if (a.favorite !== b.favorite) return a.favorite ? -1 : 1;
+    if (a.favorite && b.favorite) return a.name.localeCompare(b.name);
+    switch (sortBy) {
+      case 'name': return a.name.localeCompare(b.name);
+      case 'issuer': return (a.issuer || '').localeCompare(b.issuer || '') || a.name.localeCompare(b.name);
+      case 'digits': return a.digits - b.digits || a.name.localeCompare(b.name);
+      case 'recent': return (b.lastUsedAt || '').localeCompare(a.lastUsedAt || '') || a.name.localeCompare(b.name);
+      case 'added': return (b.createdAt || '').localeCompare(a.createdAt || '') || a.name.localeCompare(b.name);
+      case 'custom': return (a.sortOrder || 0) - (b.sortOrder || 0) || a.name.localeCompare(b.name);
+      default: return a.timeRemaining - b.timeRemaining || a.name.localeCompare(b.name);
+    }
+  });
+  return result;
+}
+
+function handleSortChange(value) {
+  sortBy = value;
+  updateGrid();
+}
+
+/* ══════════════════ GROUPING ══════════════════ */
+// visibleGroups filters and sorts each server group the way the flat grid
+// would, dropping groups left empty. It returns null when grouping is off.
+function visibleGroups() {
+  if (!groupBy || !accountGroups) return null;
+  return accountGroups
+    .map(g => ({ key: g.key, label: g.label, accounts: filterAndSort(g.accounts) }))
+    .filter(g => g.accounts.length > 0);
+}
+
+function renderGroup(group) {
+  const id = `${groupBy}:${group.key}`;
+  const collapsed = collapsedGroups.has(id);
+  return `
+    <section class="account-group${collapsed ? ' collapsed' : ''}">
+      <button class="group-header" data-action="toggle-group" data-group="${escapeHtml(id)}" aria-expanded="${!collapsed}">
+        <span class="group-chevron">&#9662;</span>
+        <span class="group-label">${escapeHtml(group.label)}</span>
+        <span class="group-count">${group.accounts.length}</span>
+      </button>
+      <div class="group-cards">${collapsed ? '' : group.accounts.map((a, i) => renderCard(a, i)).join('')}</div>
+    </section>`;
+}
+
+async function handleGroupChange(value) {
+  groupBy = value;
+  try { localStorage.setItem('trustpin-group-by', groupBy); } catch (e) {}
+  accountGroups = null;
+  lastAccountKeys = '';
+  await refresh();
+}
+
+function toggleGroup(id) {
+  if (collapsedGroups.has(id)) collapsedGroups.delete(id);
+  else collapsedGroups.add(id);
+  try { localStorage.setItem('trustpin-collapsed-groups', JSON.stringify([...collapsedGroups])); } catch (e) {}
+  lastAccountKeys = '';
+  updateGrid();
+}
+
+function loadGroupBy() {
+  try { return localStorage.getItem('trustpin-group-by') || ''; } catch (e) { return ''; }
+}
+
+function loadCollapsedGroups() {
+  try {
+    const stored = JSON.parse(localStorage.getItem('trustpin-collapsed-groups') || '[]');
+    return new Set(Array.isArray(stored) ? stored : []);
+  } catch (e) {
+    return new Set();
+  }
+}
+
+/* ══════════════════ HELPERS ══════════════════ */
+function escapeHtml(str) {
+  if (!str) return '';
+  return str.replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;').replace(/"/g, '&quot;');
+}
+function normalizeAccountName(str) {
+  return (str || '').trim().toLowerCase();
+}
+
+function renderAccountSubmitButton() {
+  if (accountModalMode === 'edit') {
+    return `${ICONS.edit}<span id="add-submit-label">Save Changes</span>`;
+  }
+  return `${ICONS.plus}<span id="add-submit-label">Add Account</span>`;
+}
+
+function loadPrivacyMode() {
+  try {
+    const stored = localStorage.getItem('trustpin-privacy-mode');
+    if (stored === null) return true;
+    return stored !== 'off';
+  } catch (e) {
+    return true;
+  }
+}
+
+function savePrivacyMode() {
+  try {
+    localStorage.setItem('trustpin-privacy-mode', privacyMode ? 'on' : 'off');
+  } catch (e) {
+    /* ignore storage failures */
+  }
+}
+
+function applyPrivacyMode() {
+  document.body.classList.toggle('privacy-mode', privacyMode);
+}
+
+function togglePrivacyMode() {
+  privacyMode = !privacyMode;
+  savePrivacyMode();
+  applyPrivacyMode();
+  updateToolbar();
+  showToast(privacyMode ? 'Privacy mode enabled' : 'Privacy mode disabled', 'info');
+}
+
+/* ══════════════════ THEME ══════════════════ */
+function loadThemeMode() {
+  try { return localStorage.getItem('trustpin-theme') !== 'light'; } catch (e) { return true; }
+}
+function saveThemeMode() {
+  try { localStorage.setItem('trustpin-theme', darkMode ? 'dark' : 'light'); } catch (e) {}
+}
+function applyThemeMode() {
+  document.body.classList.toggle('light-theme', !darkMode);
+}
+function toggleThemeMode() {
+  darkMode = !darkMode;
+  saveThemeMode();
+  applyThemeMode();
+  renderApp();
+  setupDropzone();
+  updateStats();
+  updateGrid();
+  showToast(darkMode ? 'Dark theme enabled' : 'Light theme enabled', 'info');
+}
+
+/* ══════════════════ FAVORITES ══════════════════ */
+async function toggleFavorite(id) {
+  const account = findAccountById(id);
+  if (!account) return;
+  try {
+    await apiUpdateAccount(id, {
+      name: account.name, secret: '', interval: account.interval,
+      digits: account.digits, algorithm: account.algorithm, type: account.type,
+      counter: account.counter, tags: account.tags, favorite: !account.favorite,
+      notes: account.notes, sortOrder: account.sortOrder
+    });
+    lastAccountKeys = '';
+    await refresh();
+    showToast(account.favorite ? 'Removed from favorites' : 'Added to favorites', 'info');
+  } catch (err) {
+    showToast(err.message, 'error');
+  }
+}
+
+/* ══════════════════ QR DISPLAY ══════════════════ */
+function showAccountQR(id) {
+  const account = findAccountById(id);
+  if (!account) return;
+  document.getElementById('qr-modal').classList.add('open');
+  document.getElementById('qr-modal-meta').textContent = `Scan to add "${account.name}" to your authenticator app.`;
+  document.getElementById('qr-display').innerHTML = `<img src="/api/v1/accounts/${encodeURIComponent(id)}/qr" width="256" alt="QR Code" style="width:256px;height:auto">`;
+}
+function closeQRModal() { document.getElementById('qr-modal').classList.remove('open'); }
+
+/* ══════════════════ DRAG & DROP ══════════════════ */
+function handleDragStart(e) {
+  draggedCard = e.target.closest('.card');
+  if (draggedCard) {
+    draggedCard.classList.add('dragging');
+    e.dataTransfer.effectAllowed = 'move';
+    e.dataTransfer.setData('text/plain', draggedCard.dataset.id);
+  }
+}
+function handleDragOver(e) {
+  e.preventDefault();
+  e.dataTransfer.dropEffect = 'move';
+  const card = e.target.closest('.card');
+  if (card && card !== draggedCard) card.classList.add('drag-over');
+}
+function handleDragEnd(e) {
+  document.querySelectorAll('.card').forEach(c => {
+    c.classList.remove('dragging', 'drag-over');
+  });
+  draggedCard = null;
+}
+async function handleDrop(e) {
+  e.preventDefault();
+  const targetCard = e.target.closest('.card');
+  document.querySelectorAll('.card').forEach(c => c.classList.remove('drag-over'));
+  if (!draggedCard || !targetCard || draggedCard === targetCard) return;
+  const draggedId = draggedCard.dataset.id;
+  const targetId = targetCard.dataset.id;
+  // Swap sort orders
+  const filtered = filterAndSort(accounts);
+  const draggedIdx = filtered.findIndex(a => a.id === draggedId);
+  const targetIdx = filtered.findIndex(a => a.id === targetId);
+  if (draggedIdx === -1 || targetIdx === -1) return;
+  const reorder = filtered.map((a, i) => ({ id: a.id, sortOrder: i }));
+  // Move dragged to target position
+  reorder.splice(draggedIdx, 1);
+  reorder.splice(targetIdx, 0, { id: draggedId, sortOrder: targetIdx });
+  // Reassign sequential sort orders
+  const payload = reorder.map((r, i) => ({ id: r.id, sortOrder: i }));
+  try {
+    const res = await apiFetch('/api/v1/accounts/reorder', {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(payload),
+    });
+    if (!res.ok) throw new Error('Reorder failed');
+    sortBy = 'custom';
+    lastAccountKeys = '';
+    await refresh();
+  } catch (err) {
+    showToast('Reorder failed', 'error');
+  }
+  draggedCard = null;
+}
+
+/* ══════════════════ OTP TYPE CHANGE ══════════════════ */
+function handleTypeChange(value) {
+  const counterGroup = document.getElementById('counter-group');
+  counterGroup.style.display = value === 'hotp' ? 'block' : 'none';
+  if (value === 'steam') {
+    document.getElementById('add-digits').value = '5';
+    document.getElementById('add-interval').value = '30';
+    document.getElementById('add-algorithm').value = 'SHA1';
+  }
+}
+
+/* ══════════════════ ISSUER ICON ══════════════════ */
+/* Icons come from the server's built-in brand catalog, or from an image
+   uploaded for the account, so the dashboard makes no third-party requests. */
+function issuerIconHtml(a) {
+  const initial = escapeHtml((a.issuer || '?')[0]);
+  if (a.customIcon) {
+    const version = encodeURIComponent(a.updatedAt || '');
+    return `<img class="issuer-icon" src="/api/v1/accounts/${encodeURIComponent(a.id)}/icon?v=${version}" data-initial="${initial}" alt="">`;
+  }
+  if (!a.brandColor) return `<span class="issuer-icon-placeholder">${initial}</span>`;
+  return `<img class="issuer-icon" src="/api/v1/icons/${encodeURIComponent(a.issuer)}" data-initial="${initial}" alt="">`;
+}
+
+// brandStyle tints the issuer badge with the account's brand color.
+function brandStyle(a) {
+  return a.brandColor ? ` style="--brand: ${escapeHtml(a.brandColor)}"` : '';
+}
+
+/* ══════════════════ KEYBOARD ══════════════════ */
+document.addEventListener('keydown', e => {
+  if ((e.metaKey || e.ctrlKey) && e.key === 'k') {
+    e.preventDefault();
+    document.getElementById('search-input')?.focus();
+  }
+  if (e.key === 'Escape') {
+    closeAddModal();
+    closeHealthPanel();
+    closeConfirm();
+    closeQRModal();
+  }
+});
+
+/* ══════════════════ EVENT WIRING ══════════════════ */
+/* Markup names its handlers with data-action, data-change and data-submit
+   instead of inline on* attributes, which the Content-Security-Policy blocks. */
+function cardAccountId(el) {
+  const card = el.closest('[data-id]');
+  return card ? card.dataset.id : '';
+}
+
+const CLICK_ACTIONS = {
+  'toggle-group': el => toggleGroup(el.dataset.group),
+  'open-add-modal': () => openAddModal(),
+  'close-add-modal': () => closeAddModal(),
+  'switch-tab': el => switchTab(el.dataset.tab),
+  'toggle-advanced': () => toggleAdvanced(),
+  'clear-qr-file': () => clearQRFile(),
+  'qr-import': () => handleQRImport(),
+  'open-health-panel': () => openHealthPanel(),
+  'close-health-panel': () => closeHealthPanel(),
+  'close-confirm': () => closeConfirm(),
+  'confirm-delete': () => confirmDelete(),
+  'close-qr-modal': () => closeQRModal(),
+  'toggle-archived': () => toggleArchivedView(),
+  'lock': () => lockDashboard(),
+  'toggle-theme': () => toggleThemeMode(),
+  'toggle-selection': () => toggleSelectionMode(),
+  'toggle-privacy': () => togglePrivacyMode(),
+  'toggle-selected': el => toggleSelected(cardAccountId(el)),
+  'toggle-favorite': el => toggleFavorite(cardAccountId(el)),
+  'copy-otp': async el => {
+    const account = findAccountById(cardAccountId(el));
+    if (account && await copyOTP(account.otp, el)) recordUse(account.id);
+  },
+  'copy-next-otp': async el => {
+    const account = findAccountById(cardAccountId(el));
+    if (account && await copyOTP(account.nextOTP, el)) recordUse(account.id);
+  },
+  'next-hotp': el => nextHOTP(cardAccountId(el)),
+  'show-qr': el => showAccountQR(cardAccountId(el)),
+  'edit': el => openEditModal(cardAccountId(el)),
+  'archive': el => archiveAccount(cardAccountId(el)),
+  'restore': el => restoreAccount(cardAccountId(el)),
+  'delete': el => promptDelete(cardAccountId(el)),
+  'select-all': () => selectAllVisible(),
+  'bulk-favorite': el => bulkFavorite(el.dataset.favorite === 'true'),
+  'bulk-tag': () => bulkAddTag(),
+  'bulk-archive': el => bulkArchive(el.dataset.archived === 'true'),
+  'bulk-delete': () => bulkDelete(),
+};
+
+const CHANGE_ACTIONS = {
+  'otp-type': el => handleTypeChange(el.value),
+  'reset-import-plan': () => resetImportPlan(),
+  'sort': el => handleSortChange(el.value),
+  'group-by': el => handleGroupChange(el.value),
+  'custom-color': () => { document.getElementById('add-color-custom').checked = true; },
+};
+
+const SUBMIT_ACTIONS = {
+  'add-account': (el, e) => handleAddSubmit(e),
+  'unlock': () => unlockSession(),
+};
+
+document.addEventListener('click', e => {
+  const el = e.target.closest('[data-action]');
+  const action = el && CLICK_ACTIONS[el.dataset.action];
+  if (action) action(el, e);
+});
+document.addEventListener('change', e => {
+  const el = e.target.closest('[data-change]');
+  const action = el && CHANGE_ACTIONS[el.dataset.change];
+  if (action) action(el, e);
+});
+document.addEventListener('submit', e => {
+  const action = SUBMIT_ACTIONS[e.target.dataset.submit];
+  if (!action) return;
+  e.preventDefault();
+  action(e.target, e);
+});
+
+/* Revealing a code in privacy mode counts as a use */
+document.addEventListener('mouseover', e => { const card = e.target.closest('.card[data-id]'); if (card) recordReveal(card); });
+document.addEventListener('focusin', e => { const card = e.target.closest('.card[data-id]'); if (card) recordReveal(card); });
+
+/* Remember which cards have their details open across re-renders */
+document.addEventListener('toggle', e => {
+  const card = e.target.classList && e.target.classList.contains('card-details') && e.target.closest('.card[data-id]');
+  if (!card) return;
+  if (e.target.open) openDetails.add(card.dataset.id);
+  else openDetails.delete(card.dataset.id);
+}, true);
+
+/* Account cards can be dragged to reorder them */
+document.addEventListener('dragstart', e => { if (e.target.closest('.card[draggable]')) handleDragStart(e); });
+document.addEventListener('dragover', e => { if (e.target.closest('.card[draggable]')) handleDragOver(e); });
+document.addEventListener('drop', e => { if (e.target.closest('.card[draggable]')) handleDrop(e); });
+document.addEventListener('dragend', e => { if (e.target.closest('.card[draggable]')) handleDragEnd(e); });
+
+/* Image load errors do not bubble, so catch them on the way down */
+document.addEventListener('error', e => {
+  const img = e.target;
+  if (!(img instanceof HTMLImageElement)) return;
+  if (img.classList.contains('issuer-icon')) {
+    const placeholder = document.createElement('span');
+    placeholder.className = 'issuer-icon-placeholder';
+    placeholder.textContent = img.dataset.initial || '?';
+    img.replaceWith(placeholder);
+  } else if (img.closest('#qr-display')) {
+    const message = document.createElement('div');
+    message.className = 'qr-error';
+    message.textContent = 'Failed to generate QR code';
+    img.replaceWith(message);
+  }
+}, true);
+
+/* Click outside to close overlays */
+document.getElementById('add-modal').addEventListener('click', e => {
+  if (e.target === e.currentTarget) closeAddModal();
+});
+document.getElementById('health-panel').addEventListener('click', e => {
+  if (e.target === e.currentTarget) closeHealthPanel();
+});
+document.getElementById('confirm-overlay').addEventListener('click', e => {
+  if (e.target === e.currentTarget) closeConfirm();
+});
+document.getElementById('qr-modal').addEventListener('click', e => {
+  if (e.target === e.currentTarget) closeQRModal();
+});
+
+/* ══════════════════ LOCK ══════════════════ */
+['pointerdown', 'keydown', 'wheel', 'touchstart'].forEach(type => {
+  document.addEventListener(type, () => { userActive = true; }, { passive: true, capture: true });
+});
+
+function showLockScreen(message) {
+  if (!locked) {
+    locked = true;
+    accounts = [];
+    prevOTPs = {};
+    lastAccountKeys = '';
+    closeAddModal();
+    closeHealthPanel();
+    closeConfirm();
+    closeQRModal();
+    updateStats();
+    updateGrid();
+  }
+  if (message) document.getElementById('lock-desc').textContent = message;
+  document.getElementById('lock-error').textContent = '';
+  document.getElementById('lock-logo').innerHTML = ICONS.lock;
+  document.getElementById('lock-screen').classList.add('open');
+  document.getElementById('lock-token').focus();
+}
+
+async function startSession(token) {
+  const res = await fetch('/api/v1/session', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ token }),
+  });
+  const body = await res.json();
+  if (!res.ok) throw new Error(apiErrorMessage(body, 'Unlock failed'));
+  return body;
+}
+
+async function unlockSession() {
+  const input = document.getElementById('lock-token');
+  try {
+    await startSession(input.value);
+  } catch (e) {
+    document.getElementById('lock-error').textContent = e.message;
+    return;
+  }
+  input.value = '';
+  locked = false;
+  document.getElementById('lock-screen').classList.remove('open');
+  await refresh();
+}
+
+async function lockDashboard() {
+  try {
+    await fetch('/api/v1/lock', { method: 'POST' });
+  } finally {
+    showLockScreen('Dashboard locked. Enter the access token to continue.');
+  }
+}
+
+/* ══════════════════ MAIN LOOP ══════════════════ */
+async function refresh() {
+  if (locked) return;
+  const data = await fetchAccounts();
+  if (data === null || data.by !== groupBy || data.q !== searchTerm.trim()) return;
+
+  accounts = data.list;
+  accountGroups = data.groups;
+  /* While searching, the stats keep describing the whole workspace */
+  if (!data.q) updateStats();
+
+  const filtered = filterAndSort(accounts);
+  const groups = visibleGroups();
+  const newKeys = groups
+    ? groups.map(g => [g.key, ...g.accounts.map(a => a.name)].join('\n')).join('\n\n')
+    : filtered.map(a => a.name).join('\n');
+
+  if (newKeys !== lastAccountKeys) {
+    lastAccountKeys = newKeys;
+    updateGrid();
+  } else {
+    updateCardsInPlace(filtered);
+    updateToolbar();
+  }
+}
+
+async function init() {
+  renderApp();
+  setupDropzone();
+
+  const params = new URLSearchParams(window.location.search);
+  const token = params.get('token');
+  if (token) {
+    params.delete('token');
+    const query = params.toString();
+    history.replaceState(null, '', window.location.pathname + (query ? `?${query}` : ''));
+    try {
+      await startSession(token);
+    } catch (e) {
+      showToast(e.message, 'error');
+    }
+  }
+  const session = await fetch('/api/v1/session').then(res => res.json()).catch(() => ({ locked: true }));
+  if (session.locked) showLockScreen();
+
+  await refresh();
+  refreshTimer = setInterval(refresh, 1000);
+}
+
+init();
@@ -0,0 +1,221 @@
+package webui
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	caCertFileName   = "ca.pem"
+	caKeyFileName    = "ca-key.pem"
+	leafCertFileName = "cert.pem"
+	leafKeyFileName  = "key.pem"
+
+	caValidity = 10 * 365 * 24 * time.Hour
+	// leafValidity stays under the 398 days browsers accept for a leaf.
+	leafValidity = 397 * 24 * time.Hour
+	// leafRenewBefore regenerates the leaf well before it expires.
+	leafRenewBefore = 30 * 24 * time.Hour
+)
+
+// localCertificate is the dashboard certificate plus what the user needs to
+// trust it.
+type localCertificate struct {
+	Certificate   tls.Certificate
+	CAPath        string
+	CAFingerprint string
+}
+
+// loadOrCreateLocalCertificate returns a leaf certificate for hosts signed by
+// a local CA cached in dir. The CA is created once and reused so it only has
+// to be trusted once; the leaf is reissued when it nears expiry or does not
+// cover every host.
+func loadOrCreateLocalCertificate(dir string, hosts []string, now time.Time) (localCertificate, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return localCertificate{}, fmt.Errorf("create tls directory: %w", err)
+	}
+
+	caPath := filepath.Join(dir, caCertFileName)
+	caCert, caKey, err := loadCertificateAuthority(caPath, filepath.Join(dir, caKeyFileName), now)
+	if err != nil {
+		return localCertificate{}, err
+	}
+
+	certPath := filepath.Join(dir, leafCertFileName)
+	keyPath := filepath.Join(dir, leafKeyFileName)
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil || !leafCovers(cert, caCert, hosts, now) {
+		if err := writeLeafCertificate(certPath, keyPath, caCert, caKey, hosts, now); err != nil {
+			return localCertificate{}, err
+		}
+		if cert, err = tls.LoadX509KeyPair(certPath, keyPath); err != nil {
+			return localCertificate{}, fmt.Errorf("load tls certificate: %w", err)
+		}
+	}
+
+	return localCertificate{Certificate: cert, CAPath: caPath, CAFingerprint: certificateFingerprint(caCert)}, nil
+}
+
+func loadCertificateAuthority(certPath, keyPath string, now time.Time) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err == nil {
+		key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+		if ok && pair.Leaf != nil && pair.Leaf.IsCA && now.Before(pair.Leaf.NotAfter) {
+			return pair.Leaf, key, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("load local CA: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate CA key: %w", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          randomSerial(),
+		Subject:               pkix.Name{CommonName: "TrustPIN Local CA", Organization: []string{"TrustPIN"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create CA certificate: %w", err)
+	}
+	if err := writePEMFiles(certPath, keyPath, der, key); err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse CA certificate: %w", err)
+	}
+	return cert, key, nil
+}
+
+func writeLeafCertificate(certPath, keyPath string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, hosts []string, now time.Time) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generate tls key: %w", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{CommonName: hosts[0], Organization: []string{"TrustPIN"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(leafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return fmt.Errorf("create tls certificate: %w", err)
+	}
+	return writePEMFiles(certPath, keyPath, der, key)
+}
+
+// leafCovers reports whether a cached leaf is signed by ca, valid for a while
+// longer and names every host.
+func leafCovers(cert tls.Certificate, ca *x509.Certificate, hosts []string, now time.Time) bool {
+	leaf := cert.Leaf
+	if leaf == nil || leaf.CheckSignatureFrom(ca) != nil || now.Add(leafRenewBefore).After(leaf.NotAfter) {
+		return false
+	}
+	for _, host := range hosts {
+		if leaf.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func writePEMFiles(certPath, keyPath string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("encode private key: %w", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return fmt.Errorf("write private key: %w", err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return fmt.Errorf("write certificate: %w", err)
+	}
+	return nil
+}
+
+func randomSerial() *big.Int {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic("webui: read random serial: " + err.Error())
+	}
+	return serial
+}
+
+// certificateFingerprint formats the SHA-256 fingerprint the way browsers and
+// keychain tools display it.
+func certificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	var b bytes.Buffer
+	for i, octet := range sum {
+		if i > 0 {
+			b.WriteByte(':')
+		}
+		fmt.Fprintf(&b, "%02X", octet)
+	}
+	return b.String()
+}
+
+// certificateHosts lists the names the generated certificate must cover. An
+// unspecified bind address adds every interface address so the dashboard can
+// be reached from, for example, a VM host.
+func certificateHosts(bind string) []string {
+	hosts := []string{"trustpin.localhost", "localhost", "127.0.0.1", "::1"}
+	add := func(host string) {
+		if !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	host := strings.Trim(strings.TrimSpace(bind), "[]")
+	ip := net.ParseIP(host)
+	switch {
+	case host == "":
+	case ip != nil && ip.IsUnspecified():
+		addrs, _ := net.InterfaceAddrs()
+		for _, addr := range addrs {
+			if prefix, ok := addr.(*net.IPNet); ok && !prefix.IP.IsLoopback() && !prefix.IP.IsLinkLocalUnicast() {
+				add(prefix.IP.String())
+			}
+		}
+	case ip != nil:
+		add(ip.String())
+	default:
+		add(strings.ToLower(host))
+	}
+	return hosts
+}
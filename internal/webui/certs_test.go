@@ -0,0 +1,61 @@
+package webui
+
+import (
+	"bytes"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLocalCertificateIsCachedAndReissued(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	hosts := certificateHosts("")
+
+	first, err := loadOrCreateLocalCertificate(dir, hosts, now)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	caPEM, err := os.ReadFile(first.CAPath)
+	if err != nil || !roots.AppendCertsFromPEM(caPEM) {
+		t.Fatalf("read CA: %v", err)
+	}
+	for _, host := range []string{"trustpin.localhost", "127.0.0.1", "::1"} {
+		if _, err := first.Certificate.Leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err != nil {
+			t.Fatalf("expected the leaf to be valid for %s: %v", host, err)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dir, caKeyFileName)); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected the CA key to be private, info=%v err=%v", info, err)
+	}
+
+	again, err := loadOrCreateLocalCertificate(dir, hosts, now)
+	if err != nil {
+		t.Fatalf("reload certificate: %v", err)
+	}
+	if again.CAFingerprint != first.CAFingerprint || !bytes.Equal(again.Certificate.Leaf.Raw, first.Certificate.Leaf.Raw) {
+		t.Fatalf("expected the cached CA and leaf to be reused")
+	}
+
+	widened, err := loadOrCreateLocalCertificate(dir, certificateHosts("192.168.56.1"), now)
+	if err != nil {
+		t.Fatalf("reissue certificate: %v", err)
+	}
+	if widened.CAFingerprint != first.CAFingerprint {
+		t.Fatalf("expected the CA to survive a leaf reissue")
+	}
+	if err := widened.Certificate.Leaf.VerifyHostname("192.168.56.1"); err != nil {
+		t.Fatalf("expected the reissued leaf to cover the bind address: %v", err)
+	}
+
+	renewed, err := loadOrCreateLocalCertificate(dir, hosts, now.Add(leafValidity-leafRenewBefore/2))
+	if err != nil {
+		t.Fatalf("renew certificate: %v", err)
+	}
+	if bytes.Equal(renewed.Certificate.Leaf.Raw, widened.Certificate.Leaf.Raw) {
+		t.Fatalf("expected a leaf close to expiry to be reissued")
+	}
+}
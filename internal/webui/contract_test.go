@@ -0,0 +1,470 @@
+package webui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/milan604/trustPIN/internal/trustpin"
+)
+
+// openAPIDocument is the subset of OpenAPI 3.1 the contract tests read.
+type openAPIDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Responses map[string]openAPIResponse `json:"responses"`
+		Schemas   map[string]jsonSchema      `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	Responses map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIResponse struct {
+	Ref     string `json:"$ref"`
+	Content map[string]struct {
+		Schema jsonSchema `json:"schema"`
+	} `json:"content"`
+}
+
+type jsonSchema map[string]interface{}
+
+var httpMethods = []string{"get", "put", "post", "delete", "patch"}
+
+func loadOpenAPI(t *testing.T) openAPIDocument {
+	t.Helper()
+	var doc openAPIDocument
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("parse openapi.json: %v", err)
+	}
+	return doc
+}
+
+func (doc openAPIDocument) operation(t *testing.T, path, method string) (openAPIOperation, bool) {
+	t.Helper()
+	raw, ok := doc.Paths[path][method]
+	if !ok {
+		return openAPIOperation{}, false
+	}
+	var op openAPIOperation
+	if err := json.Unmarshal(raw, &op); err != nil {
+		t.Fatalf("parse %s %s: %v", method, path, err)
+	}
+	return op, true
+}
+
+// methods lists the operations declared on a path, upper-cased like an Allow
+// header.
+func (doc openAPIDocument) methods(path string) []string {
+	var methods []string
+	for _, method := range httpMethods {
+		if _, ok := doc.Paths[path][method]; ok {
+			methods = append(methods, strings.ToUpper(method))
+		}
+	}
+	return methods
+}
+
+func (doc openAPIDocument) resolveResponse(response openAPIResponse) openAPIResponse {
+	if name, ok := strings.CutPrefix(response.Ref, "#/components/responses/"); ok {
+		return doc.Components.Responses[name]
+	}
+	return response
+}
+
+// validate checks value against the keywords the spec uses: $ref, oneOf,
+// type, enum, required, properties, additionalProperties and items.
+func (doc openAPIDocument) validate(schema jsonSchema, value interface{}, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		target, ok := doc.Components.Schemas[name]
+		if !ok {
+			return []string{fmt.Sprintf("%s: unknown schema %s", at, ref)}
+		}
+		return doc.validate(target, value, at)
+	}
+	if branches, ok := schema["oneOf"].([]interface{}); ok {
+		matches := 0
+		for _, branch := range branches {
+			if branch, ok := branch.(map[string]interface{}); ok && len(doc.validate(branch, value, at)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return []string{fmt.Sprintf("%s: matches %d of the oneOf schemas, want exactly 1", at, matches)}
+		}
+		return nil
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !slices.Contains(types, jsonType(value)) {
+		if !(jsonType(value) == "number" && slices.Contains(types, "integer") && isInteger(value)) {
+			return []string{fmt.Sprintf("%s: got %s, want %v", at, jsonType(value), types)}
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && !slices.Contains(enum, value) {
+		return []string{fmt.Sprintf("%s: %v is not one of %v", at, value, enum)}
+	}
+
+	var problems []string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		for _, name := range schemaTypes(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required %q", at, name))
+			}
+		}
+		for name, field := range v {
+			property, ok := properties[name].(map[string]interface{})
+			if !ok {
+				if schema["additionalProperties"] == false {
+					problems = append(problems, fmt.Sprintf("%s: undocumented property %q", at, name))
+				}
+				continue
+			}
+			problems = append(problems, doc.validate(property, field, at+"."+name)...)
+		}
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				problems = append(problems, doc.validate(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	}
+	return problems
+}
+
+func schemaTypes(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		types := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func isInteger(value interface{}) bool {
+	f, ok := value.(float64)
+	return ok && f == math.Trunc(f)
+}
+
+type contractCase struct {
+	name     string
+	method   string
+	path     string
+	spec     string
+	body     string
+	form     map[string][]byte
+	header   map[string]string
+	locked   bool
+	status   int
+	wantCode string
+}
+
+func qrUploadFor(t *testing.T, account trustpin.Account) []byte {
+	t.Helper()
+	png, err := trustpin.GenerateQRCodePNG(account, 256)
+	if err != nil {
+		t.Fatalf("generate QR: %v", err)
+	}
+	return png
+}
+
+func (c contractCase) request(sessionID string) *http.Request {
+	var req *http.Request
+	if c.form != nil {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		names := make([]string, 0, len(c.form))
+		for name := range c.form {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if name == "qr" || name == "icon" {
+				part, _ := writer.CreateFormFile(name, name+".png")
+				_, _ = part.Write(c.form[name])
+				continue
+			}
+			_ = writer.WriteField(name, string(c.form[name]))
+		}
+		_ = writer.Close()
+		req = httptest.NewRequest(c.method, c.path, &body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+	} else {
+		req = httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if !c.locked {
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+	}
+	for name, value := range c.header {
+		req.Header.Set(name, value)
+	}
+	return req
+}
+
+func TestAPIContract(t *testing.T) {
+	doc := loadOpenAPI(t)
+	srv, id := newTestServer(t)
+	handler := srv.handler()
+	sessionID, _ := srv.sessions.login("secret")
+
+	account := "/api/v1/accounts/" + id
+	icon := qrUploadFor(t, trustpin.Account{Name: "Icon", Secret: "JBSWY3DPEHPK3PXP"})
+	cases := []contractCase{
+		{name: "spec", method: http.MethodGet, path: "/api/v1/openapi.json", spec: "/openapi.json", locked: true, status: http.StatusOK},
+		{name: "session status", method: http.MethodGet, path: "/api/v1/session", spec: "/session", locked: true, status: http.StatusOK},
+		{name: "unlock with bad JSON", method: http.MethodPost, path: "/api/v1/session", spec: "/session", body: "{", locked: true, status: http.StatusBadRequest, wantCode: codeInvalidJSON},
+		{name: "unlock with wrong token", method: http.MethodPost, path: "/api/v1/session", spec: "/session", body: `{"token":"nope"}`, locked: true, status: http.StatusUnauthorized, wantCode: codeUnauthorized},
+		{name: "unlock", method: http.MethodPost, path: "/api/v1/session", spec: "/session", body: `{"token":"secret"}`, locked: true, status: http.StatusOK},
+		{name: "list while locked", method: http.MethodGet, path: "/api/v1/accounts", spec: "/accounts", locked: true, status: http.StatusUnauthorized, wantCode: codeLocked},
+		{name: "list", method: http.MethodGet, path: "/api/v1/accounts", spec: "/accounts", status: http.StatusOK},
+		{name: "search", method: http.MethodGet, path: "/api/v1/accounts?q=gihtub", spec: "/accounts", status: http.StatusOK},
+		{name: "list grouped by issuer", method: http.MethodGet, path: "/api/v1/accounts?groupBy=issuer", spec: "/accounts", status: http.StatusOK},
+		{name: "list with a bad grouping", method: http.MethodGet, path: "/api/v1/accounts?groupBy=folder", spec: "/accounts", status: http.StatusBadRequest, wantCode: codeValidation},
+		{name: "add", method: http.MethodPost, path: "/api/v1/accounts", spec: "/accounts", body: `{"issuer":"Slack","label":"me","secret":"GEZDGNBVGY3TQOJQ"}`, status: http.StatusCreated},
+		{name: "add again", method: http.MethodPost, path: "/api/v1/accounts", spec: "/accounts", body: `{"issuer":"Slack","label":"me","secret":"GEZDGNBVGY3TQOJQ"}`, status: http.StatusOK},
+		{name: "add without a secret", method: http.MethodPost, path: "/api/v1/accounts", spec: "/accounts", body: `{"name":"Empty"}`, status: http.StatusBadRequest, wantCode: codeInvalidSecret},
+		{name: "add with bad digits", method: http.MethodPost, path: "/api/v1/accounts", spec: "/accounts", body: `{"name":"Odd","secret":"ONSWG4TFOQ======","digits":42}`, status: http.StatusBadRequest, wantCode: codeValidation},
+		{name: "add with bad color", method: http.MethodPost, path: "/api/v1/accounts", spec: "/accounts", body: `{"name":"Tinted","secret":"ONSWG4TFOQ======","color":"teal"}`, status: http.StatusBadRequest, wantCode: codeValidation},
+		{name: "add with bad JSON", method: http.MethodPost, path: "/api/v1/accounts", spec: "/accounts", body: "[", status: http.StatusBadRequest, wantCode: codeInvalidJSON},
+		{name: "add oversized", method: http.MethodPost, path: "/api/v1/accounts", spec: "/accounts", body: `{"notes":"` + strings.Repeat("x", maxRequestBodyBytes) + `"}`, status: http.StatusRequestEntityTooLarge, wantCode: codeTooLarge},
+		{name: "get", method: http.MethodGet, path: account, spec: "/accounts/{id}", status: http.StatusOK},
+		{name: "get missing", method: http.MethodGet, path: "/api/v1/accounts/missing", spec: "/accounts/{id}", status: http.StatusNotFound, wantCode: codeNotFound},
+		{name: "update", method: http.MethodPut, path: account, spec: "/accounts/{id}", body: `{"name":"GitHub:home"}`, status: http.StatusOK},
+		{name: "update onto another name", method: http.MethodPut, path: account, spec: "/accounts/{id}", body: `{"name":"Slack:me"}`, status: http.StatusConflict, wantCode: codeNameCollision},
+		{name: "update without a name", method: http.MethodPut, path: account, spec: "/accounts/{id}", body: `{}`, status: http.StatusBadRequest, wantCode: codeValidation},
+		{name: "update missing", method: http.MethodPut, path: "/api/v1/accounts/missing", spec: "/accounts/{id}", body: `{"name":"x"}`, status: http.StatusNotFound, wantCode: codeNotFound},
+		{name: "qr", method: http.MethodGet, path: account + "/qr", spec: "/accounts/{id}/qr", status: http.StatusOK},
+		{name: "qr missing", method: http.MethodGet, path: "/api/v1/accounts/missing/qr", spec: "/accounts/{id}/qr", status: http.StatusNotFound, wantCode: codeNotFound},
+		{name: "archive", method: http.MethodPut, path: account + "/archive", spec: "/accounts/{id}/archive", body: `{"archived":true}`, status: http.StatusOK},
+		{name: "archive with bad JSON", method: http.MethodPut, path: account + "/archive", spec: "/accounts/{id}/archive", body: "x", status: http.StatusBadRequest, wantCode: codeInvalidJSON},
+		{name: "archive missing", method: http.MethodPut, path: "/api/v1/accounts/missing/archive", spec: "/accounts/{id}/archive", body: `{"archived":true}`, status: http.StatusNotFound, wantCode: codeNotFound},
+		{name: "use", method: http.MethodPost, path: account + "/use", spec: "/accounts/{id}/use", status: http.StatusOK},
+		{name: "use missing", method: http.MethodPost, path: "/api/v1/accounts/missing/use", spec: "/accounts/{id}/use", status: http.StatusNotFound, wantCode: codeNotFound},
+		{name: "next on a TOTP account", method: http.MethodPost, path: account + "/next", spec: "/accounts/{id}/next", status: http.StatusBadRequest, wantCode: codeValidation},
+		{name: "next missing", method: http.MethodPost, path: "/api/v1/accounts/missing/next", spec: "/accounts/{id}/next", status: http.StatusNotFound, wantCode: codeNotFound},
+		{name: "brand icon", method: http.MethodGet, path: account + "/icon", spec: "/accounts/{id}/icon", status: http.StatusOK},
+		{name: "upload icon", method: http.MethodPut, path: account + "/icon", spec: "/accounts/{id}/icon", form: map[string][]byte{"icon": icon}, status: http.StatusOK},
+		{name: "custom icon", method: http.MethodGet, path: account + "/icon", spec: "/accounts/{id}/icon", status: http.StatusOK},
+		{name: "upload a non-image icon", method: http.MethodPut, path: account + "/icon", spec: "/accounts/{id}/icon", form: map[string][]byte{"icon": []byte("<svg/>")}, status: http.StatusBadRequest, wantCode: codeValidation},
+		{name: "upload without an icon", method: http.MethodPut, path: account + "/icon", spec: "/accounts/{id}/icon", form: map[string][]byte{"note": []byte("x")}, status: http.StatusBadRequest, wantCode: codeValidation},
+		{name: "upload an oversized icon", method: http.MethodPut, path: account + "/icon", spec: "/accounts/{id}/icon", form: map[string][]byte{"icon": bytes.Repeat([]byte{0}, trustpin.MaxIconBytes+32<<10)}, status: http.StatusRequestEntityTooLarge, wantCode: codeTooLarge},
+		{name: "remove icon", method: http.MethodDelete, path: account + "/icon", spec: "/accounts/{id}/icon", status: http.StatusOK},
+		{name: "icon missing", method: http.MethodGet, path: "/api/v1/accounts/missing/icon", spec: "/accounts/{id}/icon", status: http.StatusNotFound, wantCode: codeNotFound},
+		{name: "issuer icon", method: http.MethodGet, path: "/api/v1/icons/GitHub", spec: "/icons/{issuer}", status: http.StatusOK},
+		{name: "reorder", method: http.MethodPut, path: "/api/v1/accounts/reorder", spec: "/accounts/reorder", body: `[{"id":"` + id + `","sortOrder":3}]`, status: http.StatusOK},
+		{name: "reorder with bad JSON", method: http.MethodPut, path: "/api/v1/accounts/reorder", spec: "/accounts/reorder", body: `{}`, status: http.StatusBadRequest, wantCode: codeInvalidJSON},
+		{name: "batch", method: http.MethodPost, path: "/api/v1/accounts/batch", spec: "/accounts/batch", body: `{"operations":[{"op":"set-favorite","id":"` + id + `","favorite":true}]}`, status: http.StatusOK},
+		{name: "empty batch", method: http.MethodPost, path: "/api/v1/accounts/batch", spec: "/accounts/batch", body: `{"operations":[]}`, status: http.StatusBadRequest, wantCode: codeValidation},
+		{name: "rejected batch", method: http.MethodPost, path: "/api/v1/accounts/batch", spec: "/accounts/batch", body: `{"operations":[{"op":"delete","id":"missing"}]}`, status: http.StatusUnprocessableEntity, wantCode: codeBatchRejected},
+		{name: "import plan", method: http.MethodPost, path: "/api/v1/accounts/import", spec: "/accounts/import", form: map[string][]byte{
+			"qr":     qrUploadFor(t, trustpin.Account{Name: "Dropbox:me", Issuer: "Dropbox", Label: "me", Secret: "MFRGGZDFMZTWQ2LK"}),
+			"dryRun": []byte("true"),
+		}, status: http.StatusOK},
+		{name: "import without a file", method: http.MethodPost, path: "/api/v1/accounts/import", spec: "/accounts/import", form: map[string][]byte{"dryRun": []byte("true")}, status: http.StatusBadRequest, wantCode: codeValidation},
+		{name: "import with bad strategy", method: http.MethodPost, path: "/api/v1/accounts/import", spec: "/accounts/import", form: map[string][]byte{"strategy": []byte("bogus")}, status: http.StatusBadRequest, wantCode: codeValidation},
+		{name: "health", method: http.MethodGet, path: "/api/v1/health", spec: "/health", status: http.StatusOK},
+		{name: "diagnostics", method: http.MethodGet, path: "/api/v1/diagnostics", spec: "/diagnostics", status: http.StatusOK},
+		{name: "delete", method: http.MethodDelete, path: account, spec: "/accounts/{id}", status: http.StatusOK},
+		{name: "delete missing", method: http.MethodDelete, path: account, spec: "/accounts/{id}", status: http.StatusNotFound, wantCode: codeNotFound},
+		{name: "lock from another site", method: http.MethodPost, path: "/api/v1/lock", spec: "/lock", header: map[string]string{"Origin": "https://evil.example", "Sec-Fetch-Site": "cross-site"}, locked: true, status: http.StatusForbidden, wantCode: codeCrossOrigin},
+		{name: "lock", method: http.MethodPost, path: "/api/v1/lock", spec: "/lock", locked: true, status: http.StatusOK},
+	}
+
+	covered := map[string]bool{}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, c.request(sessionID))
+		covered[strings.ToLower(c.method)+" "+c.spec] = true
+		checkContract(t, doc, c, rec)
+	}
+
+	for path := range doc.Paths {
+		for _, method := range httpMethods {
+			if _, ok := doc.Paths[path][method]; ok && !covered[method+" "+path] {
+				t.Errorf("%s %s is documented but not exercised", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func checkContract(t *testing.T, doc openAPIDocument, c contractCase, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	if rec.Code != c.status {
+		t.Errorf("%s: status %d, want %d (body %s)", c.name, rec.Code, c.status, rec.Body.String())
+		return
+	}
+
+	op, ok := doc.operation(t, c.spec, strings.ToLower(c.method))
+	if !ok {
+		t.Errorf("%s: %s %s is not in the spec", c.name, c.method, c.spec)
+		return
+	}
+	response, ok := op.Responses[fmt.Sprint(rec.Code)]
+	if !ok {
+		t.Errorf("%s: status %d is not documented for %s %s", c.name, rec.Code, c.method, c.spec)
+		return
+	}
+	response = doc.resolveResponse(response)
+
+	mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	content, ok := response.Content[mediaType]
+	if !ok {
+		t.Errorf("%s: content type %q is not documented for %d", c.name, mediaType, rec.Code)
+		return
+	}
+	if mediaType != "application/json" {
+		return
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Errorf("%s: response is not JSON: %v", c.name, err)
+		return
+	}
+	for _, problem := range doc.validate(content.Schema, body, "body") {
+		t.Errorf("%s: %s", c.name, problem)
+	}
+	if c.wantCode != "" {
+		envelope, _ := body.(map[string]interface{})
+		apiErr, _ := envelope["error"].(map[string]interface{})
+		if apiErr["code"] != c.wantCode {
+			t.Errorf("%s: error code %v, want %s", c.name, apiErr["code"], c.wantCode)
+		}
+	}
+}
+
+func TestAPIContractStoreFailure(t *testing.T) {
+	doc := loadOpenAPI(t)
+	failures := []struct {
+		name     string
+		damage   func(trustpin.Service) error
+		wantCode string
+	}{
+		{"corrupted store", func(service trustpin.Service) error {
+			return os.WriteFile(service.StorePath, []byte("not a store"), 0o600)
+		}, codeCorruptStore},
+		{"wrong key", func(service trustpin.Service) error {
+			return os.WriteFile(service.KeyPath, bytes.Repeat([]byte{7}, 32), 0o600)
+		}, codeWrongKey},
+	}
+
+	for _, failure := range failures {
+		srv, id := newTestServer(t)
+		sessionID, _ := srv.sessions.login("secret")
+		if err := failure.damage(srv.service); err != nil {
+			t.Fatalf("%s: %v", failure.name, err)
+		}
+
+		cases := []contractCase{
+			{name: failure.name + ": list", method: http.MethodGet, path: "/api/v1/accounts", spec: "/accounts"},
+			{name: failure.name + ": get", method: http.MethodGet, path: "/api/v1/accounts/" + id, spec: "/accounts/{id}"},
+			{name: failure.name + ": health", method: http.MethodGet, path: "/api/v1/health", spec: "/health"},
+		}
+		// Diagnostics still answer when the store is broken; that is their point.
+		rec := httptest.NewRecorder()
+		srv.handler().ServeHTTP(rec, contractCase{method: http.MethodGet, path: "/api/v1/diagnostics"}.request(sessionID))
+		checkContract(t, doc, contractCase{name: failure.name + ": diagnostics", method: http.MethodGet, spec: "/diagnostics", status: http.StatusOK}, rec)
+		var report trustpin.DiagnosticReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil || report.Status != trustpin.DiagnosticError {
+			t.Errorf("%s: expected diagnostics to report an error, got %s", failure.name, rec.Body.String())
+		}
+
+		for _, c := range cases {
+			c.status, c.wantCode = http.StatusInternalServerError, failure.wantCode
+			rec := httptest.NewRecorder()
+			srv.handler().ServeHTTP(rec, c.request(sessionID))
+			checkContract(t, doc, c, rec)
+		}
+	}
+}
+
+func TestAPIMethodNotAllowed(t *testing.T) {
+	doc := loadOpenAPI(t)
+	srv, id := newTestServer(t)
+	sessionID, _ := srv.sessions.login("secret")
+
+	for path := range doc.Paths {
+		allowed := doc.methods(path)
+		for _, method := range httpMethods {
+			method = strings.ToUpper(method)
+			if slices.Contains(allowed, method) {
+				continue
+			}
+			target := "/api/v1" + strings.ReplaceAll(path, "{id}", id)
+			c := contractCase{method: method, path: target, body: "{}"}
+			rec := httptest.NewRecorder()
+			srv.handler().ServeHTTP(rec, c.request(sessionID))
+
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Errorf("%s %s: status %d, want 405", method, path, rec.Code)
+				continue
+			}
+			got := strings.Split(rec.Header().Get("Allow"), ", ")
+			sort.Strings(got)
+			want := slices.Sorted(slices.Values(allowed))
+			if !slices.Equal(got, want) {
+				t.Errorf("%s %s: Allow %v, want %v", method, path, got, want)
+			}
+			var body interface{}
+			_ = json.Unmarshal(rec.Body.Bytes(), &body)
+			for _, problem := range doc.validate(jsonSchema{"$ref": "#/components/schemas/ErrorEnvelope"}, body, "body") {
+				t.Errorf("%s %s: %s", method, path, problem)
+			}
+		}
+	}
+}
+
+func TestLegacyAPIRedirects(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	srv.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/accounts/batch?x=1", nil))
+	if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != "/api/v1/accounts/batch?x=1" {
+		t.Fatalf("expected a 308 to the v1 path, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	srv.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/nope", nil))
+	var envelope errorEnvelope
+	if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil || rec.Code != http.StatusNotFound || envelope.Error.Code != codeNotFound {
+		t.Fatalf("expected a not_found envelope for an unknown v1 path, got %d %s", rec.Code, rec.Body.String())
+	}
+}
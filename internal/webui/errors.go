@@ -0,0 +1,99 @@
+package webui
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/milan604/trustPIN/internal/trustpin"
+)
+
+// Error codes returned in the error envelope. Clients branch on these rather
+// than on messages.
+const (
+	codeInvalidJSON      = "invalid_json"
+	codeValidation       = "validation_failed"
+	codeNotFound         = "not_found"
+	codeConflict         = "conflict"
+	codeNameCollision    = "name_collision"
+	codeSecretCollision  = "secret_collision"
+	codeInvalidSecret    = "invalid_secret"
+	codeInvalidPolicy    = "invalid_policy"
+	codeStoreLocked      = "store_locked"
+	codeInsecureStore    = "store_insecure"
+	codeNewerStore       = "store_too_new"
+	codeWrongKey         = "wrong_key"
+	codeCorruptStore     = "store_corrupted"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeLocked           = "locked"
+	codeUnauthorized     = "unauthorized"
+	codeCrossOrigin      = "cross_origin"
+	codeTooLarge         = "payload_too_large"
+	codeBatchRejected    = "batch_rejected"
+	codeInternal         = "internal"
+)
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
+
+// errorEnvelope is the body of every API error response.
+type errorEnvelope struct {
+	Error apiError `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message, field string) {
+	writeJSON(w, status, errorEnvelope{Error: apiError{Code: code, Message: message, Field: field}})
+}
+
+// writeServiceError maps an error from the trustpin service to a status code
+// by its category. Specific cases come first so clients get the precise code;
+// anything uncategorised is a server-side failure.
+func writeServiceError(w http.ResponseWriter, err error) {
+	field := trustpin.ErrorField(err)
+	switch {
+	case errors.Is(err, trustpin.ErrInvalidPolicy):
+		// The policy file belongs to the server, not to the request.
+		writeError(w, http.StatusInternalServerError, codeInvalidPolicy, err.Error(), "")
+	case errors.Is(err, trustpin.ErrInvalidSecret):
+		writeError(w, http.StatusBadRequest, codeInvalidSecret, err.Error(), field)
+	case errors.Is(err, trustpin.ErrValidation):
+		writeError(w, http.StatusBadRequest, codeValidation, err.Error(), field)
+	case errors.Is(err, trustpin.ErrNotFound):
+		writeError(w, http.StatusNotFound, codeNotFound, err.Error(), field)
+	case errors.Is(err, trustpin.ErrNameCollision):
+		writeError(w, http.StatusConflict, codeNameCollision, err.Error(), field)
+	case errors.Is(err, trustpin.ErrSecretCollision):
+		writeError(w, http.StatusConflict, codeSecretCollision, err.Error(), field)
+	case errors.Is(err, trustpin.ErrConflict):
+		writeError(w, http.StatusConflict, codeConflict, err.Error(), field)
+	case errors.Is(err, trustpin.ErrInsecureStore):
+		writeError(w, http.StatusServiceUnavailable, codeInsecureStore, err.Error(), "")
+	case errors.Is(err, trustpin.ErrNewerStore):
+		writeError(w, http.StatusServiceUnavailable, codeNewerStore, err.Error(), "")
+	case errors.Is(err, trustpin.ErrStoreLocked):
+		writeError(w, http.StatusServiceUnavailable, codeStoreLocked, err.Error(), "")
+	case errors.Is(err, trustpin.ErrWrongKey):
+		writeError(w, http.StatusInternalServerError, codeWrongKey, err.Error(), "")
+	case errors.Is(err, trustpin.ErrCorruptStore):
+		writeError(w, http.StatusInternalServerError, codeCorruptStore, err.Error(), "")
+	default:
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error(), "")
+	}
+}
+
+// writeDecodeError reports a request body that could not be decoded.
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, codeTooLarge, "request body is too large", "")
+		return
+	}
+	writeError(w, http.StatusBadRequest, codeInvalidJSON, "invalid JSON body", "")
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method not allowed", "")
+}
@@ -0,0 +1,156 @@
+package webui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/milan604/trustPIN/internal/trustpin"
+)
+
+func newTestServer(t *testing.T) (server, string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	service := trustpin.Service{
+		StorePath: filepath.Join(tmpDir, "accounts.enc"),
+		KeyPath:   filepath.Join(tmpDir, "accounts.key"),
+	}
+	if _, err := service.UpsertAccounts([]trustpin.Account{{Name: "GitHub:work", Secret: "JBSWY3DPEHPK3PXP"}}); err != nil {
+		t.Fatalf("upsert accounts: %v", err)
+	}
+	accounts, err := service.LoadAccounts()
+	if err != nil {
+		t.Fatalf("load accounts: %v", err)
+	}
+	return server{service: service, sessions: newSessionManager("secret", 0)}, accounts[0].ID
+}
+
+func hashedAssetPath(t *testing.T, ext string) string {
+	t.Helper()
+	assets, err := loadUIAssets()
+	if err != nil {
+		t.Fatalf("load assets: %v", err)
+	}
+	for name := range assets.files {
+		if strings.HasSuffix(name, ext) {
+			return "/assets/" + name
+		}
+	}
+	t.Fatalf("no %s asset embedded", ext)
+	return ""
+}
+
+func TestSecurityHeadersOnEveryRoute(t *testing.T) {
+	srv, id := newTestServer(t)
+	handler := srv.handler()
+	sessionID, _ := srv.sessions.login("secret")
+
+	routes := []struct {
+		method string
+		path   string
+		api    bool
+	}{
+		{http.MethodGet, "/", false},
+		{http.MethodGet, "/missing", false},
+		{http.MethodGet, hashedAssetPath(t, ".js"), false},
+		{http.MethodGet, hashedAssetPath(t, ".css"), false},
+		{http.MethodGet, "/assets/app.js", false},
+		{http.MethodGet, "/api/v1/session", true},
+		{http.MethodGet, "/api/v1/accounts", true},
+		{http.MethodPost, "/api/v1/accounts/import", true},
+		{http.MethodPut, "/api/v1/accounts/reorder", true},
+		{http.MethodPost, "/api/v1/accounts/batch", true},
+		{http.MethodGet, "/api/v1/accounts/" + id, true},
+		{http.MethodGet, "/api/v1/accounts/" + id + "/qr", true},
+		{http.MethodPut, "/api/v1/accounts/" + id + "/archive", true},
+		{http.MethodPost, "/api/v1/accounts/" + id + "/use", true},
+		{http.MethodGet, "/api/v1/accounts/" + id + "/icon", true},
+		{http.MethodGet, "/api/v1/icons/GitHub", true},
+		{http.MethodGet, "/api/v1/health", true},
+		{http.MethodGet, "/api/v1/diagnostics", true},
+		{http.MethodPost, "/api/v1/lock", true},
+		{http.MethodGet, "/api/v1/openapi.json", true},
+		{http.MethodGet, "/api/v1/missing", true},
+		{http.MethodGet, "/api/accounts", true},
+	}
+
+	for _, route := range routes {
+		for _, unlocked := range []bool{true, false} {
+			req := httptest.NewRequest(route.method, route.path, strings.NewReader("{}"))
+			if unlocked {
+				req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: sessionID})
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			header := rec.Header()
+			want := map[string]string{
+				"Content-Security-Policy": contentSecurityPolicy,
+				"X-Frame-Options":         "DENY",
+				"Referrer-Policy":         "no-referrer",
+				"X-Content-Type-Options":  "nosniff",
+			}
+			if route.api {
+				want["Cache-Control"] = "no-store"
+			}
+			for name, value := range want {
+				if got := header.Get(name); got != value {
+					t.Errorf("%s %s (unlocked=%v): %s = %q, want %q", route.method, route.path, unlocked, name, got, value)
+				}
+			}
+		}
+	}
+}
+
+func TestStrictTransportOnlyWithTLS(t *testing.T) {
+	srv, _ := newTestServer(t)
+	for _, secure := range []bool{false, true} {
+		srv.secure = secure
+		rec := httptest.NewRecorder()
+		srv.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://192.168.56.1:8086/", nil))
+		if got := rec.Header().Get("Strict-Transport-Security"); (got == "max-age=86400") != secure {
+			t.Fatalf("secure=%v: unexpected HSTS %q", secure, got)
+		}
+	}
+}
+
+func TestStrictTransportSkipsLocalHosts(t *testing.T) {
+	srv, _ := newTestServer(t)
+	srv.secure = true
+	for _, host := range []string{"trustpin.localhost:8086", "localhost", "127.0.0.1:8086", "[::1]:8086"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		srv.handler().ServeHTTP(rec, req)
+		if got := rec.Header().Get("Strict-Transport-Security"); got != "" {
+			t.Errorf("%s: expected no HSTS, got %q", host, got)
+		}
+	}
+}
+
+func TestEmbeddedUIHasNoInlineScript(t *testing.T) {
+	assets, err := loadUIAssets()
+	if err != nil {
+		t.Fatalf("load assets: %v", err)
+	}
+
+	index := string(assets.index)
+	if regexp.MustCompile(`<script>`).MatchString(index) {
+		t.Fatalf("index.html still contains an inline script block")
+	}
+	inlineHandler := regexp.MustCompile(`\son[a-z]+\s*=\s*["']`)
+	if match := inlineHandler.FindString(index); match != "" {
+		t.Fatalf("index.html has an inline event handler: %q", match)
+	}
+	for name, asset := range assets.files {
+		if match := inlineHandler.FindString(string(asset.data)); match != "" {
+			t.Fatalf("%s renders an inline event handler: %q", name, match)
+		}
+		if !strings.Contains(index, `"/assets/`+name+`"`) {
+			t.Fatalf("index.html does not reference the hashed asset %s", name)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package webui
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const defaultBindAddress = "127.0.0.1"
+
+// listen opens the dashboard listener and returns the URL to show the user.
+func listen(opts Options) (net.Listener, string, error) {
+	if opts.Socket != "" {
+		return listenUnix(opts.Socket)
+	}
+
+	host := strings.TrimSpace(opts.Bind)
+	if host == "" {
+		host = defaultBindAddress
+	}
+	if !isLoopbackHost(host) {
+		if !opts.AllowRemote {
+			return nil, "", fmt.Errorf("refusing to listen on %s: it is not a loopback address (pass --allow-remote to expose the dashboard)", host)
+		}
+		if !opts.TLS {
+			return nil, "", fmt.Errorf("refusing to listen on %s without TLS (pass --tls)", host)
+		}
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(opts.Port))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, "", fmt.Errorf("listen on %s: %w", addr, err)
+	}
+
+	scheme := "http"
+	if opts.TLS {
+		scheme = "https"
+	}
+	displayHost := net.JoinHostPort(host, strconv.Itoa(opts.Port))
+	if host == defaultBindAddress || host == "localhost" {
+		displayHost = net.JoinHostPort("trustpin.localhost", strconv.Itoa(opts.Port))
+	}
+	return listener, scheme + "://" + displayHost, nil
+}
+
+// listenUnix listens on a Unix domain socket readable only by the current
+// user. The socket is created private and its mode set again afterwards. A
+// stale socket left behind by a crashed server is replaced.
+func listenUnix(path string) (net.Listener, string, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, "", fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			return nil, "", fmt.Errorf("another server is already listening on %s", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, "", fmt.Errorf("remove stale socket: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, "", fmt.Errorf("check socket path: %w", err)
+	}
+
+	listener, err := listenPrivateSocket(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = listener.Close()
+		return nil, "", fmt.Errorf("restrict socket permissions: %w", err)
+	}
+	return listener, "unix:" + path, nil
+}
+
+// isLoopbackHost reports whether host only accepts local connections. Names
+// other than localhost are treated as remote because they may resolve anywhere.
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
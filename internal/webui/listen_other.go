@@ -0,0 +1,11 @@
+//go:build !unix
+
+package webui
+
+import "net"
+
+// listenPrivateSocket creates the socket. Outside Unix there is no umask;
+// listenUnix restricts the mode right after.
+func listenPrivateSocket(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
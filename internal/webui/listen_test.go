@@ -0,0 +1,45 @@
+package webui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListenRefusesRemoteAddresses(t *testing.T) {
+	for _, bind := range []string{"0.0.0.0", "192.168.1.10", "example.com"} {
+		_, _, err := listen(Options{Bind: bind, Port: 0})
+		if err == nil || !strings.Contains(err.Error(), "--allow-remote") {
+			t.Fatalf("expected %s to need --allow-remote, got %v", bind, err)
+		}
+	}
+
+	if _, _, err := listen(Options{Bind: "0.0.0.0", AllowRemote: true}); err == nil || !strings.Contains(err.Error(), "TLS") {
+		t.Fatalf("expected a remote address to need TLS, got %v", err)
+	}
+}
+
+func TestListenUnixSocketIsPrivate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trustpin.sock")
+	listener, displayURL, err := listen(Options{Socket: path})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("expected socket mode 0600, got %o", perm)
+	}
+	if displayURL != "unix:"+path {
+		t.Fatalf("unexpected display URL %q", displayURL)
+	}
+
+	if _, _, err := listen(Options{Socket: path}); err == nil {
+		t.Fatalf("expected a second server on the same socket to fail")
+	}
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package webui
+
+import (
+	"net"
+	"sync"
+	"syscall"
+)
+
+// socketListenMu serializes the umask change below; the umask is process-wide.
+var socketListenMu sync.Mutex
+
+// listenPrivateSocket creates the socket with a umask that leaves it 0600,
+// so no other user can connect before its mode is checked.
+func listenPrivateSocket(path string) (net.Listener, error) {
+	socketListenMu.Lock()
+	defer socketListenMu.Unlock()
+
+	old := syscall.Umask(0o177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package webui
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestListenUnixRestoresUmask(t *testing.T) {
+	old := syscall.Umask(0o022)
+	defer syscall.Umask(old)
+
+	listener, _, err := listen(Options{Socket: filepath.Join(t.TempDir(), "trustpin.sock")})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	if mask := syscall.Umask(0o022); mask != 0o022 {
+		t.Fatalf("expected the umask to be restored to 022, got %03o", mask)
+	}
+}
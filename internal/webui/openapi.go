@@ -0,0 +1,22 @@
+package webui
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec documents every /api/v1 route. The contract tests check each
+// handler's responses against it, so it has to change with them.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "GET")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPISpec)
+}
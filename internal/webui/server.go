@@ -1,120 +1,348 @@
 package webui
 
 import (
-	"embed"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/milan604/trustPIN/internal/trustpin"
 )
 
-//go:embed index.html
-var webContent embed.FS
-
 type server struct {
-	service trustpin.Service
+	service  trustpin.Service
+	sessions *sessionManager
+	// secure is set when serving over TLS; it turns on HSTS and secure cookies.
+	secure bool
 }
 
 type apiAddRequest struct {
 	Name      string   `json:"name"`
+	Issuer    string   `json:"issuer"`
+	Label     string   `json:"label"`
 	Secret    string   `json:"secret"`
 	Interval  int64    `json:"interval"`
 	Digits    int      `json:"digits"`
 	Algorithm string   `json:"algorithm"`
+	Encoding  string   `json:"encoding"`
 	Type      string   `json:"type"`
 	Counter   int64    `json:"counter"`
 	Tags      []string `json:"tags"`
 	Favorite  bool     `json:"favorite"`
 	Notes     string   `json:"notes"`
+	Color     string   `json:"color"`
 	SortOrder int      `json:"sortOrder"`
 	Archived  bool     `json:"archived"`
 }
 
-func Start(service trustpin.Service, port int) error {
-	srv := server{service: service}
-	mux := http.NewServeMux()
+const (
+	// maxRequestBodyBytes leaves room for a 10 MiB QR upload plus the
+	// multipart framing around it.
+	maxRequestBodyBytes = 11 << 20
+	maxHeaderBytes      = 64 << 10
+	shutdownTimeout     = 10 * time.Second
+)
+
+// Options configures the web dashboard server.
+type Options struct {
+	Port int
+	// Bind is the address to listen on. It defaults to 127.0.0.1; other
+	// non-loopback addresses need AllowRemote.
+	Bind        string
+	AllowRemote bool
+	// Socket listens on a Unix domain socket instead of Bind and Port.
+	Socket string
+	// TLS serves HTTPS. Without TLSCertFile and TLSKeyFile a certificate
+	// signed by a local CA cached in TLSDir is used.
+	TLS         bool
+	TLSCertFile string
+	TLSKeyFile  string
+	TLSDir      string
+	// Token unlocks the dashboard. A random token is generated when empty.
+	Token string
+	// IdleTimeout locks a browser session after this long without user
+	// activity. Zero keeps sessions open until the server stops.
+	IdleTimeout time.Duration
+	// Lock receives a value whenever every session should be locked, such as
+	// when a screen locker sends SIGUSR1.
+	Lock <-chan os.Signal
+}
+
+// Start serves the dashboard until ctx is cancelled, then waits up to
+// shutdownTimeout for in-flight requests to finish.
+func Start(ctx context.Context, service trustpin.Service, opts Options) error {
+	if opts.Token == "" {
+		opts.Token = NewAccessToken()
+	}
+	sessions := newSessionManager(opts.Token, opts.IdleTimeout)
+	srv := server{service: service, sessions: sessions, secure: opts.TLS}
+
+	var local *localCertificate
+	var tlsConfig *tls.Config
+	if opts.TLS {
+		var cert tls.Certificate
+		if opts.TLSCertFile != "" || opts.TLSKeyFile != "" {
+			loaded, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+			if err != nil {
+				return fmt.Errorf("load tls certificate: %w", err)
+			}
+			cert = loaded
+		} else {
+			generated, err := loadOrCreateLocalCertificate(opts.TLSDir, certificateHosts(opts.Bind), time.Now())
+			if err != nil {
+				return err
+			}
+			cert, local = generated.Certificate, &generated
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
 
-	mux.HandleFunc("/", srv.handleUI)
-	mux.HandleFunc("/api/accounts", srv.handleAPIAccounts)
-	mux.HandleFunc("/api/accounts/import", srv.handleImportQRAPI)
-	mux.HandleFunc("/api/accounts/qr", srv.handleAccountQR)
-	mux.HandleFunc("/api/accounts/reorder", srv.handleReorderAPI)
-	mux.HandleFunc("/api/accounts/archive", srv.handleArchiveAPI)
-	mux.HandleFunc("/api/health", srv.handleAPIHealth)
+	listener, displayURL, err := listen(opts)
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	httpServer := &http.Server{
+		Handler:           srv.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    maxHeaderBytes,
+	}
+
+	if opts.Lock != nil {
+		go func() {
+			for range opts.Lock {
+				sessions.lockAll()
+				fmt.Println("  Dashboard locked")
+			}
+		}()
+	}
 
-	bindAddr := fmt.Sprintf("127.0.0.1:%d", port)
-	displayURL := fmt.Sprintf("http://trustpin.localhost:%d", port)
 	fmt.Println()
 	fmt.Println("  TrustPIN Web Dashboard")
-	fmt.Printf("  Running at \033[1;36m%s\033[0m\n", displayURL)
+	if opts.Socket != "" {
+		fmt.Printf("  Listening on \033[1;36m%s\033[0m\n", displayURL)
+		fmt.Printf("  Access token: %s\n", opts.Token)
+	} else {
+		fmt.Printf("  Running at \033[1;36m%s/?token=%s\033[0m\n", displayURL, url.QueryEscape(opts.Token))
+	}
 	fmt.Printf("  Secure store: \033[0;37m%s\033[0m\n", service.StorePath)
+	if local != nil {
+		fmt.Printf("  Local CA: %s\n", local.CAPath)
+		fmt.Printf("  CA SHA-256: %s\n", local.CAFingerprint)
+		fmt.Println("  Trust the CA once in your browser or keychain to avoid certificate warnings")
+	}
+	if opts.IdleTimeout > 0 {
+		fmt.Printf("  Locks after %s without activity\n", opts.IdleTimeout)
+	}
 	fmt.Println("  Press Ctrl+C to stop")
 	fmt.Println()
 
-	return http.ListenAndServe(bindAddr, mux)
-}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.Serve(listener)
+	}()
 
-func (s server) handleUI(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
-		return
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
 	}
 
-	data, err := webContent.ReadFile("index.html")
-	if err != nil {
-		http.Error(w, "UI not available", http.StatusInternalServerError)
-		return
+	fmt.Println("  Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shut down web server: %w", err)
 	}
+	return nil
+}
 
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	_, _ = w.Write(data)
+// handler wraps the routes with the headers and limits every response and
+// request gets.
+func (s server) handler() http.Handler {
+	return s.strictTransport(securityHeaders(limitRequestBody(s.routes())))
 }
 
-func (s server) handleAPIAccounts(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+// apiPrefix is the root of the current API version.
+const apiPrefix = "/api/v1"
+
+func (s server) routes() http.Handler {
+	mux := http.NewServeMux()
+	guard := func(handler http.HandlerFunc) http.Handler {
+		return s.sessions.requireSession(handler)
+	}
+
+	mux.HandleFunc("/", s.handleUI)
+	mux.HandleFunc("GET /assets/{name}", s.handleAsset)
+	mux.HandleFunc("/api/", handleLegacyAPI)
+	mux.HandleFunc(apiPrefix+"/", handleUnknownAPI)
+	mux.HandleFunc(apiPrefix+"/openapi.json", handleOpenAPI)
+	mux.HandleFunc(apiPrefix+"/session", s.handleSessionAPI)
+	mux.HandleFunc(apiPrefix+"/lock", s.handleLockAPI)
+	mux.Handle(apiPrefix+"/accounts", guard(s.handleAPIAccounts))
+	mux.Handle(apiPrefix+"/accounts/import", guard(s.handleImportQRAPI))
+	mux.Handle(apiPrefix+"/accounts/reorder", guard(s.handleReorderAPI))
+	mux.Handle(apiPrefix+"/accounts/batch", guard(s.handleBatchAPI))
+	mux.Handle(apiPrefix+"/accounts/{id}", guard(s.handleAPIAccount))
+	mux.Handle(apiPrefix+"/accounts/{id}/qr", guard(s.handleAccountQR))
+	mux.Handle(apiPrefix+"/accounts/{id}/archive", guard(s.handleArchiveAPI))
+	mux.Handle(apiPrefix+"/accounts/{id}/use", guard(s.handleUseAPI))
+	mux.Handle(apiPrefix+"/accounts/{id}/next", guard(s.handleNextAPI))
+	mux.Handle(apiPrefix+"/accounts/{id}/icon", guard(s.handleAccountIconAPI))
+	mux.Handle(apiPrefix+"/icons/{issuer}", guard(s.handleIssuerIconAPI))
+	mux.Handle(apiPrefix+"/health", guard(s.handleAPIHealth))
+	mux.Handle(apiPrefix+"/diagnostics", guard(s.handleDiagnosticsAPI))
+	return mux
+}
 
+// handleLegacyAPI permanently redirects the unversioned /api paths to v1.
+// 308 keeps the method and body, so scripts written against them still work.
+func handleLegacyAPI(w http.ResponseWriter, r *http.Request) {
+	target := apiPrefix + strings.TrimPrefix(r.URL.Path, "/api")
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusPermanentRedirect)
+}
+
+func handleUnknownAPI(w http.ResponseWriter, r *http.Request) {
+	writeError(w, http.StatusNotFound, codeNotFound, "no such API endpoint", "")
+}
+
+// limitRequestBody caps every request body so a client cannot stream an
+// unbounded upload into memory.
+func limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s server) handleAPIAccounts(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		s.handleListAccounts(w)
+		s.handleListAccounts(w, r)
 	case http.MethodPost:
 		s.handleAddAccountAPI(w, r)
+	default:
+		writeMethodNotAllowed(w, "GET, POST")
+	}
+}
+
+func (s server) handleAPIAccount(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+	default:
+		writeMethodNotAllowed(w, "GET, PUT, DELETE")
+		return
+	}
+
+	account, ok := s.accountFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, trustpin.BuildAccountSnapshot(account))
 	case http.MethodPut:
-		s.handleUpdateAccountAPI(w, r)
+		s.handleUpdateAccountAPI(w, r, account)
 	case http.MethodDelete:
-		s.handleDeleteAccountAPI(w, r)
-	default:
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		s.handleDeleteAccountAPI(w, account)
+	}
+}
+
+// accountFromPath resolves the {id} path segment, writing a 404 when it does
+// not match a stored account.
+func (s server) accountFromPath(w http.ResponseWriter, r *http.Request) (trustpin.Account, bool) {
+	id := strings.TrimSpace(r.PathValue("id"))
+	if id == "" {
+		writeError(w, http.StatusBadRequest, codeValidation, "account id is required", "id")
+		return trustpin.Account{}, false
 	}
+
+	accounts, err := s.service.LoadAccounts()
+	if err != nil {
+		writeServiceError(w, err)
+		return trustpin.Account{}, false
+	}
+
+	for _, account := range accounts {
+		if strings.EqualFold(account.ID, id) {
+			return account, true
+		}
+	}
+
+	writeError(w, http.StatusNotFound, codeNotFound, "account not found", "id")
+	return trustpin.Account{}, false
 }
 
-func (s server) handleListAccounts(w http.ResponseWriter) {
+func (s server) handleListAccounts(w http.ResponseWriter, r *http.Request) {
+	groupBy, err := trustpin.NormalizeGroupBy(r.URL.Query().Get("groupBy"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
 	accounts, err := s.service.LoadAccounts()
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
 	response := make([]trustpin.AccountSnapshot, 0, len(accounts))
-	for _, account := range accounts {
-		response = append(response, trustpin.BuildAccountSnapshot(account))
+	if query := strings.TrimSpace(r.URL.Query().Get("q")); query != "" {
+		for _, match := range trustpin.SearchAccounts(accounts, query) {
+			snapshot := trustpin.BuildAccountSnapshot(match.Account)
+			snapshot.Score = match.Score
+			response = append(response, snapshot)
+		}
+	} else {
+		for _, account := range accounts {
+			response = append(response, trustpin.BuildAccountSnapshot(account))
+		}
 	}
 
+	if groupBy != "" {
+		writeJSON(w, http.StatusOK, trustpin.GroupAccounts(response, groupBy, func(snapshot trustpin.AccountSnapshot) trustpin.Account { return snapshot.Account }))
+		return
+	}
 	writeJSON(w, http.StatusOK, response)
 }
 
 func (s server) handleAddAccountAPI(w http.ResponseWriter, r *http.Request) {
 	var req apiAddRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		writeDecodeError(w, err)
 		return
 	}
 
+	if strings.TrimSpace(req.Name) == "" {
+		req.Name = trustpin.AccountName(req.Issuer, req.Label)
+	}
 	if err := trustpin.ValidateAccountInput(req.Name, req.Secret); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		writeServiceError(w, err)
+		return
+	}
+	encoding, err := trustpin.NormalizeSecretEncoding(req.Encoding)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if err := trustpin.ValidateSecret(req.Secret, encoding); err != nil {
+		writeServiceError(w, err)
 		return
 	}
 
@@ -125,48 +353,69 @@ func (s server) handleAddAccountAPI(w http.ResponseWriter, r *http.Request) {
 		req.Digits = trustpin.DefaultDigits
 	}
 	if err := trustpin.ValidateDigits(req.Digits); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		writeServiceError(w, err)
+		return
+	}
+	color, err := trustpin.NormalizeColor(req.Color)
+	if err != nil {
+		writeServiceError(w, err)
 		return
 	}
 
 	summary, err := s.service.UpsertAccounts([]trustpin.Account{{
-		Name:      req.Name,
-		Secret:    req.Secret,
-		Interval:  req.Interval,
-		Digits:    req.Digits,
-		Algorithm: req.Algorithm,
-		Type:      req.Type,
-		Counter:   req.Counter,
-		Tags:      req.Tags,
-		Favorite:  req.Favorite,
-		Notes:     req.Notes,
-		SortOrder: req.SortOrder,
+		Name:           req.Name,
+		Issuer:         req.Issuer,
+		Label:          req.Label,
+		Secret:         req.Secret,
+		SecretEncoding: encoding,
+		Interval:       req.Interval,
+		Digits:         req.Digits,
+		Algorithm:      req.Algorithm,
+		Type:           req.Type,
+		Counter:        req.Counter,
+		Tags:           req.Tags,
+		Favorite:       req.Favorite,
+		Notes:          req.Notes,
+		Color:          color,
+		SortOrder:      req.SortOrder,
 	}})
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, summary)
-}
-
-func (s server) handleUpdateAccountAPI(w http.ResponseWriter, r *http.Request) {
-	currentName := strings.TrimSpace(r.URL.Query().Get("name"))
-	if currentName == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "name query parameter is required"})
-		return
+	status := http.StatusOK
+	if summary.Added > 0 {
+		status = http.StatusCreated
 	}
+	writeJSON(w, status, summary)
+}
 
+func (s server) handleUpdateAccountAPI(w http.ResponseWriter, r *http.Request, current trustpin.Account) {
 	var req apiAddRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		writeDecodeError(w, err)
 		return
 	}
 
 	if strings.TrimSpace(req.Name) == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "account name cannot be empty"})
+		req.Name = trustpin.AccountName(req.Issuer, req.Label)
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		writeError(w, http.StatusBadRequest, codeValidation, "account name cannot be empty", "name")
 		return
 	}
+	encoding, err := trustpin.NormalizeSecretEncoding(req.Encoding)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	if strings.TrimSpace(req.Secret) != "" {
+		if err := trustpin.ValidateSecret(req.Secret, encoding); err != nil {
+			writeServiceError(w, err)
+			return
+		}
+	}
 
 	if req.Interval <= 0 {
 		req.Interval = trustpin.DefaultInterval
@@ -175,91 +424,112 @@ func (s server) handleUpdateAccountAPI(w http.ResponseWriter, r *http.Request) {
 		req.Digits = trustpin.DefaultDigits
 	}
 	if err := trustpin.ValidateDigits(req.Digits); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
-		return
-	}
-
-	if err := s.service.UpdateAccount(currentName, trustpin.Account{
-		Name:      req.Name,
-		Secret:    req.Secret,
-		Interval:  req.Interval,
-		Digits:    req.Digits,
-		Algorithm: req.Algorithm,
-		Type:      req.Type,
-		Counter:   req.Counter,
-		Tags:      req.Tags,
-		Favorite:  req.Favorite,
-		Notes:     req.Notes,
-		SortOrder: req.SortOrder,
-		Archived:  req.Archived,
+		writeServiceError(w, err)
+		return
+	}
+
+	if err := s.service.UpdateAccount(current.ID, trustpin.Account{
+		Name:           req.Name,
+		Issuer:         req.Issuer,
+		Label:          req.Label,
+		Secret:         req.Secret,
+		SecretEncoding: encoding,
+		Interval:       req.Interval,
+		Digits:         req.Digits,
+		Algorithm:      req.Algorithm,
+		Type:           req.Type,
+		Counter:        req.Counter,
+		Tags:           req.Tags,
+		Favorite:       req.Favorite,
+		Notes:          req.Notes,
+		Color:          req.Color,
+		SortOrder:      req.SortOrder,
+		Archived:       req.Archived,
 	}); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]string{
 		"status": "updated",
+		"id":     current.ID,
 		"name":   req.Name,
 	})
 }
 
-func (s server) handleDeleteAccountAPI(w http.ResponseWriter, r *http.Request) {
-	name := strings.TrimSpace(r.URL.Query().Get("name"))
-	if name == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "name query parameter is required"})
-		return
-	}
-
-	removed, err := s.service.DeleteAccount(name)
+func (s server) handleDeleteAccountAPI(w http.ResponseWriter, account trustpin.Account) {
+	removed, err := s.service.DeleteAccount(account.ID)
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"status":  "deleted",
+		"id":      account.ID,
 		"removed": removed,
 	})
 }
 
 func (s server) handleAPIHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	if r.Method != http.MethodGet {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		writeMethodNotAllowed(w, "GET")
 		return
 	}
 
 	report, err := s.service.HealthReport()
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, report)
 }
 
+// handleDiagnosticsAPI reports the doctor checks. It is read-only: restoring
+// a backup is left to `trustpin doctor --restore` on the machine itself.
+func (s server) handleDiagnosticsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "GET")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.service.Diagnose())
+}
+
 func (s server) handleImportQRAPI(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	if r.Method != http.MethodPost {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		writeMethodNotAllowed(w, "POST")
 		return
 	}
 
 	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "file too large or invalid form"})
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, codeTooLarge, "file too large", "qr")
+			return
+		}
+		writeError(w, http.StatusBadRequest, codeValidation, "expected a multipart form with a qr file", "qr")
+		return
+	}
+
+	strategy, err := trustpin.ParseMergeStrategy(r.FormValue("strategy"))
+	if err != nil {
+		writeServiceError(w, err)
 		return
 	}
+	dryRun, _ := strconv.ParseBool(r.FormValue("dryRun"))
 
 	file, _, err := r.FormFile("qr")
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no file uploaded"})
+		writeError(w, http.StatusBadRequest, codeValidation, "no file uploaded", "qr")
 		return
 	}
 	defer file.Close()
 
 	tmpFile, err := os.CreateTemp("", "trustpin-qr-*")
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to create temp file"})
+		writeError(w, http.StatusInternalServerError, codeInternal, "failed to create temp file", "")
 		return
 	}
 	tmpPath := tmpFile.Name()
@@ -267,21 +537,25 @@ func (s server) handleImportQRAPI(w http.ResponseWriter, r *http.Request) {
 
 	if _, err := io.Copy(tmpFile, file); err != nil {
 		_ = tmpFile.Close()
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to save upload"})
+		writeError(w, http.StatusInternalServerError, codeInternal, "failed to save upload", "")
 		return
 	}
 	_ = tmpFile.Close()
 
-	result, err := s.service.ImportAccountsFromQR(tmpPath)
+	result, err := s.service.ImportAccountsFromQR(tmpPath, trustpin.ImportOptions{Strategy: strategy, DryRun: dryRun})
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		writeServiceError(w, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"added":    result.Summary.Added,
 		"replaced": result.Summary.Replaced,
+		"merged":   result.Summary.Merged,
+		"kept":     result.Summary.Kept,
 		"skipped":  len(result.Skipped),
+		"strategy": result.Summary.Strategy,
+		"dryRun":   result.Summary.DryRun,
 		"changes":  result.Summary.Changes,
 		"details":  result.Skipped,
 	})
@@ -289,38 +563,18 @@ func (s server) handleImportQRAPI(w http.ResponseWriter, r *http.Request) {
 
 func (s server) handleAccountQR(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
-		return
-	}
-
-	name := strings.TrimSpace(r.URL.Query().Get("name"))
-	if name == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "name query parameter is required"})
-		return
-	}
-
-	accounts, err := s.service.LoadAccounts()
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		writeMethodNotAllowed(w, "GET")
 		return
 	}
 
-	var target *trustpin.Account
-	nameKey := strings.ToLower(name)
-	for _, a := range accounts {
-		if strings.ToLower(strings.TrimSpace(a.Name)) == nameKey {
-			target = &a
-			break
-		}
-	}
-	if target == nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "account not found"})
+	target, ok := s.accountFromPath(w, r)
+	if !ok {
 		return
 	}
 
-	png, err := trustpin.GenerateQRCodePNG(*target, 256)
+	png, err := trustpin.GenerateQRCodePNG(target, 256)
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to generate QR code"})
+		writeError(w, http.StatusInternalServerError, codeInternal, "failed to generate QR code", "")
 		return
 	}
 
@@ -330,40 +584,29 @@ func (s server) handleAccountQR(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s server) handleReorderAPI(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	if r.Method != http.MethodPut {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		writeMethodNotAllowed(w, "PUT")
 		return
 	}
 
 	var order []struct {
-		Name      string `json:"name"`
+		ID        string `json:"id"`
 		SortOrder int    `json:"sortOrder"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		writeDecodeError(w, err)
 		return
 	}
 
-	accounts, err := s.service.LoadAccounts()
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
-		return
-	}
-
-	orderMap := make(map[string]int, len(order))
+	positions := make(map[string]int, len(order))
 	for _, o := range order {
-		orderMap[strings.ToLower(strings.TrimSpace(o.Name))] = o.SortOrder
-	}
-
-	for i, a := range accounts {
-		if so, ok := orderMap[strings.ToLower(strings.TrimSpace(a.Name))]; ok {
-			accounts[i].SortOrder = so
+		if id := strings.TrimSpace(o.ID); id != "" {
+			positions[id] = o.SortOrder
 		}
 	}
 
-	if err := s.service.SaveAccounts(accounts); err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	if err := s.service.ReorderAccounts(positions); err != nil {
+		writeServiceError(w, err)
 		return
 	}
 
@@ -371,29 +614,26 @@ func (s server) handleReorderAPI(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s server) handleArchiveAPI(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	if r.Method != http.MethodPut {
-		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		writeMethodNotAllowed(w, "PUT")
 		return
 	}
 
-	var req struct {
-		Name     string `json:"name"`
-		Archived bool   `json:"archived"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+	account, ok := s.accountFromPath(w, r)
+	if !ok {
 		return
 	}
 
-	name := strings.TrimSpace(req.Name)
-	if name == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "name is required"})
+	var req struct {
+		Archived bool `json:"archived"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
 
-	if err := s.service.SetAccountArchived(name, req.Archived); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	if err := s.service.SetAccountArchived(account.ID, req.Archived); err != nil {
+		writeServiceError(w, err)
 		return
 	}
 
@@ -401,7 +641,167 @@ func (s server) handleArchiveAPI(w http.ResponseWriter, r *http.Request) {
 	if !req.Archived {
 		action = "restored"
 	}
-	writeJSON(w, http.StatusOK, map[string]string{"status": action, "name": name})
+	writeJSON(w, http.StatusOK, map[string]string{"status": action, "id": account.ID, "name": account.Name})
+}
+
+// handleUseAPI records that the dashboard copied or revealed the account's
+// code.
+func (s server) handleUseAPI(w http.ResponseWriter, r *http.Request) {
+	s.handleUsage(w, r, s.service.RecordUse)
+}
+
+// handleNextAPI moves a HOTP account to its next code.
+func (s server) handleNextAPI(w http.ResponseWriter, r *http.Request) {
+	s.handleUsage(w, r, s.service.AdvanceHOTP)
+}
+
+func (s server) handleUsage(w http.ResponseWriter, r *http.Request, record func(string, time.Time) (trustpin.Account, error)) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "POST")
+		return
+	}
+
+	account, ok := s.accountFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := record(account.ID, time.Now())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, trustpin.BuildAccountSnapshot(updated))
+}
+
+// handleAccountIconAPI serves, replaces or removes an account's custom icon.
+// Accounts without one get their issuer's icon in their brand color.
+func (s server) handleAccountIconAPI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+	default:
+		writeMethodNotAllowed(w, "GET, PUT, DELETE")
+		return
+	}
+
+	account, ok := s.accountFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if account.Icon != nil {
+			w.Header().Set("Content-Type", account.Icon.Type)
+			_, _ = w.Write(account.Icon.Data)
+			return
+		}
+		writeSVG(w, trustpin.BrandIconSVG(trustpin.AccountBrand(account)))
+	case http.MethodPut:
+		s.handleUploadIcon(w, r, account)
+	case http.MethodDelete:
+		updated, err := s.service.SetAccountIcon(account.ID, nil)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, trustpin.BuildAccountSnapshot(updated))
+	}
+}
+
+func (s server) handleUploadIcon(w http.ResponseWriter, r *http.Request, account trustpin.Account) {
+	// Leave room for the multipart framing around the largest allowed icon.
+	r.Body = http.MaxBytesReader(w, r.Body, trustpin.MaxIconBytes+16<<10)
+	if err := r.ParseMultipartForm(trustpin.MaxIconBytes); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, codeTooLarge, "file too large", "icon")
+			return
+		}
+		writeError(w, http.StatusBadRequest, codeValidation, "expected a multipart form with an icon file", "icon")
+		return
+	}
+
+	file, _, err := r.FormFile("icon")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeValidation, "no file uploaded", "icon")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, trustpin.MaxIconBytes+1))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, "failed to read upload", "")
+		return
+	}
+	icon, err := trustpin.NewAccountIcon(data)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	updated, err := s.service.SetAccountIcon(account.ID, icon)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, trustpin.BuildAccountSnapshot(updated))
+}
+
+// handleIssuerIconAPI draws an issuer's icon from the built-in brand catalog,
+// so the dashboard never fetches favicons from third parties.
+func (s server) handleIssuerIconAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeMethodNotAllowed(w, "GET")
+		return
+	}
+	writeSVG(w, trustpin.BrandIconSVG(trustpin.IssuerBrand(r.PathValue("issuer"))))
+}
+
+func writeSVG(w http.ResponseWriter, svg []byte) {
+	w.Header().Set("Content-Type", "image/svg+xml")
+	_, _ = w.Write(svg)
+}
+
+// batchRejection is the 422 body for a batch with failed operations: the
+// error envelope plus the per-operation results.
+type batchRejection struct {
+	Error apiError `json:"error"`
+	trustpin.BatchSummary
+}
+
+func (s server) handleBatchAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "POST")
+		return
+	}
+
+	var req struct {
+		Operations []trustpin.BatchOperation `json:"operations"`
+		DryRun     bool                      `json:"dryRun"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	summary, err := s.service.ApplyBatch(req.Operations, trustpin.BatchOptions{DryRun: req.DryRun})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if summary.Failed > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, batchRejection{
+			Error: apiError{
+				Code:    codeBatchRejected,
+				Message: fmt.Sprintf("batch rejected: %d of %d operations failed", summary.Failed, len(summary.Results)),
+			},
+			BatchSummary: summary,
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
 }
 
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
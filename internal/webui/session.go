@@ -0,0 +1,184 @@
+package webui
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookieName = "trustpin_session"
+
+	// activityHeader marks API requests that follow a real user interaction.
+	// Background polling omits it so it cannot keep an idle session alive.
+	activityHeader = "X-TrustPIN-Activity"
+)
+
+// sessionManager tracks browser sessions unlocked with the access token. A
+// session expires once it has seen no user activity for the idle timeout.
+type sessionManager struct {
+	mu       sync.Mutex
+	token    string
+	idle     time.Duration
+	now      func() time.Time
+	sessions map[string]time.Time
+}
+
+func newSessionManager(token string, idle time.Duration) *sessionManager {
+	return &sessionManager{
+		token:    token,
+		idle:     idle,
+		now:      time.Now,
+		sessions: make(map[string]time.Time),
+	}
+}
+
+// NewAccessToken returns a random token for unlocking the web dashboard.
+func NewAccessToken() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("webui: read random access token: " + err.Error())
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// login starts a session when token matches the access token.
+func (m *sessionManager) login(token string) (string, bool) {
+	if subtle.ConstantTimeCompare([]byte(token), []byte(m.token)) != 1 {
+		return "", false
+	}
+
+	id := NewAccessToken()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[id] = m.now()
+	return id, true
+}
+
+// check reports whether the session is still unlocked. When active is set
+// the idle timer restarts.
+func (m *sessionManager) check(id string, active bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lastSeen, ok := m.sessions[id]
+	if !ok {
+		return false
+	}
+	now := m.now()
+	if m.idle > 0 && now.Sub(lastSeen) >= m.idle {
+		delete(m.sessions, id)
+		return false
+	}
+	if active {
+		m.sessions[id] = now
+	}
+	return true
+}
+
+// lockAll ends every session. It reports how many were open.
+func (m *sessionManager) lockAll() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := len(m.sessions)
+	clear(m.sessions)
+	return count
+}
+
+// requireSession rejects API requests without an unlocked session.
+func (m *sessionManager) requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookieName)
+		active := r.Method != http.MethodGet || r.Header.Get(activityHeader) != ""
+		if err != nil || !m.check(cookie.Value, active) {
+			writeError(w, http.StatusUnauthorized, codeLocked, "the dashboard is locked", "")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type sessionStatus struct {
+	Locked      bool  `json:"locked"`
+	IdleTimeout int64 `json:"idleTimeout"`
+}
+
+// handleSessionAPI reports whether the caller is locked (GET) or unlocks a new
+// session with the access token (POST).
+func (s server) handleSessionAPI(w http.ResponseWriter, r *http.Request) {
+	status := sessionStatus{Locked: true, IdleTimeout: int64(s.sessions.idle / time.Second)}
+
+	switch r.Method {
+	case http.MethodGet:
+		if cookie, err := r.Cookie(sessionCookieName); err == nil {
+			status.Locked = !s.sessions.check(cookie.Value, false)
+		}
+		writeJSON(w, http.StatusOK, status)
+	case http.MethodPost:
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		id, ok := s.sessions.login(strings.TrimSpace(req.Token))
+		if !ok {
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "invalid access token", "token")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionCookieName,
+			Value:    id,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   s.secure,
+			SameSite: http.SameSiteStrictMode,
+		})
+		status.Locked = false
+		writeJSON(w, http.StatusOK, status)
+	default:
+		writeMethodNotAllowed(w, "GET, POST")
+	}
+}
+
+// handleLockAPI locks every open session. A screen-locker hook can call it
+// with a plain POST and no session, but another site cannot make a browser do
+// the same.
+func (s server) handleLockAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, "POST")
+		return
+	}
+	cookie, err := r.Cookie(sessionCookieName)
+	if (err != nil || !s.sessions.check(cookie.Value, false)) && !sameOrigin(r) {
+		writeError(w, http.StatusForbidden, codeCrossOrigin, "cross-origin requests need an unlocked session", "")
+		return
+	}
+
+	locked := s.sessions.lockAll()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "locked", "sessions": locked})
+}
+
+// sameOrigin reports whether r cannot have come from another site. Browsers
+// send Sec-Fetch-Site and Origin with a cross-site POST; tools such as curl
+// send neither.
+func sameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+	default:
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
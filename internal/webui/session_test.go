@@ -0,0 +1,102 @@
+package webui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSessionManagerIdleTimeout(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	sessions := newSessionManager("secret", 5*time.Minute)
+	sessions.now = func() time.Time { return now }
+
+	if _, ok := sessions.login("wrong"); ok {
+		t.Fatalf("expected a wrong token to be rejected")
+	}
+	id, ok := sessions.login("secret")
+	if !ok {
+		t.Fatalf("expected the access token to unlock a session")
+	}
+
+	now = now.Add(4 * time.Minute)
+	if !sessions.check(id, false) {
+		t.Fatalf("expected the session to be open before the timeout")
+	}
+	now = now.Add(2 * time.Minute)
+	if sessions.check(id, false) {
+		t.Fatalf("expected polling alone to let the session expire")
+	}
+
+	id, _ = sessions.login("secret")
+	for range 3 {
+		now = now.Add(4 * time.Minute)
+		if !sessions.check(id, true) {
+			t.Fatalf("expected activity to keep the session open")
+		}
+	}
+	if sessions.lockAll() != 1 || sessions.check(id, true) {
+		t.Fatalf("expected lockAll to end the session")
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	sessions := newSessionManager("secret", 0)
+	handler := sessions.requireSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/accounts", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a session, got %d", rec.Code)
+	}
+
+	id, _ := sessions.login("secret")
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/accounts", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: id})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected the request to pass with a session, got %d", rec.Code)
+	}
+}
+
+func TestLockRefusesCrossSiteRequests(t *testing.T) {
+	srv, _ := newTestServer(t)
+	handler := srv.handler()
+	sessionID, _ := srv.sessions.login("secret")
+
+	cases := []struct {
+		name    string
+		header  map[string]string
+		session bool
+		status  int
+	}{
+		{name: "cross site", header: map[string]string{"Origin": "https://evil.example", "Sec-Fetch-Site": "cross-site"}, status: http.StatusForbidden},
+		{name: "foreign origin", header: map[string]string{"Origin": "https://evil.example"}, status: http.StatusForbidden},
+		{name: "opaque origin", header: map[string]string{"Origin": "null"}, status: http.StatusForbidden},
+		{name: "plain tool", status: http.StatusOK},
+		{name: "same origin", header: map[string]string{"Origin": "http://example.com", "Sec-Fetch-Site": "same-origin"}, status: http.StatusOK},
+		{name: "cross site with session", header: map[string]string{"Sec-Fetch-Site": "same-site"}, session: true, status: http.StatusOK},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/lock", nil)
+		for name, value := range c.header {
+			req.Header.Set(name, value)
+		}
+		if c.session {
+			id, _ := srv.sessions.login("secret")
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: id})
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != c.status {
+			t.Errorf("%s: expected %d, got %d: %s", c.name, c.status, rec.Code, rec.Body.String())
+		}
+		if c.status == http.StatusForbidden && !srv.sessions.check(sessionID, false) {
+			t.Fatalf("%s: expected a refused request to leave the session open", c.name)
+		}
+	}
+}